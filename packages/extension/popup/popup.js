@@ -54,7 +54,7 @@ async function loadRecentBookmarks() {
             ? `<img class="recent-thumb" src="${item.thumb_data_url}" alt="" />`
             : `<div class="recent-thumb"></div>`
           }
-        <img class="recent-favicon" src="${item.favicon || ''}" alt="" onerror="this.style.display='none'" />
+        <img class="recent-favicon" src="${item.favicon || ''}" data-favicon-id="${item.favicon_id || ''}" alt="" onerror="this.style.display='none'" />
         <div class="recent-info">
           <div class="recent-title">${escHtml(item.alias || item.title || item.url)}</div>
           <div class="recent-time">${relativeTime(item.created_at)}</div>
@@ -74,6 +74,16 @@ async function loadRecentBookmarks() {
       </div>
     `).join('');
 
+    list.querySelectorAll('.recent-favicon[data-favicon-id]').forEach(async img => {
+      if (!img.dataset.faviconId) return;
+      try {
+        const favicon = await invokeExtension(METHODS.FAVICON_GET, { id: img.dataset.faviconId, size: 32 });
+        img.src = favicon.dataUrl;
+      } catch {
+        // 保留原 src，由 onerror 隐藏
+      }
+    });
+
     list.querySelectorAll('.preview-btn').forEach(btn => {
       btn.addEventListener('click', async e => {
         e.stopPropagation();
@@ -15,6 +15,7 @@ export const METHODS = {
   BOOKMARK_UPDATE_NOTES: 'bookmark.updateNotes',
   BOOKMARK_DOWNLOAD_HTML: 'bookmark.downloadHtml',
   BOOKMARK_OPEN_FOLDER: 'bookmark.openFolder',
+  FAVICON_GET: 'favicon.get',
   TRASH_LIST: 'trash.list',
   TRASH_RESTORE: 'trash.restore',
   TRASH_DELETE: 'trash.delete',
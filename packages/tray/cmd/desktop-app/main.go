@@ -3,6 +3,8 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,6 +12,8 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"chrome-collect-tray/internal/app"
 	"chrome-collect-tray/internal/protocol"
@@ -20,6 +24,14 @@ import (
 
 var Version = "dev"
 
+const (
+	maintenanceInterval = time.Hour
+	linkCheckTick       = 10 * time.Minute
+	archiveServerPoll   = 5 * time.Second
+	syncInterval        = 5 * time.Minute
+	feedExportTick      = 30 * time.Second
+)
+
 func main() {
 	service, err := app.New(Version)
 	if err != nil {
@@ -34,6 +46,13 @@ func main() {
 		},
 	}
 
+	if hasArg("--capture") {
+		if err := runCapture(service); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	if hasArg("--window") {
 		if err := runWindow(dispatcher); err != nil {
 			log.Fatal(err)
@@ -41,6 +60,14 @@ func main() {
 		return
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go service.RunMaintenance(ctx, maintenanceInterval)
+	go service.RunLinkChecker(ctx, linkCheckTick)
+	go service.RunArchiveServer(ctx, archiveServerPoll)
+	go service.RunSync(ctx, syncInterval)
+	go service.RunFeedExporter(ctx, feedExportTick)
+
 	systray.Run(func() {
 		onReady()
 	}, func() {
@@ -87,6 +114,13 @@ func runWindow(dispatcher *app.Dispatcher) error {
 	}
 	defer w.Destroy()
 
+	preview, err := app.StartPreviewServer(dispatcher.Service)
+	if err != nil {
+		return err
+	}
+	defer preview.Close()
+	dispatcher.PreviewURLFunc = preview.URL
+
 	w.SetTitle("Chrome Collect")
 	w.SetSize(1280, 860, webview.HintNone)
 
@@ -106,7 +140,8 @@ func runWindow(dispatcher *app.Dispatcher) error {
 		return err
 	}
 
-	w.Init(`window.chromeCollect = { invoke(method, payload = {}) { return chromeCollectInvoke(method, payload); } };`)
+	// 只在顶层管理页面暴露桥接接口，预览内容走 PreviewServer，不在这个来源下运行。
+	w.Init(`if (window === window.top) { window.chromeCollect = { invoke(method, payload = {}) { return chromeCollectInvoke(method, payload); } }; }`)
 	indexURL, err := resolveIndexURL()
 	if err != nil {
 		return err
@@ -141,6 +176,43 @@ func resolveIndexURL() (string, error) {
 	return "", fmt.Errorf("未找到桌面前端资源 index.html")
 }
 
+// runCapture 处理 `--capture [--skip-existing] <url>...`，不带网址或传入 - 时从标准输入逐行读取。
+func runCapture(service *app.Service) error {
+	var urls []string
+	for _, arg := range os.Args[1:] {
+		if arg != "-" && !strings.HasPrefix(arg, "--") {
+			urls = append(urls, arg)
+		}
+	}
+	if len(urls) == 0 || hasArg("-") {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			urls = append(urls, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	}
+
+	result, err := service.CaptureURLs(app.CaptureRequest{URLs: urls, SkipExisting: hasArg("--skip-existing")})
+	if err != nil {
+		return err
+	}
+	for _, bm := range result.Saved {
+		fmt.Printf("已保存 %s\n", bm.URL)
+	}
+	for _, rawURL := range result.Skipped {
+		fmt.Printf("已存在 %s\n", rawURL)
+	}
+	for _, failure := range result.Failed {
+		fmt.Fprintf(os.Stderr, "失败 %s: %s\n", failure.URL, failure.Error)
+	}
+	if len(result.Failed) > 0 {
+		return fmt.Errorf("%d 个网址保存失败", len(result.Failed))
+	}
+	return nil
+}
+
 func hasArg(flag string) bool {
 	for _, arg := range os.Args[1:] {
 		if arg == flag {
@@ -20,6 +20,7 @@ func main() {
 		log.Fatal(err)
 	}
 	defer service.Close()
+	service.SetSource(app.SourceExtension)
 
 	dispatcher := &app.Dispatcher{
 		Service: service,
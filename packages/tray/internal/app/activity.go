@@ -0,0 +1,390 @@
+package app
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"chrome-collect-tray/internal/protocol"
+)
+
+const (
+	SourceDesktop   = "desktop"
+	SourceExtension = "extension"
+
+	activityUndo        = protocol.MethodActivityUndo
+	activityTrashExpire = "trash.expire"
+	defaultActivityMax  = 100
+
+	activityRetention  = 180 * 24 * time.Hour
+	maxActivityEntries = 50000
+)
+
+// journalTarget 描述一类可被记录的对象：快照哪些列，以及撤销时允许回写哪些列。
+type journalTarget struct {
+	table    string
+	key      string
+	columns  []string
+	undoable map[string]bool
+}
+
+var journalTargets = map[string]journalTarget{
+	"bookmark": {
+		table:   "bookmarks",
+		key:     "id",
+		columns: []string{"url", "title", "alias", "notes", "tags", "deleted_at", "read_status", "read_status_at", "starred", "starred_at", "color_label", "archived", "archived_at"},
+		undoable: map[string]bool{
+			"alias": true, "notes": true, "tags": true, "deleted_at": true,
+			"read_status": true, "read_status_at": true, "starred": true, "starred_at": true,
+			"color_label": true, "archived": true, "archived_at": true,
+		},
+	},
+	"annotation": {
+		table:    "annotations",
+		key:      "id",
+		columns:  []string{"target_id", "exact", "comment", "color"},
+		undoable: map[string]bool{"comment": true, "color": true},
+	},
+	"domain": {
+		table:    "domain_names",
+		key:      "domain",
+		columns:  []string{"display_name"},
+		undoable: map[string]bool{},
+	},
+	"setting": {
+		table:    "app_meta",
+		key:      "key",
+		columns:  []string{"value"},
+		undoable: map[string]bool{},
+	},
+}
+
+type Activity struct {
+	ID         int64          `json:"id"`
+	At         int64          `json:"at"`
+	Source     string         `json:"source"`
+	Action     string         `json:"action"`
+	TargetType string         `json:"targetType"`
+	TargetID   string         `json:"targetId"`
+	Before     map[string]any `json:"before"`
+	After      map[string]any `json:"after"`
+	UndoOf     int64          `json:"undoOf,omitempty"`
+	UndoneBy   int64          `json:"undoneBy,omitempty"`
+	Undoable   bool           `json:"undoable"`
+}
+
+type ActivityQuery struct {
+	Limit    int    `json:"limit"`
+	BeforeID int64  `json:"beforeId"`
+	TargetID string `json:"targetId"`
+	Action   string `json:"action"`
+}
+
+type dbExecutor interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func (s *Service) SetSource(source string) {
+	s.source = source
+}
+
+// journaled 在单独事务中执行 mutate，并记录目标对象前后的变化。
+func (s *Service) journaled(action, targetType, targetID string, mutate func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := s.journal(tx, action, targetType, targetID, func() error { return mutate(tx) }); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Service) journal(db dbExecutor, action, targetType, targetID string, mutate func() error) error {
+	before, err := snapshotTarget(db, targetType, targetID)
+	if err != nil {
+		return err
+	}
+	if err := mutate(); err != nil {
+		return err
+	}
+	after, err := snapshotTarget(db, targetType, targetID)
+	if err != nil {
+		return err
+	}
+	before, after = diffSnapshots(before, after)
+	if before == nil && after == nil {
+		return nil
+	}
+	return s.appendActivity(db, Activity{Action: action, TargetType: targetType, TargetID: targetID, Before: before, After: after})
+}
+
+func (s *Service) appendActivity(db dbExecutor, entry Activity) error {
+	before, err := marshalSnapshot(entry.Before)
+	if err != nil {
+		return err
+	}
+	after, err := marshalSnapshot(entry.After)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT INTO activity_log (created_at, source, action, target_type, target_id, before_json, after_json, undo_of)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		time.Now().UnixMilli(), s.source, entry.Action, entry.TargetType, entry.TargetID, before, after, entry.UndoOf)
+	return err
+}
+
+func (s *Service) ListActivity(query ActivityQuery) ([]Activity, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultActivityMax
+	}
+	limit = min(limit, maxPageSize)
+	where := "1 = 1"
+	args := []any{}
+	if query.BeforeID > 0 {
+		where += " AND a.id < ?"
+		args = append(args, query.BeforeID)
+	}
+	if query.TargetID != "" {
+		where += " AND a.target_id = ?"
+		args = append(args, query.TargetID)
+	}
+	if query.Action != "" {
+		where += " AND a.action = ?"
+		args = append(args, query.Action)
+	}
+
+	rows, err := s.db.Query(`SELECT a.id, a.created_at, a.source, a.action, a.target_type, a.target_id, a.before_json, a.after_json, a.undo_of,
+		COALESCE((SELECT MAX(u.id) FROM activity_log u WHERE u.undo_of = a.id), 0)
+		FROM activity_log a WHERE `+where+` ORDER BY a.id DESC LIMIT ?`, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []Activity{}
+	for rows.Next() {
+		entry, err := scanActivity(rows)
+		if err != nil {
+			return nil, err
+		}
+		s.revealSnapshot(entry.Before)
+		s.revealSnapshot(entry.After)
+		items = append(items, *entry)
+	}
+	return items, rows.Err()
+}
+
+// PruneActivity 删除超过 activityRetention 的记录，并把总数限制在 maxActivityEntries 以内。
+// 每个对象最新的一条记录总会保留：同步用它判断本机修改的时间，撤销也只针对最近的修改。
+func (s *Service) PruneActivity() (int64, error) {
+	var cutoffID int64
+	err := s.db.QueryRow("SELECT id FROM activity_log ORDER BY id DESC LIMIT 1 OFFSET ?", maxActivityEntries).Scan(&cutoffID)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+	result, err := s.db.Exec(`DELETE FROM activity_log WHERE (created_at < ? OR id <= ?)
+		AND id NOT IN (SELECT MAX(id) FROM activity_log GROUP BY target_type, target_id)`,
+		time.Now().Add(-activityRetention).UnixMilli(), cutoffID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (s *Service) getActivity(id int64) (*Activity, error) {
+	return scanActivity(s.db.QueryRow(`SELECT a.id, a.created_at, a.source, a.action, a.target_type, a.target_id, a.before_json, a.after_json, a.undo_of,
+		COALESCE((SELECT MAX(u.id) FROM activity_log u WHERE u.undo_of = a.id), 0)
+		FROM activity_log a WHERE a.id = ?`, id))
+}
+
+// UndoActivity 将目标对象恢复到该记录之前的值；若之后又被修改过则拒绝，避免覆盖新内容。
+func (s *Service) UndoActivity(id int64) (*Activity, error) {
+	entry, err := s.getActivity(id)
+	if err != nil {
+		return nil, err
+	}
+	if entry.UndoneBy > 0 {
+		return nil, errors.New("该操作已撤销")
+	}
+	if !entry.Undoable {
+		return nil, errors.New("该操作无法撤销")
+	}
+	target := journalTargets[entry.TargetType]
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	current, err := snapshotTarget(tx, entry.TargetType, entry.TargetID)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, sql.ErrNoRows
+	}
+	var sets []string
+	var args []any
+	for column, value := range entry.After {
+		if fmt.Sprint(current[column]) != fmt.Sprint(value) {
+			return nil, errors.New("记录之后已被修改，无法撤销")
+		}
+		sets = append(sets, column+" = ?")
+		args = append(args, journalValue(entry.Before[column]))
+	}
+
+	if entry.TargetType == "bookmark" {
+		for field := range revisionFields {
+			if value, ok := entry.Before[field].(string); ok {
+				if err := s.recordRevision(tx, entry.TargetID, field, value); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	if _, err := tx.Exec("UPDATE "+target.table+" SET "+strings.Join(sets, ", ")+" WHERE "+target.key+" = ?", append(args, entry.TargetID)...); err != nil {
+		return nil, err
+	}
+	after, err := snapshotTarget(tx, entry.TargetType, entry.TargetID)
+	if err != nil {
+		return nil, err
+	}
+	before, after := diffSnapshots(current, after)
+	if err := s.appendActivity(tx, Activity{Action: activityUndo, TargetType: entry.TargetType, TargetID: entry.TargetID, Before: before, After: after, UndoOf: entry.ID}); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.getActivity(entry.ID)
+}
+
+// revealSnapshot 仅用于展示：解锁时把快照中加密的标题、别名和备注还原为明文。
+func (s *Service) revealSnapshot(snapshot map[string]any) {
+	for _, field := range sealedSnapshotFields {
+		if value, ok := snapshot[field].(string); ok {
+			if plain, err := s.openField(value); err == nil {
+				snapshot[field] = plain
+			}
+		}
+	}
+}
+
+func snapshotTarget(db dbExecutor, targetType, targetID string) (map[string]any, error) {
+	target, ok := journalTargets[targetType]
+	if !ok {
+		return nil, fmt.Errorf("未知的记录类型: %s", targetType)
+	}
+	values := make([]any, len(target.columns))
+	dest := make([]any, len(target.columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	err := db.QueryRow("SELECT "+strings.Join(target.columns, ", ")+" FROM "+target.table+" WHERE "+target.key+" = ?", targetID).Scan(dest...)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	snapshot := make(map[string]any, len(values))
+	for i, column := range target.columns {
+		if raw, ok := values[i].([]byte); ok {
+			values[i] = string(raw)
+		}
+		snapshot[column] = values[i]
+	}
+	return snapshot, nil
+}
+
+// diffSnapshots 只保留发生变化的列；对象新建或删除时保留完整快照。
+func diffSnapshots(before, after map[string]any) (map[string]any, map[string]any) {
+	if before == nil || after == nil {
+		return before, after
+	}
+	changedBefore := map[string]any{}
+	changedAfter := map[string]any{}
+	for column, value := range after {
+		if fmt.Sprint(before[column]) != fmt.Sprint(value) {
+			changedBefore[column] = before[column]
+			changedAfter[column] = value
+		}
+	}
+	if len(changedAfter) == 0 {
+		return nil, nil
+	}
+	return changedBefore, changedAfter
+}
+
+func marshalSnapshot(snapshot map[string]any) (string, error) {
+	if snapshot == nil {
+		return "", nil
+	}
+	raw, err := json.Marshal(snapshot)
+	return string(raw), err
+}
+
+func unmarshalSnapshot(raw string) (map[string]any, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	var snapshot map[string]any
+	decoder := json.NewDecoder(bytes.NewReader([]byte(raw)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+func journalValue(value any) any {
+	if number, ok := value.(json.Number); ok {
+		if n, err := number.Int64(); err == nil {
+			return n
+		}
+		return number.String()
+	}
+	return value
+}
+
+func scanActivity(row interface{ Scan(dest ...any) error }) (*Activity, error) {
+	entry := &Activity{}
+	var before, after string
+	if err := row.Scan(&entry.ID, &entry.At, &entry.Source, &entry.Action, &entry.TargetType, &entry.TargetID, &before, &after, &entry.UndoOf, &entry.UndoneBy); err != nil {
+		return nil, err
+	}
+	var err error
+	if entry.Before, err = unmarshalSnapshot(before); err != nil {
+		return nil, err
+	}
+	if entry.After, err = unmarshalSnapshot(after); err != nil {
+		return nil, err
+	}
+	entry.Undoable = entry.isUndoable()
+	return entry, nil
+}
+
+func (a Activity) isUndoable() bool {
+	if a.UndoOf > 0 || a.UndoneBy > 0 || a.Before == nil || a.After == nil {
+		return false
+	}
+	target, ok := journalTargets[a.TargetType]
+	if !ok {
+		return false
+	}
+	for column := range a.After {
+		if !target.undoable[column] {
+			return false
+		}
+	}
+	return true
+}
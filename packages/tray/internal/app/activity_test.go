@@ -0,0 +1,131 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"chrome-collect-tray/internal/protocol"
+)
+
+func lastActivity(t *testing.T, s *Service, targetID string) Activity {
+	t.Helper()
+	items, err := s.ListActivity(ActivityQuery{TargetID: targetID, Limit: 1})
+	if err != nil || len(items) != 1 {
+		t.Fatalf("activity for %s = %+v, %v", targetID, items, err)
+	}
+	return items[0]
+}
+
+func TestUndoNotesAndAlias(t *testing.T) {
+	s := newTestService(t)
+	s.SetSource(SourceExtension)
+	bm := saveTestBookmark(t, s, "https://example.com/a", "A", "<p>a</p>")
+	if err := s.UpdateNotes(bm.ID, "important"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateNotes(bm.ID, ""); err != nil {
+		t.Fatal(err)
+	}
+	wipe := lastActivity(t, s, bm.ID)
+	if wipe.Source != SourceExtension || wipe.Before["notes"] != "important" || wipe.After["notes"] != "" || !wipe.Undoable {
+		t.Fatalf("journal entry = %+v", wipe)
+	}
+
+	undone, err := s.UndoActivity(wipe.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if undone.UndoneBy == 0 || undone.Undoable {
+		t.Fatalf("entry after undo = %+v", undone)
+	}
+	if got, _ := s.GetBookmark(bm.ID); got.Notes != "important" {
+		t.Fatalf("notes after undo = %q", got.Notes)
+	}
+	if _, err := s.UndoActivity(wipe.ID); err == nil {
+		t.Fatal("entry undone twice")
+	}
+
+	if err := s.UpdateAlias(bm.ID, "first"); err != nil {
+		t.Fatal(err)
+	}
+	first := lastActivity(t, s, bm.ID)
+	if err := s.UpdateAlias(bm.ID, "second"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.UndoActivity(first.ID); err == nil {
+		t.Fatal("undo overwrote a later change")
+	}
+}
+
+func TestUndoBulkMove(t *testing.T) {
+	s := newTestService(t)
+	bm := saveTestBookmark(t, s, "https://example.com/a", "A", "<p>a</p>")
+	if _, err := s.RunBulk(BulkRequest{IDs: []string{bm.ID}, Action: bulkActionTag, Tags: []string{"inbox", "keep"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RunBulk(BulkRequest{IDs: []string{bm.ID}, Action: bulkActionMove, From: []string{"inbox"}, Tags: []string{"later"}}); err != nil {
+		t.Fatal(err)
+	}
+	move := lastActivity(t, s, bm.ID)
+	if move.Action != protocol.MethodBookmarkBulk+"."+bulkActionMove || !move.Undoable {
+		t.Fatalf("move entry = %+v", move)
+	}
+	if _, err := s.UndoActivity(move.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := s.GetBookmark(bm.ID); got.Tags != `["inbox","keep"]` {
+		t.Fatalf("tags after undoing move = %s", got.Tags)
+	}
+}
+
+func TestListActivityLimit(t *testing.T) {
+	s := newTestService(t)
+	bm := saveTestBookmark(t, s, "https://example.com/a", "A", "<p>a</p>")
+	if _, err := s.db.Exec(`WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < ?)
+		INSERT INTO activity_log (created_at, source, action, target_type, target_id, before_json, after_json, undo_of)
+		SELECT i, 'desktop', 'test', 'bookmark', ?, '', '', 0 FROM n`, maxPageSize+5, bm.ID); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct{ limit, want int }{{0, defaultActivityMax}, {-1, defaultActivityMax}, {7, 7}, {maxPageSize + 1, maxPageSize}} {
+		items, err := s.ListActivity(ActivityQuery{Limit: c.limit})
+		if err != nil || len(items) != c.want {
+			t.Fatalf("limit %d: got %d items, %v", c.limit, len(items), err)
+		}
+	}
+	page, _ := s.ListActivity(ActivityQuery{Limit: 3})
+	next, _ := s.ListActivity(ActivityQuery{Limit: 3, BeforeID: page[2].ID})
+	if next[0].ID >= page[2].ID {
+		t.Fatalf("page after %d starts at %d", page[2].ID, next[0].ID)
+	}
+}
+
+func TestPruneActivity(t *testing.T) {
+	s := newTestService(t)
+	old := time.Now().Add(-activityRetention - time.Hour).UnixMilli()
+	insert := func(target string, at int64) {
+		t.Helper()
+		if _, err := s.db.Exec(`INSERT INTO activity_log (created_at, source, action, target_type, target_id, before_json, after_json, undo_of)
+			VALUES (?, 'desktop', 'test', 'bookmark', ?, '', '', 0)`, at, target); err != nil {
+			t.Fatal(err)
+		}
+	}
+	insert("a", old)
+	insert("a", old)
+	insert("a", time.Now().UnixMilli())
+	insert("b", old)
+	insert("b", old)
+
+	removed, err := s.PruneActivity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a 的两条旧记录被删除；b 只删旧的那条，最新一条即使过期也要保留。
+	if removed != 3 {
+		t.Fatalf("removed = %d", removed)
+	}
+	var remaining int
+	_ = s.db.QueryRow("SELECT COUNT(*) FROM activity_log WHERE target_id IN ('a', 'b')").Scan(&remaining)
+	if remaining != 2 {
+		t.Fatalf("remaining = %d", remaining)
+	}
+}
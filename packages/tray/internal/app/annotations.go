@@ -0,0 +1,366 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"chrome-collect-tray/internal/protocol"
+	"github.com/google/uuid"
+)
+
+const (
+	annotationFormatMarkdown = "markdown"
+	annotationFormatJSONLD   = "jsonld"
+	annotationContextURL     = "http://www.w3.org/ns/anno.jsonld"
+)
+
+var hexColorPattern = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// Annotation 的选择器字段对应 W3C Web Annotation 的 TextQuoteSelector 与 TextPositionSelector。
+type Annotation struct {
+	ID         string `json:"id"`
+	BookmarkID string `json:"bookmarkId"`
+	Exact      string `json:"exact"`
+	Prefix     string `json:"prefix"`
+	Suffix     string `json:"suffix"`
+	Start      int    `json:"start"`
+	End        int    `json:"end"`
+	Comment    string `json:"comment"`
+	Color      string `json:"color"`
+	CreatedAt  int64  `json:"created_at"`
+	UpdatedAt  int64  `json:"updated_at"`
+	// Locked 表示资料库未解锁，选中文本和批注已被清空。
+	Locked bool `json:"locked,omitempty"`
+}
+
+type AnnotationInput struct {
+	BookmarkID string `json:"bookmarkId"`
+	Exact      string `json:"exact"`
+	Prefix     string `json:"prefix"`
+	Suffix     string `json:"suffix"`
+	Start      *int   `json:"start"`
+	End        *int   `json:"end"`
+	Comment    string `json:"comment"`
+	Color      string `json:"color"`
+}
+
+type AnnotationSearchHit struct {
+	Annotation
+	BookmarkTitle string `json:"bookmarkTitle"`
+	BookmarkURL   string `json:"bookmarkUrl"`
+}
+
+type AnnotationExport struct {
+	Format  string `json:"format"`
+	Mime    string `json:"mime"`
+	Content string `json:"content"`
+}
+
+const annotationColumns = "id, target_id, exact, prefix, suffix, start_pos, end_pos, comment, color, created_at, updated_at"
+
+func scanAnnotation(row interface{ Scan(dest ...any) error }, extra ...any) (*Annotation, error) {
+	a := &Annotation{}
+	dest := []any{&a.ID, &a.BookmarkID, &a.Exact, &a.Prefix, &a.Suffix, &a.Start, &a.End, &a.Comment, &a.Color, &a.CreatedAt, &a.UpdatedAt}
+	err := row.Scan(append(dest, extra...)...)
+	return a, err
+}
+
+func (s *Service) CreateAnnotation(input AnnotationInput) (*Annotation, error) {
+	if input.BookmarkID == "" || strings.TrimSpace(input.Exact) == "" {
+		return nil, errors.New("缺少收藏 ID 或选中文本")
+	}
+	if err := validateAnnotationColor(input.Color); err != nil {
+		return nil, err
+	}
+	start, end := -1, -1
+	if input.Start != nil && input.End != nil {
+		start, end = *input.Start, *input.End
+		if start < 0 || end < start {
+			return nil, errors.New("无效的文本位置")
+		}
+	}
+	var exists int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM bookmarks WHERE id = ? AND deleted_at = 0", input.BookmarkID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if exists == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	sealed := []string{input.Exact, input.Prefix, input.Suffix, input.Comment}
+	for i, value := range sealed {
+		var err error
+		if sealed[i], err = s.sealField(value); err != nil {
+			return nil, err
+		}
+	}
+
+	id := uuid.New().String()
+	now := time.Now().UnixMilli()
+	err := s.journaled(protocol.MethodAnnotationCreate, "annotation", id, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`INSERT INTO annotations (`+annotationColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, input.BookmarkID, sealed[0], sealed[1], sealed[2], start, end, sealed[3], input.Color, now, now)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.getAnnotation(id)
+}
+
+func (s *Service) UpdateAnnotation(id, comment, color string) (*Annotation, error) {
+	if err := validateAnnotationColor(color); err != nil {
+		return nil, err
+	}
+	comment, err := s.sealField(comment)
+	if err != nil {
+		return nil, err
+	}
+	err = s.journaled(protocol.MethodAnnotationUpdate, "annotation", id, func(tx *sql.Tx) error {
+		return execAffected(tx, `UPDATE annotations SET comment = ?, color = ?, updated_at = ?
+			WHERE id = ? AND target_id IN (SELECT id FROM bookmarks WHERE deleted_at = 0)`,
+			comment, color, time.Now().UnixMilli(), id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.getAnnotation(id)
+}
+
+func (s *Service) DeleteAnnotation(id string) error {
+	return s.journaled(protocol.MethodAnnotationDelete, "annotation", id, func(tx *sql.Tx) error {
+		return execAffected(tx, "DELETE FROM annotations WHERE id = ?", id)
+	})
+}
+
+func (s *Service) ListAnnotations(bookmarkID string) ([]Annotation, error) {
+	rows, err := s.db.Query(`SELECT `+annotationColumns+` FROM annotations
+		WHERE target_id = ? AND target_id IN (SELECT id FROM bookmarks WHERE deleted_at = 0)
+		ORDER BY CASE WHEN start_pos < 0 THEN 1 ELSE 0 END, start_pos, created_at`, bookmarkID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []Annotation{}
+	for rows.Next() {
+		a, err := scanAnnotation(rows)
+		if err != nil {
+			return nil, err
+		}
+		s.revealAnnotation(a)
+		items = append(items, *a)
+	}
+	return items, rows.Err()
+}
+
+func (s *Service) SearchAnnotations(q string, limit int) ([]AnnotationSearchHit, error) {
+	if strings.TrimSpace(q) == "" {
+		return nil, errors.New("缺少搜索关键词")
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+	like := "%" + q + "%"
+	sealed := sealedFieldPrefix + "%"
+	rows, err := s.db.Query(`SELECT a.id, a.target_id, a.exact, a.prefix, a.suffix, a.start_pos, a.end_pos, a.comment, a.color, a.created_at, a.updated_at,
+		CASE WHEN b.alias != '' THEN b.alias ELSE b.title END, b.url
+		FROM annotations a JOIN bookmarks b ON b.id = a.target_id
+		WHERE b.deleted_at = 0 AND ((a.exact LIKE ? AND a.exact NOT LIKE ?) OR (a.comment LIKE ? AND a.comment NOT LIKE ?))
+		ORDER BY a.updated_at DESC LIMIT ?`, like, sealed, like, sealed, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hits := []AnnotationSearchHit{}
+	for rows.Next() {
+		var hit AnnotationSearchHit
+		a, err := scanAnnotation(rows, &hit.BookmarkTitle, &hit.BookmarkURL)
+		if err != nil {
+			return nil, err
+		}
+		s.revealAnnotation(a)
+		hit.Annotation = *a
+		hit.BookmarkTitle = s.revealField(hit.BookmarkTitle)
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
+
+func (s *Service) ExportAnnotations(bookmarkID, format string) (*AnnotationExport, error) {
+	bm, err := s.GetBookmark(bookmarkID)
+	if err != nil {
+		return nil, err
+	}
+	if bm == nil || bm.DeletedAt > 0 {
+		return nil, sql.ErrNoRows
+	}
+	items, err := s.ListAnnotations(bookmarkID)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if item.Locked {
+			return nil, ErrLibraryLocked
+		}
+	}
+
+	switch format {
+	case "", annotationFormatMarkdown:
+		return &AnnotationExport{
+			Format:  annotationFormatMarkdown,
+			Mime:    "text/markdown",
+			Content: annotationsMarkdown(bm, items),
+		}, nil
+	case annotationFormatJSONLD:
+		content, err := annotationsJSONLD(bm, items)
+		if err != nil {
+			return nil, err
+		}
+		return &AnnotationExport{
+			Format:  annotationFormatJSONLD,
+			Mime:    "application/ld+json",
+			Content: content,
+		}, nil
+	default:
+		return nil, fmt.Errorf("不支持的导出格式: %s", format)
+	}
+}
+
+func (s *Service) getAnnotation(id string) (*Annotation, error) {
+	a, err := scanAnnotation(s.db.QueryRow("SELECT "+annotationColumns+" FROM annotations WHERE id = ?", id))
+	if err != nil {
+		return nil, err
+	}
+	s.revealAnnotation(a)
+	return a, nil
+}
+
+// revealAnnotation 解密选中文本、上下文和批注，未解锁时清空并标记。
+func (s *Service) revealAnnotation(a *Annotation) {
+	for _, field := range []*string{&a.Exact, &a.Prefix, &a.Suffix, &a.Comment} {
+		plain, err := s.openField(*field)
+		if err != nil {
+			plain = ""
+			a.Locked = true
+		}
+		*field = plain
+	}
+}
+
+// validateAnnotationColor 接受与颜色标签相同的名称或 #rgb / #rrggbb。
+func validateAnnotationColor(color string) error {
+	if colorLabels[color] || hexColorPattern.MatchString(color) {
+		return nil
+	}
+	return fmt.Errorf("无效的标注颜色: %s", color)
+}
+
+func annotationsMarkdown(bm *Bookmark, items []Annotation) string {
+	title := bm.Alias
+	if title == "" {
+		title = bm.Title
+	}
+	if title == "" {
+		title = bm.URL
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n<%s>\n", title, bm.URL)
+	for _, item := range items {
+		b.WriteString("\n")
+		for _, line := range strings.Split(item.Exact, "\n") {
+			b.WriteString("> " + line + "\n")
+		}
+		if item.Comment != "" {
+			b.WriteString("\n" + item.Comment + "\n")
+		}
+		fmt.Fprintf(&b, "\n<sub>%s</sub>\n", time.UnixMilli(item.UpdatedAt).Format("2006-01-02 15:04"))
+	}
+	return b.String()
+}
+
+func annotationsJSONLD(bm *Bookmark, items []Annotation) (string, error) {
+	type selector struct {
+		Type   string `json:"type"`
+		Exact  string `json:"exact,omitempty"`
+		Prefix string `json:"prefix,omitempty"`
+		Suffix string `json:"suffix,omitempty"`
+		Start  *int   `json:"start,omitempty"`
+		End    *int   `json:"end,omitempty"`
+	}
+	type body struct {
+		Type    string `json:"type"`
+		Value   string `json:"value"`
+		Format  string `json:"format"`
+		Purpose string `json:"purpose"`
+	}
+	type target struct {
+		Source   string     `json:"source"`
+		Selector []selector `json:"selector"`
+	}
+	type annotation struct {
+		ID       string `json:"id"`
+		Type     string `json:"type"`
+		Created  string `json:"created"`
+		Modified string `json:"modified"`
+		Body     []body `json:"body,omitempty"`
+		Target   target `json:"target"`
+	}
+	type page struct {
+		ID         string       `json:"id"`
+		Type       string       `json:"type"`
+		PartOf     string       `json:"partOf"`
+		StartIndex int          `json:"startIndex"`
+		Items      []annotation `json:"items"`
+	}
+	// 按 W3C 模型，集合本身不含标注，而是通过 first 指向 AnnotationPage；标注数量有限，只输出一页。
+	type collection struct {
+		Context string `json:"@context"`
+		ID      string `json:"id"`
+		Type    string `json:"type"`
+		Label   string `json:"label"`
+		Total   int    `json:"total"`
+		First   *page  `json:"first,omitempty"`
+	}
+
+	out := collection{
+		Context: annotationContextURL,
+		ID:      "urn:uuid:" + bm.ID,
+		Type:    "AnnotationCollection",
+		Label:   bm.Title,
+		Total:   len(items),
+	}
+	if len(items) > 0 {
+		out.First = &page{ID: out.ID + "#page-1", Type: "AnnotationPage", PartOf: out.ID, Items: []annotation{}}
+	}
+	for _, item := range items {
+		selectors := []selector{{Type: "TextQuoteSelector", Exact: item.Exact, Prefix: item.Prefix, Suffix: item.Suffix}}
+		if item.Start >= 0 {
+			start, end := item.Start, item.End
+			selectors = append(selectors, selector{Type: "TextPositionSelector", Start: &start, End: &end})
+		}
+		entry := annotation{
+			ID:       "urn:uuid:" + item.ID,
+			Type:     "Annotation",
+			Created:  time.UnixMilli(item.CreatedAt).UTC().Format(time.RFC3339),
+			Modified: time.UnixMilli(item.UpdatedAt).UTC().Format(time.RFC3339),
+			Target:   target{Source: bm.URL, Selector: selectors},
+		}
+		if item.Comment != "" {
+			entry.Body = []body{{Type: "TextualBody", Value: item.Comment, Format: "text/plain", Purpose: "commenting"}}
+		}
+		out.First.Items = append(out.First.Items, entry)
+	}
+	raw, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
@@ -0,0 +1,269 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func intPtr(v int) *int { return &v }
+
+func TestAnnotationLifecycle(t *testing.T) {
+	s := newTestService(t)
+	bm := saveTestBookmark(t, s, "https://example.com/a", "Article", "<p>alpha beta gamma</p>")
+
+	later, err := s.CreateAnnotation(AnnotationInput{BookmarkID: bm.ID, Exact: "gamma", Start: intPtr(11), End: intPtr(16)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := s.CreateAnnotation(AnnotationInput{BookmarkID: bm.ID, Exact: "alpha", Prefix: "", Suffix: " beta",
+		Start: intPtr(0), End: intPtr(5), Comment: "first word", Color: "yellow"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateAnnotation(AnnotationInput{BookmarkID: bm.ID, Exact: "beta", Start: intPtr(5), End: intPtr(2)}); err == nil {
+		t.Fatal("reversed range accepted")
+	}
+	if _, err := s.CreateAnnotation(AnnotationInput{BookmarkID: "missing", Exact: "x"}); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("missing bookmark error = %v", err)
+	}
+
+	items, err := s.ListAnnotations(bm.ID)
+	if err != nil || len(items) != 2 || items[0].ID != first.ID || items[1].ID != later.ID {
+		t.Fatalf("list = %+v, %v", items, err)
+	}
+
+	updated, err := s.UpdateAnnotation(first.ID, "edited", "#ffcc00")
+	if err != nil || updated.Comment != "edited" || updated.Color != "#ffcc00" || updated.Exact != "alpha" {
+		t.Fatalf("update = %+v, %v", updated, err)
+	}
+	if err := s.DeleteAnnotation(later.ID); err != nil {
+		t.Fatal(err)
+	}
+	if items, _ := s.ListAnnotations(bm.ID); len(items) != 1 {
+		t.Fatalf("after delete = %+v", items)
+	}
+	if err := s.DeleteAnnotation(later.ID); err == nil {
+		t.Fatal("deleting twice succeeded")
+	}
+}
+
+func TestAnnotationColorValidation(t *testing.T) {
+	s := newTestService(t)
+	bm := saveTestBookmark(t, s, "https://example.com/a", "Article", "<p>text</p>")
+	for _, color := range []string{"", "red", "#abc", "#A0B1C2"} {
+		if _, err := s.CreateAnnotation(AnnotationInput{BookmarkID: bm.ID, Exact: "text", Color: color}); err != nil {
+			t.Fatalf("color %q rejected: %v", color, err)
+		}
+	}
+	for _, color := range []string{"pink", "#abcd", "#12345g", "red;background:url(x)"} {
+		if _, err := s.CreateAnnotation(AnnotationInput{BookmarkID: bm.ID, Exact: "text", Color: color}); err == nil {
+			t.Fatalf("color %q accepted", color)
+		}
+	}
+	a, err := s.CreateAnnotation(AnnotationInput{BookmarkID: bm.ID, Exact: "text"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.UpdateAnnotation(a.ID, "", "javascript:"); err == nil {
+		t.Fatal("invalid color accepted on update")
+	}
+}
+
+func TestSearchAnnotations(t *testing.T) {
+	s := newTestService(t)
+	bm := saveTestBookmark(t, s, "https://example.com/a", "Article", "<p>text</p>")
+	if err := s.UpdateAlias(bm.ID, "Named"); err != nil {
+		t.Fatal(err)
+	}
+	other := saveTestBookmark(t, s, "https://example.com/b", "Other", "<p>text</p>")
+	if _, err := s.CreateAnnotation(AnnotationInput{BookmarkID: bm.ID, Exact: "needle in text"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateAnnotation(AnnotationInput{BookmarkID: other.ID, Exact: "plain", Comment: "a needle comment"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateAnnotation(AnnotationInput{BookmarkID: other.ID, Exact: "unrelated"}); err != nil {
+		t.Fatal(err)
+	}
+
+	hits, err := s.SearchAnnotations("needle", 0)
+	if err != nil || len(hits) != 2 {
+		t.Fatalf("hits = %+v, %v", hits, err)
+	}
+	titles := map[string]string{}
+	for _, hit := range hits {
+		titles[hit.BookmarkID] = hit.BookmarkTitle
+	}
+	if titles[bm.ID] != "Named" || titles[other.ID] != "Other" {
+		t.Fatalf("hit titles = %v", titles)
+	}
+
+	// 收藏移入回收站后不再出现在结果里，但普通搜索仍能通过标注找到收藏
+	where, args := s.bookmarkWhere("deleted_at = 0", "needle", "", BookmarkFilter{})
+	if items, err := s.queryBookmarks(where, args, defaultOrderBy, 10, 0); err != nil || len(items) != 2 {
+		t.Fatalf("bookmark search via annotations = %d, %v", len(items), err)
+	}
+	if _, err := s.RunBulk(BulkRequest{Action: bulkActionTrash, IDs: []string{other.ID}}); err != nil {
+		t.Fatal(err)
+	}
+	if hits, _ := s.SearchAnnotations("needle", 0); len(hits) != 1 || hits[0].BookmarkID != bm.ID {
+		t.Fatalf("hits after trashing = %+v", hits)
+	}
+	if _, err := s.SearchAnnotations("  ", 0); err == nil {
+		t.Fatal("blank query accepted")
+	}
+}
+
+func TestExportAnnotations(t *testing.T) {
+	s := newTestService(t)
+	bm := saveTestBookmark(t, s, "https://example.com/a", "Article", "<p>alpha beta</p>")
+	empty, err := s.ExportAnnotations(bm.ID, annotationFormatJSONLD)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(empty.Content, `"first"`) || !strings.Contains(empty.Content, `"total": 0`) {
+		t.Fatalf("empty collection = %s", empty.Content)
+	}
+
+	if _, err := s.CreateAnnotation(AnnotationInput{BookmarkID: bm.ID, Exact: "alpha\nbeta", Prefix: "x", Suffix: "y",
+		Start: intPtr(0), End: intPtr(10), Comment: "a note"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateAnnotation(AnnotationInput{BookmarkID: bm.ID, Exact: "beta"}); err != nil {
+		t.Fatal(err)
+	}
+
+	md, err := s.ExportAnnotations(bm.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"# Article\n", "<https://example.com/a>", "> alpha\n> beta\n", "\na note\n", "> beta\n"} {
+		if !strings.Contains(md.Content, want) {
+			t.Fatalf("markdown missing %q:\n%s", want, md.Content)
+		}
+	}
+	if md.Format != annotationFormatMarkdown || md.Mime != "text/markdown" {
+		t.Fatalf("markdown export = %+v", md)
+	}
+
+	export, err := s.ExportAnnotations(bm.ID, annotationFormatJSONLD)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Context string `json:"@context"`
+		Type    string `json:"type"`
+		Total   int    `json:"total"`
+		Items   []any  `json:"items"`
+		First   struct {
+			Type       string `json:"type"`
+			PartOf     string `json:"partOf"`
+			StartIndex int    `json:"startIndex"`
+			Items      []struct {
+				Type string `json:"type"`
+				Body []struct {
+					Value   string `json:"value"`
+					Purpose string `json:"purpose"`
+				} `json:"body"`
+				Target struct {
+					Source   string `json:"source"`
+					Selector []struct {
+						Type  string `json:"type"`
+						Exact string `json:"exact"`
+						Start *int   `json:"start"`
+						End   *int   `json:"end"`
+					} `json:"selector"`
+				} `json:"target"`
+			} `json:"items"`
+		} `json:"first"`
+	}
+	if err := json.Unmarshal([]byte(export.Content), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Context != annotationContextURL || doc.Type != "AnnotationCollection" || doc.Total != 2 || doc.Items != nil {
+		t.Fatalf("collection = %+v", doc)
+	}
+	page := doc.First
+	if page.Type != "AnnotationPage" || page.PartOf != "urn:uuid:"+bm.ID || page.StartIndex != 0 || len(page.Items) != 2 {
+		t.Fatalf("first page = %+v", page)
+	}
+	first := page.Items[0]
+	if first.Type != "Annotation" || first.Target.Source != bm.URL || len(first.Body) != 1 || first.Body[0].Value != "a note" ||
+		first.Body[0].Purpose != "commenting" {
+		t.Fatalf("first annotation = %+v", first)
+	}
+	selectors := first.Target.Selector
+	if len(selectors) != 2 || selectors[0].Type != "TextQuoteSelector" || selectors[0].Exact != "alpha\nbeta" ||
+		selectors[1].Type != "TextPositionSelector" || *selectors[1].Start != 0 || *selectors[1].End != 10 {
+		t.Fatalf("selectors = %+v", selectors)
+	}
+	if second := page.Items[1]; len(second.Body) != 0 || len(second.Target.Selector) != 1 {
+		t.Fatalf("second annotation = %+v", second)
+	}
+
+	if _, err := s.ExportAnnotations(bm.ID, "pdf"); err == nil {
+		t.Fatal("unknown format accepted")
+	}
+}
+
+func TestAnnotationsSealedWhenEncrypted(t *testing.T) {
+	s := newTestService(t)
+	bm := saveTestBookmark(t, s, "https://example.com/a", "Article", "<p>text</p>")
+	before, err := s.CreateAnnotation(AnnotationInput{BookmarkID: bm.ID, Exact: "quoted passage", Comment: "private thought"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.EnableEncryption(testPassphrase); err != nil {
+		t.Fatal(err)
+	}
+	after, err := s.CreateAnnotation(AnnotationInput{BookmarkID: bm.ID, Exact: "second passage", Comment: "later thought"})
+	if err != nil || after.Exact != "second passage" {
+		t.Fatalf("create while encrypted = %+v, %v", after, err)
+	}
+
+	rows, err := s.db.Query("SELECT exact, comment FROM annotations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var exact, comment string
+		if err := rows.Scan(&exact, &comment); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(exact, sealedFieldPrefix) || !strings.HasPrefix(comment, sealedFieldPrefix) {
+			t.Fatalf("annotation stored in plaintext: %q / %q", exact, comment)
+		}
+	}
+	rows.Close()
+	var leaked int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM activity_log WHERE before_json LIKE '%thought%' OR after_json LIKE '%thought%'").Scan(&leaked); err != nil || leaked != 0 {
+		t.Fatalf("plaintext comments in activity log: %d, %v", leaked, err)
+	}
+
+	if hits, err := s.SearchAnnotations("thought", 0); err != nil || len(hits) != 0 {
+		t.Fatalf("sealed annotations matched: %+v, %v", hits, err)
+	}
+	if updated, err := s.UpdateAnnotation(before.ID, "revised thought", "blue"); err != nil || updated.Comment != "revised thought" {
+		t.Fatalf("update = %+v, %v", updated, err)
+	}
+
+	s.LockLibrary()
+	items, err := s.ListAnnotations(bm.ID)
+	if err != nil || len(items) != 2 || !items[0].Locked || items[0].Exact != "" || items[0].Comment != "" {
+		t.Fatalf("locked list = %+v, %v", items, err)
+	}
+	if _, err := s.ExportAnnotations(bm.ID, ""); !errors.Is(err, ErrLibraryLocked) {
+		t.Fatalf("locked export error = %v", err)
+	}
+
+	if _, err := s.DisableEncryption(testPassphrase); err != nil {
+		t.Fatal(err)
+	}
+	var comment string
+	if err := s.db.QueryRow("SELECT comment FROM annotations WHERE id = ?", before.ID).Scan(&comment); err != nil || comment != "revised thought" {
+		t.Fatalf("comment after disabling = %q, %v", comment, err)
+	}
+}
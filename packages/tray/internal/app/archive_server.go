@@ -0,0 +1,343 @@
+package app
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"chrome-collect-tray/internal/protocol"
+)
+
+const (
+	metaArchiveServer       = "archive_server"
+	metaArchiveToken        = "archive_server_token"
+	metaArchiveListen       = "archive_server_listen"
+	metaArchiveError        = "archive_server_error"
+	defaultArchivePort      = 17385
+	archiveCookieName       = "cc_archive_token"
+	archiveIndexPageSize    = 100
+	archiveIndexCSP         = "default-src 'none'; style-src 'unsafe-inline'; form-action 'self'; frame-ancestors 'none'; base-uri 'none'"
+	archiveShutdownDeadline = 5 * time.Second
+)
+
+type archiveServerConfig struct {
+	Enabled bool `json:"enabled"`
+	LAN     bool `json:"lan"`
+	Port    int  `json:"port"`
+}
+
+type ArchiveServerStatus struct {
+	Enabled   bool     `json:"enabled"`
+	LAN       bool     `json:"lan"`
+	Port      int      `json:"port"`
+	Running   bool     `json:"running"`
+	Token     string   `json:"token"`
+	URLs      []string `json:"urls"`
+	LastError string   `json:"lastError,omitempty"`
+}
+
+type archiveIndexPage struct {
+	Q          string
+	Domain     string
+	Total      int
+	Items      []Bookmark
+	NextCursor string
+}
+
+func (s *Service) archiveServerConfig() archiveServerConfig {
+	cfg := archiveServerConfig{Port: defaultArchivePort}
+	if value, err := s.getMeta(metaArchiveServer); err == nil && value != "" {
+		_ = json.Unmarshal([]byte(value), &cfg)
+	}
+	if cfg.Port <= 0 {
+		cfg.Port = defaultArchivePort
+	}
+	return cfg
+}
+
+// archiveToken 首次使用时生成；令牌不经过 setSetting，避免写进操作记录。
+func (s *Service) archiveToken() (string, error) {
+	token, err := s.getMeta(metaArchiveToken)
+	if err != nil || token != "" {
+		return token, err
+	}
+	token = hex.EncodeToString(randomBytes(16))
+	return token, s.setMeta(metaArchiveToken, token)
+}
+
+func (s *Service) ArchiveServerStatus() (*ArchiveServerStatus, error) {
+	cfg := s.archiveServerConfig()
+	token, err := s.archiveToken()
+	if err != nil {
+		return nil, err
+	}
+	listen, _ := s.getMeta(metaArchiveListen)
+	lastError, _ := s.getMeta(metaArchiveError)
+	status := &ArchiveServerStatus{
+		Enabled:   cfg.Enabled,
+		LAN:       cfg.LAN,
+		Port:      cfg.Port,
+		Running:   listen != "",
+		Token:     token,
+		URLs:      []string{},
+		LastError: lastError,
+	}
+	hosts := []string{"127.0.0.1"}
+	if cfg.LAN {
+		hosts = append(hosts, lanAddresses()...)
+	}
+	for _, host := range hosts {
+		status.URLs = append(status.URLs, "http://"+net.JoinHostPort(host, strconv.Itoa(cfg.Port))+"/?token="+token)
+	}
+	return status, nil
+}
+
+// ConfigureArchiveServer 只保存配置，由托盘进程中的 RunArchiveServer 负责启停。
+func (s *Service) ConfigureArchiveServer(enabled, lan bool, port int) (*ArchiveServerStatus, error) {
+	if port == 0 {
+		port = defaultArchivePort
+	}
+	if port < 1024 || port > 65535 {
+		return nil, errors.New("端口需在 1024 到 65535 之间")
+	}
+	raw, err := json.Marshal(archiveServerConfig{Enabled: enabled, LAN: lan, Port: port})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.setSetting(protocol.MethodArchiveConfigure, metaArchiveServer, string(raw)); err != nil {
+		return nil, err
+	}
+	return s.ArchiveServerStatus()
+}
+
+// ResetArchiveToken 更换访问令牌，已配对的浏览器需要重新输入。
+func (s *Service) ResetArchiveToken() (*ArchiveServerStatus, error) {
+	if err := s.setMeta(metaArchiveToken, hex.EncodeToString(randomBytes(16))); err != nil {
+		return nil, err
+	}
+	return s.ArchiveServerStatus()
+}
+
+// RunArchiveServer 定期读取配置并启停只读存档服务，设置窗口与托盘分属不同进程，因此通过数据库同步。
+func (s *Service) RunArchiveServer(ctx context.Context, poll time.Duration) {
+	var server *http.Server
+	var running archiveServerConfig
+	stop := func() {
+		if server == nil {
+			return
+		}
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), archiveShutdownDeadline)
+		_ = server.Shutdown(shutdownCtx)
+		cancel()
+		server = nil
+		_ = s.setMeta(metaArchiveListen, "")
+	}
+	defer stop()
+	_ = s.setMeta(metaArchiveListen, "")
+
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+	for {
+		cfg := s.archiveServerConfig()
+		if server != nil && cfg != running {
+			stop()
+		}
+		if server == nil && cfg.Enabled {
+			host := "127.0.0.1"
+			if cfg.LAN {
+				host = ""
+			}
+			listener, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(cfg.Port)))
+			if err != nil {
+				_ = s.setMeta(metaArchiveError, err.Error())
+			} else {
+				_ = s.setMeta(metaArchiveError, "")
+				_ = s.setMeta(metaArchiveListen, listener.Addr().String())
+				server = &http.Server{Handler: s.archiveHandler(), ReadHeaderTimeout: 10 * time.Second}
+				running = cfg
+				go func(srv *http.Server) { _ = srv.Serve(listener) }(server)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) archiveHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.serveArchiveIndex)
+	mux.HandleFunc("GET /b/{id}", s.serveArchivePage)
+	return s.requireArchiveToken(mux)
+}
+
+// requireArchiveToken 接受 ?token= 或 Authorization: Bearer；通过 URL 传入的令牌换成 Cookie 后重定向去掉。
+func (s *Service) requireArchiveToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		w.Header().Set("Cache-Control", "no-store")
+		token, err := s.archiveToken()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		valid := func(candidate string) bool {
+			return candidate != "" && subtle.ConstantTimeCompare([]byte(candidate), []byte(token)) == 1
+		}
+
+		if query := r.URL.Query(); query.Has("token") {
+			if !valid(strings.TrimSpace(query.Get("token"))) {
+				writeArchiveLogin(w, http.StatusUnauthorized, "令牌不正确")
+				return
+			}
+			http.SetCookie(w, &http.Cookie{Name: archiveCookieName, Value: token, Path: "/", HttpOnly: true, SameSite: http.SameSiteStrictMode})
+			query.Del("token")
+			target := *r.URL
+			target.RawQuery = query.Encode()
+			http.Redirect(w, r, target.RequestURI(), http.StatusSeeOther)
+			return
+		}
+		if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && valid(bearer) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if cookie, err := r.Cookie(archiveCookieName); err == nil && valid(cookie.Value) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		writeArchiveLogin(w, http.StatusUnauthorized, "")
+	})
+}
+
+func (s *Service) serveArchiveIndex(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	page := archiveIndexPage{Q: query.Get("q"), Domain: query.Get("domain")}
+	result, err := s.listBookmarks(ListQuery{
+		Limit:          archiveIndexPageSize,
+		Cursor:         query.Get("cursor"),
+		Q:              page.Q,
+		BookmarkFilter: BookmarkFilter{Domain: page.Domain},
+	}, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page.Items = result.Items
+	page.NextCursor = result.NextCursor
+	if result.Total != nil {
+		page.Total = *result.Total
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", archiveIndexCSP)
+	_ = archiveIndexTemplate.Execute(w, page)
+}
+
+func (s *Service) serveArchivePage(w http.ResponseWriter, r *http.Request) {
+	bm, err := s.GetBookmark(r.PathValue("id"))
+	if err == nil && (bm == nil || bm.DeletedAt > 0) {
+		err = sql.ErrNoRows
+	}
+	var data []byte
+	if err == nil {
+		data, err = s.readArtifact(bm.FilePath)
+	}
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.NotFound(w, r)
+		return
+	case errors.Is(err, ErrLibraryLocked):
+		http.Error(w, err.Error(), http.StatusLocked)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", previewCSP)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	_, _ = w.Write(data)
+}
+
+func writeArchiveLogin(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", archiveIndexCSP)
+	w.WriteHeader(status)
+	_ = archiveLoginTemplate.Execute(w, message)
+}
+
+// lanAddresses 返回本机的局域网 IPv4 地址，用于在设置中展示其他设备可访问的地址。
+func lanAddresses() []string {
+	var hosts []string
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return hosts
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.IsPrivate() && ipNet.IP.To4() != nil {
+			hosts = append(hosts, ipNet.IP.String())
+		}
+	}
+	return hosts
+}
+
+func archiveNextURL(page archiveIndexPage) string {
+	query := url.Values{"cursor": {page.NextCursor}}
+	if page.Q != "" {
+		query.Set("q", page.Q)
+	}
+	if page.Domain != "" {
+		query.Set("domain", page.Domain)
+	}
+	return "/?" + query.Encode()
+}
+
+const archiveStyle = `body{font:14px/1.6 system-ui,sans-serif;max-width:960px;margin:0 auto;padding:24px;color:#1f2328}
+a{color:#0969da;text-decoration:none}a:hover{text-decoration:underline}
+form{display:flex;gap:8px;margin:16px 0}input{flex:1;padding:6px 10px;border:1px solid #d0d7de;border-radius:6px}
+button{padding:6px 14px;border:1px solid #d0d7de;border-radius:6px;background:#f6f8fa;cursor:pointer}
+ul{list-style:none;padding:0}li{padding:10px 0;border-bottom:1px solid #eaeef2}.meta{color:#656d76;font-size:12px}`
+
+var archiveIndexTemplate = template.Must(template.New("index").Funcs(template.FuncMap{
+	"date": func(ms int64) string { return time.UnixMilli(ms).Format("2006-01-02 15:04") },
+	"next": archiveNextURL,
+}).Parse(`<!DOCTYPE html>
+<html lang="zh-CN"><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Chrome Collect 存档</title><style>` + archiveStyle + `</style></head>
+<body>
+<h1><a href="/">Chrome Collect 存档</a></h1>
+<form method="get" action="/">
+<input name="q" value="{{.Q}}" placeholder="搜索标题、网址、备注">
+{{if .Domain}}<input type="hidden" name="domain" value="{{.Domain}}">{{end}}
+<button type="submit">搜索</button>
+</form>
+<p class="meta">{{if .Domain}}域名 {{.Domain}} · {{end}}共 {{.Total}} 条</p>
+<ul>
+{{range .Items}}<li>
+<a href="/b/{{.ID}}">{{if .Alias}}{{.Alias}}{{else if .Title}}{{.Title}}{{else}}{{.URL}}{{end}}</a>
+<div class="meta"><a href="/?domain={{.Domain}}">{{.Domain}}</a> · {{date .CreatedAt}} · <a href="{{.URL}}" target="_blank" rel="noopener noreferrer">原网址</a></div>
+</li>{{else}}<li class="meta">没有收藏</li>{{end}}
+</ul>
+{{if .NextCursor}}<p><a href="{{next .}}">下一页</a></p>{{end}}
+</body></html>`))
+
+var archiveLoginTemplate = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html lang="zh-CN"><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Chrome Collect 存档</title><style>` + archiveStyle + `</style></head>
+<body>
+<h1>Chrome Collect 存档</h1>
+<p>请输入桌面端设置中显示的访问令牌。</p>
+{{if .}}<p class="meta">{{.}}</p>{{end}}
+<form method="get" action="/"><input name="token" autocomplete="off" autofocus><button type="submit">进入</button></form>
+</body></html>`))
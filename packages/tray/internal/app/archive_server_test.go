@@ -0,0 +1,121 @@
+package app
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"chrome-collect-tray/internal/protocol"
+)
+
+func archiveRequest(t *testing.T, method, url string, edit func(*http.Request)) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edit != nil {
+		edit(req)
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp, string(body)
+}
+
+func TestArchiveServerRequiresToken(t *testing.T) {
+	s := newTestService(t)
+	bm := saveTestBookmark(t, s, "https://example.com/a", "<b>Archived</b>", "<p>archived body</p>")
+	srv := httptest.NewServer(s.archiveHandler())
+	t.Cleanup(srv.Close)
+	status, err := s.ArchiveServerStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := status.Token
+
+	if resp, body := archiveRequest(t, http.MethodGet, srv.URL+"/", nil); resp.StatusCode != http.StatusUnauthorized || strings.Contains(body, "Archived") {
+		t.Fatalf("anonymous index = %d %q", resp.StatusCode, body)
+	}
+	if resp, _ := archiveRequest(t, http.MethodGet, srv.URL+"/b/"+bm.ID+"?token=wrong", nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("wrong token = %d", resp.StatusCode)
+	}
+
+	// URL 中的令牌换成 Cookie，并重定向到不含令牌的地址。
+	resp, _ := archiveRequest(t, http.MethodGet, srv.URL+"/?q=x&token="+token, nil)
+	if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "/?q=x" {
+		t.Fatalf("token exchange = %d, location %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	cookies := resp.Cookies()
+	if len(cookies) != 1 || cookies[0].Name != archiveCookieName || !cookies[0].HttpOnly {
+		t.Fatalf("cookies = %+v", cookies)
+	}
+	withCookie := func(req *http.Request) { req.AddCookie(cookies[0]) }
+
+	resp, body := archiveRequest(t, http.MethodGet, srv.URL+"/", withCookie)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, "&lt;b&gt;Archived&lt;/b&gt;") || !strings.Contains(body, "/b/"+bm.ID) {
+		t.Fatalf("index = %d %q", resp.StatusCode, body)
+	}
+	if resp.Header.Get("Content-Security-Policy") != archiveIndexCSP {
+		t.Fatalf("index CSP = %q", resp.Header.Get("Content-Security-Policy"))
+	}
+
+	bearer := func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+token) }
+	resp, body = archiveRequest(t, http.MethodGet, srv.URL+"/b/"+bm.ID, bearer)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, "archived body") || resp.Header.Get("Content-Security-Policy") != previewCSP {
+		t.Fatalf("page = %d %q", resp.StatusCode, body)
+	}
+
+	// 只读：不接受写方法，回收站中的收藏不可访问。
+	for _, method := range []string{http.MethodPost, http.MethodDelete} {
+		if resp, _ := archiveRequest(t, method, srv.URL+"/b/"+bm.ID, bearer); resp.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("%s = %d", method, resp.StatusCode)
+		}
+	}
+	if _, err := s.RunBulk(BulkRequest{IDs: []string{bm.ID}, Action: bulkActionTrash}); err != nil {
+		t.Fatal(err)
+	}
+	if resp, _ := archiveRequest(t, http.MethodGet, srv.URL+"/b/"+bm.ID, bearer); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("trashed page = %d", resp.StatusCode)
+	}
+
+	if _, err := s.ResetArchiveToken(); err != nil {
+		t.Fatal(err)
+	}
+	if resp, _ := archiveRequest(t, http.MethodGet, srv.URL+"/", withCookie); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("cookie after token reset = %d", resp.StatusCode)
+	}
+}
+
+func TestConfigureArchiveServer(t *testing.T) {
+	s := newTestService(t)
+	for _, port := range []int{80, 70000} {
+		if _, err := s.ConfigureArchiveServer(true, false, port); err == nil {
+			t.Fatalf("port %d accepted", port)
+		}
+	}
+	status, err := s.ConfigureArchiveServer(true, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Enabled || status.Port != defaultArchivePort || status.Running || len(status.URLs) != 1 ||
+		!strings.HasPrefix(status.URLs[0], "http://127.0.0.1:17385/?token=") {
+		t.Fatalf("status = %+v", status)
+	}
+	if entry := lastActivity(t, s, metaArchiveServer); entry.Action != protocol.MethodArchiveConfigure {
+		t.Fatalf("configure activity = %+v", entry)
+	}
+	// 令牌不写入操作记录。
+	if _, err := s.ResetArchiveToken(); err != nil {
+		t.Fatal(err)
+	}
+	if items, err := s.ListActivity(ActivityQuery{TargetID: metaArchiveToken}); err != nil || len(items) != 0 {
+		t.Fatalf("token activity = %+v, %v", items, err)
+	}
+}
@@ -0,0 +1,656 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultBackupKeep  = 7
+	maxBackupKeep      = 100
+	backupInterval     = 24 * time.Hour
+	backupPartSize     = 4 << 20
+	backupManifestFile = "manifest.json"
+	backupRestoreDir   = "restore-staging"
+	blobGracePeriod    = 24 * time.Hour
+)
+
+// deviceLocalMetaKeys 描述只属于本机的设置，恢复备份时保留本机的值。
+var deviceLocalMetaKeys = []string{"sync_%", "webdav%", "s3_%", "archive_server%"}
+
+// backupStore 是备份目标上的对象操作，名称以 / 分隔；entries 对不存在的目录返回空列表，remove 可删除单个文件或整个目录。
+type backupStore interface {
+	read(name string) ([]byte, error)
+	write(name string, data []byte) error
+	entries(dir string) ([]remoteEntry, error)
+	remove(dir string) error
+}
+
+type remoteEntry struct {
+	name       string
+	size       int64
+	collection bool
+	modified   time.Time
+}
+
+// backupTarget 区分不同的备份目标：未完成的备份名称记在 pendingKey，对应的数据库快照保留在数据目录之外的 snapshot 文件中。
+type backupTarget struct {
+	store      backupStore
+	keep       int
+	pendingKey string
+	snapshot   string
+}
+
+type BackupResult struct {
+	Name     string `json:"name"`
+	Files    int    `json:"files"`
+	Uploaded int    `json:"uploaded"`
+	Parts    int    `json:"parts"`
+	Removed  int    `json:"removed"`
+	Swept    int    `json:"swept"`
+}
+
+type BackupInfo struct {
+	Name      string `json:"name"`
+	CreatedAt int64  `json:"createdAt"`
+	Device    string `json:"device"`
+	Encrypted bool   `json:"encrypted"`
+	Size      int64  `json:"size"`
+	Files     int    `json:"files"`
+}
+
+type RestoreResult struct {
+	Name      string `json:"name"`
+	Bookmarks int    `json:"bookmarks"`
+	Files     int    `json:"files"`
+	SavedTo   string `json:"savedTo"`
+}
+
+// backupManifest 最后写入，没有清单的备份目录视为未完成。
+type backupManifest struct {
+	Version   int               `json:"version"`
+	CreatedAt int64             `json:"createdAt"`
+	Device    string            `json:"device"`
+	Encrypted bool              `json:"encrypted"`
+	Database  backupDatabase    `json:"database"`
+	Files     map[string]string `json:"files"`
+}
+
+type backupDatabase struct {
+	Size   int64        `json:"size"`
+	SHA256 string       `json:"sha256"`
+	Parts  []backupPart `json:"parts"`
+}
+
+type backupPart struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// runBackup 串行执行备份并记录结果，供状态接口和定时任务使用。
+func (s *Service) runBackup(lastKey, errorKey string, backup func() (*BackupResult, error)) (*BackupResult, error) {
+	if !s.backupMu.TryLock() {
+		return nil, errors.New("备份正在进行中")
+	}
+	defer s.backupMu.Unlock()
+
+	result, err := backup()
+	if err != nil {
+		_ = s.setMeta(errorKey, err.Error())
+		return nil, err
+	}
+	_ = s.setMeta(errorKey, "")
+	_ = s.setMeta(lastKey, strconv.FormatInt(time.Now().UnixMilli(), 10))
+	return result, nil
+}
+
+// backupDue 在有未完成的备份或距上次成功已满一天时返回 true。
+func (s *Service) backupDue(pendingKey, lastKey string) bool {
+	if pending, _ := s.getMeta(pendingKey); pending != "" {
+		return true
+	}
+	value, _ := s.getMeta(lastKey)
+	last, _ := strconv.ParseInt(value, 10, 64)
+	return time.Since(time.UnixMilli(last)) >= backupInterval
+}
+
+// pushBackup 上传数据库快照和全部页面文件。页面文件按原始字节的哈希存入 blobs/，已存在的跳过；
+// 数据库快照分块上传并保留在本机直到清单写入，中断后再次调用会从缺失的分块继续。
+func (s *Service) pushBackup(target backupTarget) (*BackupResult, error) {
+	device, err := s.syncDeviceID()
+	if err != nil {
+		return nil, err
+	}
+	encryption, err := s.encryptionConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := s.getMeta(target.pendingKey)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := filepath.Join(filepath.Dir(s.dataDir), target.snapshot)
+	if _, statErr := os.Stat(snapshot); name == "" || statErr != nil {
+		name = time.Now().UTC().Format("20060102-150405") + "-" + device
+		_ = os.Remove(snapshot)
+		if _, err := s.db.Exec("VACUUM INTO ?", snapshot); err != nil {
+			return nil, fmt.Errorf("创建数据库快照失败: %w", err)
+		}
+		// 同一秒内再次备份会得到相同的名称，先清掉旧的分块，避免大小相同的分块被误认为已上传。
+		if err := target.store.remove("backups/" + name); err != nil {
+			return nil, err
+		}
+		if err := s.setMeta(target.pendingKey, name); err != nil {
+			return nil, err
+		}
+	}
+	result := &BackupResult{Name: name}
+	manifest := backupManifest{
+		Version:   1,
+		CreatedAt: time.Now().UnixMilli(),
+		Device:    device,
+		Encrypted: encryption != nil,
+		Files:     map[string]string{},
+	}
+
+	blobs := map[string]bool{}
+	entries, err := target.store.entries("blobs")
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		blobs[entry.name] = true
+	}
+	err = filepath.WalkDir(s.dataDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isLibraryFile(s.dbPath, d.Name()) {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		hash := sha256Hex(data)
+		manifest.Files[toRelativePath(s.dataDir, path)] = hash
+		result.Files++
+		if blobs[hash] {
+			return nil
+		}
+		if err := target.store.write(syncBlobName(hash), data); err != nil {
+			return err
+		}
+		blobs[hash] = true
+		result.Uploaded++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dir := "backups/" + name
+	uploaded := map[string]int64{}
+	entries, err = target.store.entries(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		uploaded[entry.name] = entry.size
+	}
+	file, err := os.Open(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	whole := sha256.New()
+	buf := make([]byte, backupPartSize)
+	for index := 1; ; index++ {
+		n, err := io.ReadFull(file, buf)
+		if n > 0 {
+			part := backupPart{Name: fmt.Sprintf("db.part-%05d", index), Size: int64(n), SHA256: sha256Hex(buf[:n])}
+			whole.Write(buf[:n])
+			if size, ok := uploaded[part.Name]; !ok || size != part.Size {
+				if err := target.store.write(dir+"/"+part.Name, buf[:n]); err != nil {
+					return nil, err
+				}
+				result.Parts++
+			}
+			manifest.Database.Parts = append(manifest.Database.Parts, part)
+			manifest.Database.Size += part.Size
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	manifest.Database.SHA256 = hex.EncodeToString(whole.Sum(nil))
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := target.store.write(dir+"/"+backupManifestFile, data); err != nil {
+		return nil, err
+	}
+	file.Close()
+	if err := s.discardPendingBackup(target); err != nil {
+		return nil, err
+	}
+
+	removed, err := pruneBackups(target.store, device, target.keep)
+	if err != nil {
+		return nil, err
+	}
+	result.Removed = removed
+	swept, err := sweepBlobs(target.store, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	result.Swept = swept
+	return result, nil
+}
+
+func (s *Service) discardPendingBackup(target backupTarget) error {
+	if err := os.Remove(filepath.Join(filepath.Dir(s.dataDir), target.snapshot)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return s.setMeta(target.pendingKey, "")
+}
+
+// isLibraryFile 排除数据库本身（由快照单独上传）和写了一半的临时文件。
+func isLibraryFile(dbPath, name string) bool {
+	return !strings.HasPrefix(name, filepath.Base(dbPath)) && !strings.HasPrefix(name, ".tmp")
+}
+
+// pruneBackups 只清理本机的旧备份；不再被引用的 blobs 由 sweepBlobs 回收。
+func pruneBackups(store backupStore, device string, keep int) (int, error) {
+	entries, err := store.entries("backups")
+	if err != nil {
+		return 0, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.collection && strings.HasSuffix(entry.name, "-"+device) {
+			names = append(names, entry.name)
+		}
+	}
+	slices.Sort(names)
+	removed := 0
+	for len(names) > keep {
+		if err := store.remove("backups/" + names[0]); err != nil {
+			return removed, err
+		}
+		names = names[1:]
+		removed++
+	}
+	return removed, nil
+}
+
+// sweepBlobs 删除 blobs/ 中既不在任何设备的备份清单里、也不在任何同步日志里的文件，返回删除的数量。
+// 其他设备的备份先上传 blobs、最后才写清单，所以只删除超过 blobGracePeriod 的文件；拿不到修改时间的一律保留。
+// 同步日志中出现过的哈希全部保留，落后的设备导入旧记录时仍需要它们。读取清单或日志出错时放弃本次清理。
+func sweepBlobs(store backupStore, now time.Time) (int, error) {
+	blobs, err := store.entries("blobs")
+	if err != nil || len(blobs) == 0 {
+		return 0, err
+	}
+	referenced := map[string]bool{}
+	backups, err := store.entries("backups")
+	if err != nil {
+		return 0, err
+	}
+	for _, entry := range backups {
+		if !entry.collection {
+			continue
+		}
+		manifest, err := readBackupManifest(store, entry.name)
+		if errors.Is(err, os.ErrNotExist) {
+			// 尚未写完清单的备份，它的 blobs 受宽限期保护。
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		for _, hash := range manifest.Files {
+			referenced[hash] = true
+		}
+	}
+	if logs, ok := store.(syncStore); ok {
+		if err := markSyncBlobs(logs, referenced); err != nil {
+			return 0, err
+		}
+	}
+
+	swept := 0
+	for _, entry := range blobs {
+		if entry.collection || referenced[entry.name] || entry.modified.IsZero() || now.Sub(entry.modified) < blobGracePeriod {
+			continue
+		}
+		if err := store.remove(syncBlobName(entry.name)); err != nil {
+			return swept, err
+		}
+		swept++
+	}
+	return swept, nil
+}
+
+// markSyncBlobs 把所有设备变更日志中引用的页面和缩略图哈希加入 referenced。
+func markSyncBlobs(store syncStore, referenced map[string]bool) error {
+	devices, err := store.list("devices")
+	if err != nil {
+		return err
+	}
+	for _, device := range devices {
+		data, err := store.readLog("devices/"+device+"/"+syncChangesFile, 0)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for line := range bytes.Lines(data) {
+			var record syncRecord
+			if json.Unmarshal(line, &record) != nil || record.Bookmark == nil {
+				continue
+			}
+			referenced[record.Bookmark.FileHash] = true
+			referenced[record.Bookmark.ThumbHash] = true
+		}
+	}
+	return nil
+}
+
+// listBackups 返回目标上所有已完成的备份，最新的在前。
+func listBackups(store backupStore) ([]BackupInfo, error) {
+	entries, err := store.entries("backups")
+	if err != nil {
+		return nil, err
+	}
+	backups := []BackupInfo{}
+	for _, entry := range entries {
+		if !entry.collection {
+			continue
+		}
+		manifest, err := readBackupManifest(store, entry.name)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, BackupInfo{
+			Name:      entry.name,
+			CreatedAt: manifest.CreatedAt,
+			Device:    manifest.Device,
+			Encrypted: manifest.Encrypted,
+			Size:      manifest.Database.Size,
+			Files:     len(manifest.Files),
+		})
+	}
+	slices.SortFunc(backups, func(a, b BackupInfo) int { return strings.Compare(b.Name, a.Name) })
+	return backups, nil
+}
+
+func readBackupManifest(store backupStore, name string) (*backupManifest, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return nil, fmt.Errorf("无效的备份名称: %s", name)
+	}
+	data, err := store.read("backups/" + name + "/" + backupManifestFile)
+	if err != nil {
+		return nil, err
+	}
+	var manifest backupManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("备份清单已损坏: %w", err)
+	}
+	return &manifest, nil
+}
+
+// restoreBackup 先把备份完整下载到暂存目录并逐一校验哈希，全部无误后才替换本机资料库。
+// 替换前的数据库快照和页面文件移到 before-restore-<时间> 目录，本机专属的设置和同步进度保持不变。
+func (s *Service) restoreBackup(store backupStore, name string) (*RestoreResult, error) {
+	manifest, err := readBackupManifest(store, name)
+	if err != nil {
+		return nil, err
+	}
+	rootDir := filepath.Dir(s.dataDir)
+	staging := filepath.Join(rootDir, backupRestoreDir)
+	if err := os.RemoveAll(staging); err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	stagedDB := filepath.Join(staging, filepath.Base(s.dbPath))
+	if err := downloadBackupDatabase(store, name, manifest, stagedDB); err != nil {
+		return nil, err
+	}
+	files := filepath.Join(staging, "files")
+	for rel, hash := range manifest.Files {
+		target := filepath.Join(files, filepath.FromSlash(rel))
+		if !strings.HasPrefix(target, files+string(filepath.Separator)) {
+			return nil, fmt.Errorf("备份清单中的路径无效: %s", rel)
+		}
+		data, err := store.read(syncBlobName(hash))
+		if err != nil {
+			return nil, fmt.Errorf("下载 %s 失败: %w", rel, err)
+		}
+		if sha256Hex(data) != hash {
+			return nil, fmt.Errorf("%s 校验失败", rel)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(target, data, 0o644); err != nil {
+			return nil, err
+		}
+	}
+	if err := migrateStagedDatabase(stagedDB); err != nil {
+		return nil, err
+	}
+
+	saved := filepath.Join(rootDir, "before-restore-"+time.Now().Format("20060102-150405"))
+	if err := os.MkdirAll(saved, 0o755); err != nil {
+		return nil, err
+	}
+	if _, err := s.db.Exec("VACUUM INTO ?", filepath.Join(saved, filepath.Base(s.dbPath))); err != nil {
+		return nil, fmt.Errorf("保存当前数据库失败: %w", err)
+	}
+	if err := s.replaceDatabase(stagedDB); err != nil {
+		return nil, err
+	}
+	if err := s.replaceLibraryFiles(files, saved, manifest.Files); err != nil {
+		return nil, err
+	}
+	if err := s.resetSyncProgress(); err != nil {
+		return nil, err
+	}
+	// 恢复的资料库可能使用不同的口令，已解锁的密钥不再适用。
+	s.LockLibrary()
+
+	result := &RestoreResult{Name: name, Files: len(manifest.Files), SavedTo: saved}
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM bookmarks").Scan(&result.Bookmarks); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func downloadBackupDatabase(store backupStore, name string, manifest *backupManifest, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	whole := sha256.New()
+	for _, part := range manifest.Database.Parts {
+		data, err := store.read("backups/" + name + "/" + part.Name)
+		if err != nil {
+			return fmt.Errorf("下载数据库分块 %s 失败: %w", part.Name, err)
+		}
+		if sha256Hex(data) != part.SHA256 {
+			return fmt.Errorf("数据库分块 %s 校验失败", part.Name)
+		}
+		whole.Write(data)
+		if _, err := file.Write(data); err != nil {
+			return err
+		}
+	}
+	if hex.EncodeToString(whole.Sum(nil)) != manifest.Database.SHA256 {
+		return errors.New("数据库快照校验失败")
+	}
+	return file.Close()
+}
+
+// migrateStagedDatabase 把旧版本的备份升级到当前的表结构，之后可以按列名整表复制。
+func migrateStagedDatabase(path string) error {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	staged := &Service{db: db}
+	if err := staged.initSchema(); err != nil {
+		return fmt.Errorf("升级备份数据库失败: %w", err)
+	}
+	return nil
+}
+
+// replaceDatabase 在一个事务中用暂存数据库的内容替换当前各表，其他进程持有的连接不受影响。
+func (s *Service) replaceDatabase(stagedDB string) error {
+	ctx := context.Background()
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "ATTACH DATABASE ? AS restored", stagedDB); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, "DETACH DATABASE restored")
+
+	rows, err := conn.QueryContext(ctx, `SELECT name FROM restored.sqlite_master
+		WHERE type = 'table' AND name NOT LIKE 'sqlite_%' AND name NOT LIKE 'sync_%'`)
+	if err != nil {
+		return err
+	}
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			rows.Close()
+			return err
+		}
+		tables = append(tables, table)
+	}
+	rows.Close()
+
+	columns := map[string][]string{}
+	for _, table := range tables {
+		cols, err := tableColumns(ctx, conn, table)
+		if err != nil {
+			return err
+		}
+		columns[table] = cols
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	localMeta := make([]string, len(deviceLocalMetaKeys))
+	for i := range localMeta {
+		localMeta[i] = "key LIKE ?"
+	}
+	notLocal := "NOT (" + strings.Join(localMeta, " OR ") + ")"
+	localArgs := make([]any, len(deviceLocalMetaKeys))
+	for i, pattern := range deviceLocalMetaKeys {
+		localArgs[i] = pattern
+	}
+	for _, table := range tables {
+		if len(columns[table]) == 0 {
+			continue
+		}
+		quoted := make([]string, len(columns[table]))
+		for i, column := range columns[table] {
+			quoted[i] = `"` + column + `"`
+		}
+		list := strings.Join(quoted, ", ")
+		where, args := "", []any(nil)
+		if table == "app_meta" {
+			where, args = " WHERE "+notLocal, localArgs
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM main."`+table+`"`+where, args...); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO main."`+table+`" (`+list+`) SELECT `+list+` FROM restored."`+table+`"`+where, args...); err != nil {
+			return fmt.Errorf("恢复 %s 失败: %w", table, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// tableColumns 返回当前数据库中该表的列；备份已升级到当前结构，两边的列一致。
+func tableColumns(ctx context.Context, conn *sql.Conn, table string) ([]string, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT name FROM pragma_table_info(?, 'main')`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	return columns, rows.Err()
+}
+
+// replaceLibraryFiles 把当前的页面文件整体移到 saved 目录，再把暂存的文件移入数据目录。
+func (s *Service) replaceLibraryFiles(staged, saved string, files map[string]string) error {
+	err := filepath.WalkDir(s.dataDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isLibraryFile(s.dbPath, d.Name()) {
+			return nil
+		}
+		target := filepath.Join(saved, filepath.FromSlash(toRelativePath(s.dataDir, path)))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		return os.Rename(path, target)
+	})
+	if err != nil {
+		return err
+	}
+	for rel := range files {
+		target := filepath.Join(s.dataDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		if err := os.Rename(filepath.Join(staged, filepath.FromSlash(rel)), target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
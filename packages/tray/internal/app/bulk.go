@@ -0,0 +1,286 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"chrome-collect-tray/internal/protocol"
+)
+
+const (
+	bulkActionTrash    = "trash"
+	bulkActionRestore  = "restore"
+	bulkActionPurge    = "purge"
+	bulkActionTag      = "tag"
+	bulkActionUntag    = "untag"
+	bulkActionMove     = "move"
+	bulkActionSetState = "setState"
+
+	maxBulkItems = 5000
+)
+
+// BulkRequest 中的 From 只用于 move：资料库没有文件夹，移动即把收藏从 From 中的标签换到 Tags 中的标签。
+type BulkRequest struct {
+	IDs    []string     `json:"ids"`
+	Query  *BulkQuery   `json:"query"`
+	Action string       `json:"action"`
+	Tags   []string     `json:"tags"`
+	From   []string     `json:"from"`
+	State  *StateUpdate `json:"state"`
+}
+
+type BulkQuery struct {
+	Q       string `json:"q"`
+	Trashed bool   `json:"trashed"`
+	BookmarkFilter
+}
+
+type BulkItemResult struct {
+	ID    string `json:"id"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type BulkResult struct {
+	Action    string           `json:"action"`
+	Total     int              `json:"total"`
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	Items     []BulkItemResult `json:"items"`
+}
+
+type bulkFiles struct {
+	filePath  string
+	thumbPath string
+}
+
+func (s *Service) RunBulk(req BulkRequest) (*BulkResult, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	ids, err := s.resolveBulkIDs(req)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &BulkResult{Action: req.Action, Total: len(ids), Items: make([]BulkItemResult, 0, len(ids))}
+	var purged []bulkFiles
+	now := time.Now().UnixMilli()
+	for _, id := range ids {
+		var files *bulkFiles
+		err := bulkSavepoint(tx, func() error {
+			return s.journal(tx, protocol.MethodBookmarkBulk+"."+req.Action, "bookmark", id, func() error {
+				var err error
+				files, err = s.applyBulkItem(tx, req, id, now)
+				return err
+			})
+		})
+		item := BulkItemResult{ID: id, OK: err == nil}
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				item.Error = "not_found"
+			} else if errors.Is(err, errBulkNotTagged) {
+				item.Error = "not_tagged"
+			} else {
+				item.Error = err.Error()
+			}
+			result.Failed++
+		} else {
+			result.Succeeded++
+			if files != nil {
+				purged = append(purged, *files)
+			}
+		}
+		result.Items = append(result.Items, item)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	for _, files := range purged {
+		s.removeBookmarkFiles(files.filePath, files.thumbPath)
+	}
+	return result, nil
+}
+
+var errBulkNotTagged = errors.New("收藏不含要移出的标签")
+
+// bulkSavepoint 让每一项在自己的保存点中执行，失败时只回滚这一项已经做出的修改，不影响同一事务中的其他项。
+func bulkSavepoint(tx *sql.Tx, apply func() error) error {
+	if _, err := tx.Exec("SAVEPOINT bulk_item"); err != nil {
+		return err
+	}
+	if err := apply(); err != nil {
+		if _, rollbackErr := tx.Exec("ROLLBACK TO bulk_item"); rollbackErr != nil {
+			return errors.Join(err, rollbackErr)
+		}
+		_, _ = tx.Exec("RELEASE bulk_item")
+		return err
+	}
+	_, err := tx.Exec("RELEASE bulk_item")
+	return err
+}
+
+func (req BulkRequest) validate() error {
+	if len(req.IDs) == 0 && req.Query == nil {
+		return errors.New("缺少收藏 ID 或查询条件")
+	}
+	if len(req.IDs) > maxBulkItems {
+		return fmt.Errorf("单次最多处理 %d 条", maxBulkItems)
+	}
+	switch req.Action {
+	case bulkActionTrash, bulkActionRestore, bulkActionPurge:
+	case bulkActionTag, bulkActionUntag:
+		if len(cleanTags(req.Tags)) == 0 {
+			return errors.New("缺少标签")
+		}
+	case bulkActionMove:
+		if len(cleanTags(req.From)) == 0 || len(cleanTags(req.Tags)) == 0 {
+			return errors.New("移动需要同时指定原标签和目标标签")
+		}
+	case bulkActionSetState:
+		if req.State == nil {
+			return errors.New("缺少要更新的状态")
+		}
+		if err := req.State.validate(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("不支持的批量操作: %s", req.Action)
+	}
+	if req.Query != nil {
+		return req.Query.validate()
+	}
+	return nil
+}
+
+func (s *Service) resolveBulkIDs(req BulkRequest) ([]string, error) {
+	seen := map[string]bool{}
+	var ids []string
+	for _, id := range req.IDs {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if req.Query == nil {
+		return ids, nil
+	}
+
+	base := "deleted_at = 0"
+	if req.Query.Trashed {
+		base = "deleted_at > 0"
+	}
+	where, args := s.bookmarkWhere(base, req.Query.Q, "", req.Query.BookmarkFilter)
+	rows, err := s.db.Query("SELECT id FROM bookmarks WHERE "+where+" ORDER BY created_at DESC LIMIT ?", append(args, maxBulkItems+1)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) > maxBulkItems {
+		return nil, fmt.Errorf("匹配结果超过 %d 条，请缩小查询范围", maxBulkItems)
+	}
+	return ids, nil
+}
+
+func (s *Service) applyBulkItem(tx *sql.Tx, req BulkRequest, id string, now int64) (*bulkFiles, error) {
+	switch req.Action {
+	case bulkActionTrash:
+		return nil, execAffected(tx, "UPDATE bookmarks SET deleted_at = ? WHERE id = ? AND deleted_at = 0", now, id)
+	case bulkActionRestore:
+		return nil, execAffected(tx, "UPDATE bookmarks SET deleted_at = 0 WHERE id = ? AND deleted_at > 0", id)
+	case bulkActionPurge:
+		var files bulkFiles
+		if err := tx.QueryRow("SELECT file_path, thumb_path FROM bookmarks WHERE id = ?", id).Scan(&files.filePath, &files.thumbPath); err != nil {
+			return nil, err
+		}
+		if err := deleteBookmarkRecords(tx, id); err != nil {
+			return nil, err
+		}
+		return &files, execAffected(tx, "DELETE FROM bookmarks WHERE id = ?", id)
+	case bulkActionTag, bulkActionUntag:
+		var raw string
+		if err := tx.QueryRow("SELECT tags FROM bookmarks WHERE id = ? AND deleted_at = 0", id).Scan(&raw); err != nil {
+			return nil, err
+		}
+		tags := parseTags(raw)
+		if req.Action == bulkActionTag {
+			tags = mergeTags(tags, cleanTags(req.Tags))
+		} else {
+			tags = removeTags(tags, cleanTags(req.Tags))
+		}
+		return nil, execAffected(tx, "UPDATE bookmarks SET tags = ? WHERE id = ?", encodeTags(tags), id)
+	case bulkActionMove:
+		var raw string
+		if err := tx.QueryRow("SELECT tags FROM bookmarks WHERE id = ? AND deleted_at = 0", id).Scan(&raw); err != nil {
+			return nil, err
+		}
+		tags := parseTags(raw)
+		kept := removeTags(tags, cleanTags(req.From))
+		if len(kept) == len(tags) {
+			return nil, errBulkNotTagged
+		}
+		return nil, execAffected(tx, "UPDATE bookmarks SET tags = ? WHERE id = ?", encodeTags(mergeTags(kept, cleanTags(req.Tags))), id)
+	case bulkActionSetState:
+		sets, args := req.State.assignments(now)
+		return nil, execAffected(tx, "UPDATE bookmarks SET "+sets+" WHERE id = ? AND deleted_at = 0", append(args, id)...)
+	}
+	return nil, fmt.Errorf("不支持的批量操作: %s", req.Action)
+}
+
+func execAffected(tx *sql.Tx, query string, args ...any) error {
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func cleanTags(tags []string) []string {
+	var cleaned []string
+	for _, tag := range tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			cleaned = append(cleaned, tag)
+		}
+	}
+	return mergeTags(nil, cleaned)
+}
+
+func removeTags(tags, remove []string) []string {
+	drop := map[string]bool{}
+	for _, tag := range remove {
+		drop[tag] = true
+	}
+	var kept []string
+	for _, tag := range tags {
+		if !drop[tag] {
+			kept = append(kept, tag)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,93 @@
+package app
+
+import (
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestRunBulkTagMoveAndReport(t *testing.T) {
+	s := newTestService(t)
+	a := saveTestBookmark(t, s, "https://example.com/a", "A", "<p>a</p>")
+	b := saveTestBookmark(t, s, "https://example.com/b", "B", "<p>b</p>")
+	if _, err := s.RunBulk(BulkRequest{IDs: []string{a.ID, b.ID}, Action: bulkActionTag, Tags: []string{"inbox", " "}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RunBulk(BulkRequest{IDs: []string{b.ID}, Action: bulkActionUntag, Tags: []string{"inbox"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := s.RunBulk(BulkRequest{IDs: []string{a.ID, b.ID, "missing", a.ID}, Action: bulkActionMove, From: []string{"inbox"}, Tags: []string{"later"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Total != 3 || result.Succeeded != 1 || result.Failed != 2 {
+		t.Fatalf("result = %+v", result)
+	}
+	want := []BulkItemResult{{ID: a.ID, OK: true}, {ID: b.ID, Error: "not_tagged"}, {ID: "missing", Error: "not_found"}}
+	if !slices.Equal(result.Items, want) {
+		t.Fatalf("items = %+v", result.Items)
+	}
+	if got, _ := s.GetBookmark(a.ID); got.Tags != `["later"]` {
+		t.Fatalf("tags after move = %s", got.Tags)
+	}
+
+	for _, req := range []BulkRequest{
+		{Action: bulkActionTrash},
+		{IDs: []string{a.ID}, Action: "copy"},
+		{IDs: []string{a.ID}, Action: bulkActionMove, Tags: []string{"x"}},
+		{IDs: []string{a.ID}, Action: bulkActionUntag},
+	} {
+		if _, err := s.RunBulk(req); err == nil {
+			t.Fatalf("invalid request accepted: %+v", req)
+		}
+	}
+}
+
+func TestRunBulkQueryTrashAndPurge(t *testing.T) {
+	s := newTestService(t)
+	a := saveTestBookmark(t, s, "https://example.com/a", "Alpha", "<p>a</p>")
+	saveTestBookmark(t, s, "https://example.com/b", "Beta", "<p>b</p>")
+
+	result, err := s.RunBulk(BulkRequest{Query: &BulkQuery{Q: "Alpha"}, Action: bulkActionTrash})
+	if err != nil || result.Succeeded != 1 || result.Items[0].ID != a.ID {
+		t.Fatalf("trash by query = %+v, %v", result, err)
+	}
+	result, err = s.RunBulk(BulkRequest{Query: &BulkQuery{Trashed: true}, Action: bulkActionPurge})
+	if err != nil || result.Succeeded != 1 {
+		t.Fatalf("purge trashed = %+v, %v", result, err)
+	}
+	if got, _ := s.GetBookmark(a.ID); got != nil {
+		t.Fatal("purged bookmark still present")
+	}
+	if _, err := os.Stat(getAbsoluteFilePath(s.dataDir, a.FilePath)); !os.IsNotExist(err) {
+		t.Fatal("purged page file left on disk")
+	}
+}
+
+func TestRunBulkRollsBackFailedItemOnly(t *testing.T) {
+	s := newTestService(t)
+	a := saveTestBookmark(t, s, "https://example.com/a", "A", "<p>a</p>")
+	b := saveTestBookmark(t, s, "https://example.com/b", "B", "<p>b</p>")
+	// 让 b 的活动记录写入失败：此时 b 的修改已经执行，必须随保存点一起撤回。
+	// 触发器建在主库而非 TEMP 中，连接池里的每个连接都能看到它。
+	if _, err := s.db.Exec(`CREATE TRIGGER fail_journal BEFORE INSERT ON activity_log
+		WHEN NEW.target_id = '` + b.ID + `' BEGIN SELECT RAISE(ABORT, 'journal failed'); END`); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _, _ = s.db.Exec("DROP TRIGGER IF EXISTS fail_journal") })
+
+	result, err := s.RunBulk(BulkRequest{IDs: []string{a.ID, b.ID}, Action: bulkActionTag, Tags: []string{"x"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Succeeded != 1 || result.Failed != 1 || result.Items[1].OK {
+		t.Fatalf("result = %+v", result)
+	}
+	if got, _ := s.GetBookmark(a.ID); got.Tags != `["x"]` {
+		t.Fatalf("successful item tags = %s", got.Tags)
+	}
+	if got, _ := s.GetBookmark(b.ID); got.Tags != "[]" {
+		t.Fatalf("failed item was partially applied: tags = %s", got.Tags)
+	}
+}
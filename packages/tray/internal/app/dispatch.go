@@ -13,6 +13,7 @@ import (
 type Dispatcher struct {
 	Service         *Service
 	OpenManagerFunc func() error
+	PreviewURLFunc  func(id string) string
 }
 
 func (d *Dispatcher) Handle(req protocol.Request) protocol.Response {
@@ -49,6 +50,20 @@ func (d *Dispatcher) dispatch(method string, payload json.RawMessage) (any, erro
 			return nil, err
 		}
 		return d.Service.SaveBookmark(input)
+	case protocol.MethodCaptureURL:
+		var input struct {
+			URL string `json:"url"`
+		}
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		return d.Service.CaptureURL(input.URL)
+	case protocol.MethodCaptureURLs:
+		var input CaptureRequest
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		return d.Service.CaptureURLs(input)
 	case protocol.MethodBookmarkExistsByURL:
 		var input struct {
 			URL string `json:"url"`
@@ -62,15 +77,11 @@ func (d *Dispatcher) dispatch(method string, payload json.RawMessage) (any, erro
 		}
 		return map[string]bool{"exists": exists}, nil
 	case protocol.MethodBookmarkList:
-		var input struct {
-			Limit  int    `json:"limit"`
-			Offset int    `json:"offset"`
-			Q      string `json:"q"`
-		}
+		var input ListQuery
 		if err := decodePayload(payload, &input); err != nil {
 			return nil, err
 		}
-		return d.Service.ListBookmarks(input.Limit, input.Offset, input.Q)
+		return d.Service.ListBookmarks(input)
 	case protocol.MethodBookmarkListRecent:
 		var input struct {
 			Limit int `json:"limit"`
@@ -91,6 +102,20 @@ func (d *Dispatcher) dispatch(method string, payload json.RawMessage) (any, erro
 			return nil, err
 		}
 		return d.Service.GetBookmark(input.ID)
+	case protocol.MethodBookmarkPreviewURL:
+		if d.PreviewURLFunc == nil {
+			return nil, errors.New("当前环境不支持隔离预览")
+		}
+		var input struct {
+			ID string `json:"id"`
+		}
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		if input.ID == "" {
+			return nil, errors.New("缺少收藏 ID")
+		}
+		return map[string]any{"url": d.PreviewURLFunc(input.ID)}, nil
 	case protocol.MethodBookmarkGetHTML:
 		var input struct {
 			ID string `json:"id"`
@@ -99,6 +124,14 @@ func (d *Dispatcher) dispatch(method string, payload json.RawMessage) (any, erro
 			return nil, err
 		}
 		return d.Service.GetBookmarkHTML(input.ID)
+	case protocol.MethodBookmarkThumbnail:
+		var input struct {
+			ID string `json:"id"`
+		}
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		return d.Service.GetThumbnail(input.ID)
 	case protocol.MethodBookmarkDelete:
 		var input struct {
 			ID string `json:"id"`
@@ -125,6 +158,73 @@ func (d *Dispatcher) dispatch(method string, payload json.RawMessage) (any, erro
 			return nil, err
 		}
 		return map[string]any{"ok": true}, d.Service.UpdateNotes(input.ID, input.Notes)
+	case protocol.MethodBookmarkDuplicates:
+		var input struct {
+			TextDistance  int `json:"textDistance"`
+			ImageDistance int `json:"imageDistance"`
+		}
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		return d.Service.FindDuplicates(input.TextDistance, input.ImageDistance)
+	case protocol.MethodBookmarkMerge:
+		var input struct {
+			PrimaryID string   `json:"primaryId"`
+			IDs       []string `json:"ids"`
+		}
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		return d.Service.MergeBookmarks(input.PrimaryID, input.IDs)
+	case protocol.MethodBookmarkSetState:
+		var input struct {
+			ID  string   `json:"id"`
+			IDs []string `json:"ids"`
+			StateUpdate
+		}
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		if input.ID != "" {
+			input.IDs = append(input.IDs, input.ID)
+		}
+		return d.Service.SetBookmarkState(input.IDs, input.StateUpdate)
+	case protocol.MethodBookmarkBulk:
+		var input BulkRequest
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		return d.Service.RunBulk(input)
+	case protocol.MethodBookmarkNotesHist:
+		var input struct {
+			ID    string `json:"id"`
+			Field string `json:"field"`
+		}
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		items, err := d.Service.ListNoteRevisions(input.ID, input.Field)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"items": items}, nil
+	case protocol.MethodBookmarkNotesDiff:
+		var input struct {
+			FromID int64 `json:"fromId"`
+			ToID   int64 `json:"toId"`
+		}
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		return d.Service.DiffNoteRevisions(input.FromID, input.ToID)
+	case protocol.MethodBookmarkRestoreRev:
+		var input struct {
+			RevisionID int64 `json:"revisionId"`
+		}
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		return d.Service.RestoreNoteRevision(input.RevisionID)
 	case protocol.MethodBookmarkDownload:
 		var input struct {
 			ID string `json:"id"`
@@ -141,8 +241,225 @@ func (d *Dispatcher) dispatch(method string, payload json.RawMessage) (any, erro
 			return nil, err
 		}
 		return d.Service.OpenBookmarkFolder(input.ID)
+	case protocol.MethodAnnotationList:
+		var input struct {
+			BookmarkID string `json:"bookmarkId"`
+		}
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		items, err := d.Service.ListAnnotations(input.BookmarkID)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"items": items}, nil
+	case protocol.MethodAnnotationCreate:
+		var input AnnotationInput
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		return d.Service.CreateAnnotation(input)
+	case protocol.MethodAnnotationUpdate:
+		var input struct {
+			ID      string `json:"id"`
+			Comment string `json:"comment"`
+			Color   string `json:"color"`
+		}
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		return d.Service.UpdateAnnotation(input.ID, input.Comment, input.Color)
+	case protocol.MethodAnnotationDelete:
+		var input struct {
+			ID string `json:"id"`
+		}
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		return map[string]any{"ok": true}, d.Service.DeleteAnnotation(input.ID)
+	case protocol.MethodAnnotationSearch:
+		var input struct {
+			Q     string `json:"q"`
+			Limit int    `json:"limit"`
+		}
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		items, err := d.Service.SearchAnnotations(input.Q, input.Limit)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"items": items}, nil
+	case protocol.MethodAnnotationExport:
+		var input struct {
+			BookmarkID string `json:"bookmarkId"`
+			Format     string `json:"format"`
+		}
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		return d.Service.ExportAnnotations(input.BookmarkID, input.Format)
+	case protocol.MethodFaviconGet:
+		var input struct {
+			ID     string `json:"id"`
+			Domain string `json:"domain"`
+			Size   int    `json:"size"`
+		}
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		return d.Service.GetFavicon(input.ID, input.Domain, input.Size)
+	case protocol.MethodFaviconRefresh:
+		var input struct {
+			Domain string `json:"domain"`
+		}
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		return d.Service.RefreshFavicon(input.Domain)
+	case protocol.MethodDomainList:
+		items, err := d.Service.ListDomains()
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"items": items}, nil
+	case protocol.MethodDomainSetName:
+		var input struct {
+			Domain      string `json:"domain"`
+			DisplayName string `json:"displayName"`
+		}
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		return map[string]any{"ok": true}, d.Service.SetDomainName(input.Domain, input.DisplayName)
+	case protocol.MethodDomainTrash:
+		var input struct {
+			Domain string `json:"domain"`
+		}
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		return d.Service.TrashDomain(input.Domain)
+	case protocol.MethodDomainExport:
+		var input struct {
+			Domain string `json:"domain"`
+		}
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		return d.Service.ExportDomain(input.Domain)
+	case protocol.MethodActivityList:
+		var input ActivityQuery
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		items, err := d.Service.ListActivity(input)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"items": items}, nil
+	case protocol.MethodActivityUndo:
+		var input struct {
+			ID int64 `json:"id"`
+		}
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		return d.Service.UndoActivity(input.ID)
+	case protocol.MethodLinkCheckReport:
+		return d.Service.LinkCheckReport()
+	case protocol.MethodLinkCheckRun:
+		var input struct {
+			IDs []string `json:"ids"`
+		}
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		return d.Service.CheckLinks(input.IDs)
+	case protocol.MethodSyncStatus:
+		return d.Service.SyncStatus()
+	case protocol.MethodSyncRun:
+		return d.Service.SyncNow()
+	case protocol.MethodSyncConflicts:
+		return d.Service.ListSyncConflicts()
+	case protocol.MethodSyncResolve:
+		var input struct {
+			ID   int64  `json:"id"`
+			Keep string `json:"keep"`
+		}
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		return map[string]any{"ok": true}, d.Service.ResolveSyncConflict(input.ID, input.Keep)
+	case protocol.MethodWebDAVStatus:
+		return d.Service.WebDAVStatus()
+	case protocol.MethodWebDAVBackup:
+		return d.Service.BackupToWebDAV()
+	case protocol.MethodS3Status:
+		return d.Service.S3Status()
+	case protocol.MethodS3Backup:
+		return d.Service.BackupToS3()
+	case protocol.MethodS3Backups:
+		return d.Service.ListS3Backups()
+	case protocol.MethodS3Restore:
+		var input struct {
+			Name string `json:"name"`
+		}
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		return d.Service.RestoreFromS3(input.Name)
+	case protocol.MethodArchiveStatus:
+		return d.Service.ArchiveServerStatus()
+	case protocol.MethodArchiveConfigure:
+		var input struct {
+			Enabled bool `json:"enabled"`
+			LAN     bool `json:"lan"`
+			Port    int  `json:"port"`
+		}
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		return d.Service.ConfigureArchiveServer(input.Enabled, input.LAN, input.Port)
+	case protocol.MethodArchiveResetToken:
+		return d.Service.ResetArchiveToken()
+	case protocol.MethodLibraryStatus:
+		return d.Service.EncryptionStatus(), nil
+	case protocol.MethodLibraryEncrypt, protocol.MethodLibraryDecrypt, protocol.MethodLibraryUnlock:
+		var input struct {
+			Passphrase string `json:"passphrase"`
+		}
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		switch method {
+		case protocol.MethodLibraryEncrypt:
+			return d.Service.EnableEncryption(input.Passphrase)
+		case protocol.MethodLibraryDecrypt:
+			return d.Service.DisableEncryption(input.Passphrase)
+		}
+		return d.Service.UnlockLibrary(input.Passphrase)
+	case protocol.MethodLibraryLock:
+		return d.Service.LockLibrary(), nil
+	case protocol.MethodLibraryPassphrase:
+		var input struct {
+			OldPassphrase string `json:"oldPassphrase"`
+			NewPassphrase string `json:"newPassphrase"`
+		}
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		return d.Service.ChangePassphrase(input.OldPassphrase, input.NewPassphrase)
+	case protocol.MethodLibraryExportSite:
+		return d.Service.ExportStaticSite()
+	case protocol.MethodLibraryExportFeeds:
+		return d.Service.ExportFeeds()
 	case protocol.MethodTrashList:
-		return d.Service.ListTrash()
+		var input TrashQuery
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		return d.Service.ListTrash(input)
 	case protocol.MethodTrashRestore:
 		var input struct {
 			ID string `json:"id"`
@@ -162,7 +479,21 @@ func (d *Dispatcher) dispatch(method string, payload json.RawMessage) (any, erro
 	case protocol.MethodTrashEmpty:
 		return d.Service.EmptyTrash()
 	case protocol.MethodStatsGet:
-		return d.Service.GetStats(), nil
+		return d.Service.GetStats()
+	case protocol.MethodStatsDetailed:
+		var input struct {
+			Days    int `json:"days"`
+			Weeks   int `json:"weeks"`
+			Largest int `json:"largest"`
+		}
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		return d.Service.GetDetailedStats(input.Days, input.Weeks, input.Largest)
+	case protocol.MethodMaintenanceSizes:
+		return d.Service.RecalculateSizes()
+	case protocol.MethodMaintenanceSanitize:
+		return d.Service.SanitizeLibrary()
 	case protocol.MethodSettingsGet:
 		return d.Service.GetSettings(), nil
 	case protocol.MethodSettingsSetAuto:
@@ -173,6 +504,64 @@ func (d *Dispatcher) dispatch(method string, payload json.RawMessage) (any, erro
 			return nil, err
 		}
 		return d.Service.SetAutoStart(input.Enabled)
+	case protocol.MethodSettingsSetTrash:
+		var input struct {
+			Days int `json:"days"`
+		}
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		return d.Service.SetTrashRetention(input.Days)
+	case protocol.MethodSettingsURLParams:
+		var input struct {
+			Params map[string][]string `json:"params"`
+		}
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		return d.Service.SetURLStripParams(input.Params)
+	case protocol.MethodSettingsRevisions:
+		var input struct {
+			Limit int `json:"limit"`
+		}
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		return d.Service.SetRevisionLimit(input.Limit)
+	case protocol.MethodSettingsLinkCheck:
+		var input struct {
+			Hours int `json:"hours"`
+		}
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		return d.Service.SetLinkCheckInterval(input.Hours)
+	case protocol.MethodSettingsFeed:
+		var input FeedConfig
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		return d.Service.SetFeedConfig(input)
+	case protocol.MethodSettingsSync:
+		var input struct {
+			Folder string `json:"folder"`
+		}
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		return d.Service.SetSyncFolder(input.Folder)
+	case protocol.MethodSettingsWebDAV:
+		var input WebDAVInput
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		return d.Service.SetWebDAV(input)
+	case protocol.MethodSettingsS3:
+		var input S3Input
+		if err := decodePayload(payload, &input); err != nil {
+			return nil, err
+		}
+		return d.Service.SetS3Config(input)
 	case protocol.MethodVersionGet:
 		var input struct {
 			Force bool `json:"force"`
@@ -204,6 +593,8 @@ func errorCode(err error) string {
 	switch {
 	case errors.Is(err, sql.ErrNoRows):
 		return "not_found"
+	case errors.Is(err, ErrLibraryLocked):
+		return "library_locked"
 	case strings.Contains(err.Error(), "协议"):
 		return "protocol_mismatch"
 	default:
@@ -0,0 +1,198 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"chrome-collect-tray/internal/protocol"
+)
+
+type DomainInfo struct {
+	Domain      string `json:"domain"`
+	DisplayName string `json:"displayName"`
+	Count       int    `json:"count"`
+	Size        int64  `json:"size"`
+	LatestAt    int64  `json:"latestAt"`
+	FaviconID   string `json:"faviconId"`
+}
+
+type DomainActionResult struct {
+	Domain   string `json:"domain"`
+	Affected int    `json:"affected"`
+}
+
+type DomainExportResult struct {
+	Path  string `json:"path"`
+	Count int    `json:"count"`
+}
+
+func (s *Service) ListDomains() ([]DomainInfo, error) {
+	rows, err := s.db.Query(`SELECT b.domain, COUNT(*), COALESCE(SUM(b.file_size), 0), MAX(b.created_at),
+		COALESCE(df.favicon_id, MAX(b.favicon_id), ''), COALESCE(dn.display_name, '')
+		FROM bookmarks b
+		LEFT JOIN domain_favicons df ON df.domain = b.domain
+		LEFT JOIN domain_names dn ON dn.domain = b.domain
+		WHERE b.deleted_at = 0
+		GROUP BY b.domain
+		ORDER BY COUNT(*) DESC, b.domain ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []DomainInfo{}
+	for rows.Next() {
+		var item DomainInfo
+		if err := rows.Scan(&item.Domain, &item.Count, &item.Size, &item.LatestAt, &item.FaviconID, &item.DisplayName); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (s *Service) SetDomainName(domain, name string) error {
+	domain, err := cleanDomain(domain)
+	if err != nil {
+		return err
+	}
+	name = strings.TrimSpace(name)
+	return s.journaled(protocol.MethodDomainSetName, "domain", domain, func(tx *sql.Tx) error {
+		if name == "" {
+			_, err := tx.Exec("DELETE FROM domain_names WHERE domain = ?", domain)
+			return err
+		}
+		_, err := tx.Exec(`INSERT INTO domain_names (domain, display_name, updated_at) VALUES (?, ?, ?)
+			ON CONFLICT(domain) DO UPDATE SET display_name = excluded.display_name, updated_at = excluded.updated_at`,
+			domain, name, time.Now().UnixMilli())
+		return err
+	})
+}
+
+func (s *Service) TrashDomain(domain string) (*DomainActionResult, error) {
+	domain, err := cleanDomain(domain)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := s.domainBookmarkIDs(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UnixMilli()
+	for _, id := range ids {
+		err := s.journal(tx, protocol.MethodDomainTrash, "bookmark", id, func() error {
+			_, err := tx.Exec("UPDATE bookmarks SET deleted_at = ? WHERE id = ? AND deleted_at = 0", now, id)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &DomainActionResult{Domain: domain, Affected: len(ids)}, nil
+}
+
+func (s *Service) domainBookmarkIDs(domain string) ([]string, error) {
+	rows, err := s.db.Query("SELECT id FROM bookmarks WHERE domain = ? AND deleted_at = 0", domain)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ExportDomain 将该域名下的全部收藏导出到下载目录中的同名文件夹。
+func (s *Service) ExportDomain(domain string) (*DomainExportResult, error) {
+	domain, err := cleanDomain(domain)
+	if err != nil {
+		return nil, err
+	}
+	// 只需要文件名和页面路径，不经过 queryBookmarks，避免为每条收藏读取缩略图和解密备注。
+	rows, err := s.db.Query("SELECT id, title, alias, file_path FROM bookmarks WHERE deleted_at = 0 AND domain = ? ORDER BY "+defaultOrderBy, domain)
+	if err != nil {
+		return nil, err
+	}
+	type exportRow struct {
+		id, title, alias, filePath string
+	}
+	var items []exportRow
+	for rows.Next() {
+		var item exportRow
+		if err := rows.Scan(&item.id, &item.title, &item.alias, &item.filePath); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		item.title, item.alias = s.revealField(item.title), s.revealField(item.alias)
+		items = append(items, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	baseDir, err := downloadsDir()
+	if err != nil {
+		return nil, err
+	}
+	targetDir := getUniqueFilePath(baseDir, sanitizeFilename(domain, 80), "")
+	if err := os.MkdirAll(targetDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	count := 0
+	for _, item := range items {
+		data, err := s.readArtifact(item.filePath)
+		if errors.Is(err, ErrLibraryLocked) {
+			return nil, err
+		}
+		if err != nil {
+			continue
+		}
+		name := item.alias
+		if name == "" {
+			name = item.title
+		}
+		if name == "" {
+			name = item.id
+		}
+		targetPath := getUniqueFilePath(targetDir, sanitizeFilename(name, 80), ".html")
+		if err := os.WriteFile(targetPath, data, 0o644); err != nil {
+			return nil, err
+		}
+		count++
+	}
+	return &DomainExportResult{Path: filepath.Clean(targetDir), Count: count}, nil
+}
+
+func cleanDomain(domain string) (string, error) {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if domain == "" {
+		return "", errors.New("缺少域名")
+	}
+	return domain, nil
+}
@@ -0,0 +1,78 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDomainListRenameAndTrash(t *testing.T) {
+	s := newTestService(t)
+	saveTestBookmark(t, s, "https://a.example/1", "One", "<p>1</p>")
+	saveTestBookmark(t, s, "https://a.example/2", "Two", "<p>2</p>")
+	saveTestBookmark(t, s, "https://b.example/1", "Other", "<p>3</p>")
+	if err := s.SetDomainName(" A.example ", "Site A"); err != nil {
+		t.Fatal(err)
+	}
+
+	domains, err := s.ListDomains()
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := slices.IndexFunc(domains, func(d DomainInfo) bool { return d.Domain == "a.example" })
+	if index < 0 || domains[index].Count != 2 || domains[index].DisplayName != "Site A" {
+		t.Fatalf("domains = %+v", domains)
+	}
+
+	result, err := s.TrashDomain("a.example")
+	if err != nil || result.Affected != 2 {
+		t.Fatalf("trash domain = %+v, %v", result, err)
+	}
+	if _, err := s.TrashDomain(" "); err == nil {
+		t.Fatal("empty domain accepted")
+	}
+}
+
+func TestExportDomain(t *testing.T) {
+	s := newTestService(t)
+	first := saveTestBookmark(t, s, "https://a.example/1", "Title", "<p>first</p>")
+	if err := s.UpdateAlias(first.ID, "Alias Name"); err != nil {
+		t.Fatal(err)
+	}
+	saveTestBookmark(t, s, "https://a.example/2", "Title", "<p>second</p>")
+	trashed := saveTestBookmark(t, s, "https://a.example/3", "Trashed", "<p>third</p>")
+	if _, err := s.RunBulk(BulkRequest{IDs: []string{trashed.ID}, Action: bulkActionTrash}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := s.ExportDomain("A.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Count != 2 {
+		t.Fatalf("count = %d", result.Count)
+	}
+	entries, err := os.ReadDir(result.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if !slices.Contains(names, "Alias Name.html") || !slices.Contains(names, "Title.html") || len(names) != 2 {
+		t.Fatalf("exported files = %v", names)
+	}
+	data, err := os.ReadFile(filepath.Join(result.Path, "Alias Name.html"))
+	if err != nil || !strings.Contains(string(data), "<p>first</p>") {
+		t.Fatalf("exported page = %q, %v", data, err)
+	}
+
+	if _, err := s.ExportDomain("missing.example"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("unknown domain error = %v", err)
+	}
+}
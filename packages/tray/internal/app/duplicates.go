@@ -0,0 +1,329 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"chrome-collect-tray/internal/protocol"
+)
+
+const (
+	duplicateReasonURL     = "url"
+	duplicateReasonContent = "content"
+	duplicateReasonText    = "text"
+	duplicateReasonImage   = "image"
+
+	defaultTextDistance  = 3
+	defaultImageDistance = 5
+)
+
+// DuplicateGroup 中的收藏不含缩略图数据，只带 thumb_path，缩略图通过 bookmark.getThumbnail 按需获取。
+type DuplicateGroup struct {
+	Reason string     `json:"reason"`
+	Items  []Bookmark `json:"items"`
+}
+
+type DuplicatesResult struct {
+	Groups []DuplicateGroup `json:"groups"`
+}
+
+type fingerprintRow struct {
+	id            string
+	normalizedURL string
+	contentHash   string
+	textSimhash   string
+	thumbHash     string
+}
+
+func (s *Service) FindDuplicates(textDistance, imageDistance int) (*DuplicatesResult, error) {
+	if textDistance <= 0 {
+		textDistance = defaultTextDistance
+	}
+	if imageDistance <= 0 {
+		imageDistance = defaultImageDistance
+	}
+	if err := s.backfillFingerprints(); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`SELECT id, normalized_url, content_hash, text_simhash, thumb_hash
+		FROM bookmarks WHERE deleted_at = 0 ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	var items []fingerprintRow
+	for rows.Next() {
+		var item fingerprintRow
+		if err := rows.Scan(&item.id, &item.normalizedURL, &item.contentHash, &item.textSimhash, &item.thumbHash); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var groups [][]string
+	var reasons []string
+	seen := map[string]bool{}
+	addGroups := func(reason string, found [][]string) {
+		for _, ids := range found {
+			key := strings.Join(sortedCopy(ids), ",")
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			groups = append(groups, ids)
+			reasons = append(reasons, reason)
+		}
+	}
+	addGroups(duplicateReasonURL, exactGroups(items, func(item fingerprintRow) string { return item.normalizedURL }))
+	addGroups(duplicateReasonContent, exactGroups(items, func(item fingerprintRow) string { return item.contentHash }))
+	addGroups(duplicateReasonText, nearGroups(items, func(item fingerprintRow) string { return item.textSimhash }, textDistance))
+	addGroups(duplicateReasonImage, nearGroups(items, func(item fingerprintRow) string { return item.thumbHash }, imageDistance))
+
+	result := &DuplicatesResult{Groups: []DuplicateGroup{}}
+	for index, ids := range groups {
+		group := DuplicateGroup{Reason: reasons[index]}
+		for _, id := range ids {
+			bm, err := s.lookupBookmark(id)
+			if err != nil {
+				return nil, err
+			}
+			if bm != nil {
+				group.Items = append(group.Items, *bm)
+			}
+		}
+		if len(group.Items) > 1 {
+			result.Groups = append(result.Groups, group)
+		}
+	}
+	return result, nil
+}
+
+func exactGroups(items []fingerprintRow, key func(fingerprintRow) string) [][]string {
+	buckets := map[string][]string{}
+	var order []string
+	for _, item := range items {
+		value := key(item)
+		if value == "" {
+			continue
+		}
+		if _, ok := buckets[value]; !ok {
+			order = append(order, value)
+		}
+		buckets[value] = append(buckets[value], item.id)
+	}
+	var groups [][]string
+	for _, value := range order {
+		if len(buckets[value]) > 1 {
+			groups = append(groups, buckets[value])
+		}
+	}
+	return groups
+}
+
+func nearGroups(items []fingerprintRow, key func(fingerprintRow) string, maxDistance int) [][]string {
+	type hashed struct {
+		index int
+		hash  uint64
+	}
+	var candidates []hashed
+	for index, item := range items {
+		if hash, ok := parseHash(key(item)); ok {
+			candidates = append(candidates, hashed{index: index, hash: hash})
+		}
+	}
+
+	parent := make([]int, len(items))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i := 0; i < len(candidates); i++ {
+		for j := i + 1; j < len(candidates); j++ {
+			if hammingDistance(candidates[i].hash, candidates[j].hash) <= maxDistance {
+				a, b := find(candidates[i].index), find(candidates[j].index)
+				if a != b {
+					parent[b] = a
+				}
+			}
+		}
+	}
+
+	clusters := map[int][]string{}
+	var order []int
+	for _, candidate := range candidates {
+		root := find(candidate.index)
+		if _, ok := clusters[root]; !ok {
+			order = append(order, root)
+		}
+		clusters[root] = append(clusters[root], items[candidate.index].id)
+	}
+	var groups [][]string
+	for _, root := range order {
+		if len(clusters[root]) > 1 {
+			groups = append(groups, clusters[root])
+		}
+	}
+	return groups
+}
+
+func (s *Service) backfillFingerprints() error {
+	rows, err := s.db.Query("SELECT id, file_path, thumb_path FROM bookmarks WHERE deleted_at = 0 AND content_hash = ''")
+	if err != nil {
+		return err
+	}
+	type pending struct {
+		id        string
+		filePath  string
+		thumbPath string
+	}
+	var items []pending
+	for rows.Next() {
+		var item pending
+		if err := rows.Scan(&item.id, &item.filePath, &item.thumbPath); err != nil {
+			rows.Close()
+			return err
+		}
+		items = append(items, item)
+	}
+	rows.Close()
+
+	for _, item := range items {
+		htmlData, err := s.readArtifact(item.filePath)
+		if err != nil {
+			continue
+		}
+		var thumbData []byte
+		if item.thumbPath != "" {
+			thumbData, _ = s.readArtifact(item.thumbPath)
+		}
+		fp := computeFingerprint(htmlData, thumbData)
+		if _, err := s.db.Exec("UPDATE bookmarks SET content_hash = ?, text_simhash = ?, thumb_hash = ? WHERE id = ?",
+			fp.ContentHash, fp.TextSimhash, fp.ThumbHash, item.id); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *Service) MergeBookmarks(primaryID string, ids []string) (*Bookmark, error) {
+	if primaryID == "" || len(ids) == 0 {
+		return nil, errors.New("缺少合并目标")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	primary, err := scanBookmark(tx.QueryRow("SELECT "+bookmarkColumns+" FROM bookmarks WHERE id = ? AND deleted_at = 0", primaryID))
+	if err != nil {
+		return nil, err
+	}
+
+	notes, err := s.openField(primary.Notes)
+	if err != nil {
+		return nil, err
+	}
+	alias := primary.Alias
+	tags := parseTags(primary.Tags)
+	now := time.Now().UnixMilli()
+	merged := map[string]bool{primaryID: true}
+	for _, id := range ids {
+		if merged[id] {
+			continue
+		}
+		merged[id] = true
+		other, err := scanBookmark(tx.QueryRow("SELECT "+bookmarkColumns+" FROM bookmarks WHERE id = ? AND deleted_at = 0", id))
+		if err != nil {
+			return nil, err
+		}
+		otherNotes, err := s.openField(other.Notes)
+		if err != nil {
+			return nil, err
+		}
+		if trimmed := strings.TrimSpace(otherNotes); trimmed != "" && !strings.Contains(notes, trimmed) {
+			if strings.TrimSpace(notes) != "" {
+				notes += "\n\n"
+			}
+			notes += trimmed
+		}
+		if alias == "" {
+			alias = other.Alias
+		}
+		tags = mergeTags(tags, parseTags(other.Tags))
+		err = s.journal(tx, protocol.MethodBookmarkMerge, "bookmark", id, func() error {
+			_, err := tx.Exec("UPDATE bookmarks SET deleted_at = ? WHERE id = ?", now, id)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if notes, err = s.sealField(notes); err != nil {
+		return nil, err
+	}
+	err = s.journal(tx, protocol.MethodBookmarkMerge, "bookmark", primaryID, func() error {
+		if err := s.recordRevision(tx, primaryID, "notes", notes); err != nil {
+			return err
+		}
+		if err := s.recordRevision(tx, primaryID, "alias", alias); err != nil {
+			return err
+		}
+		_, err := tx.Exec("UPDATE bookmarks SET notes = ?, alias = ?, tags = ? WHERE id = ?", notes, alias, encodeTags(tags), primaryID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.GetBookmark(primaryID)
+}
+
+func mergeTags(base, extra []string) []string {
+	seen := map[string]bool{}
+	for _, tag := range base {
+		seen[tag] = true
+	}
+	for _, tag := range extra {
+		if !seen[tag] {
+			seen[tag] = true
+			base = append(base, tag)
+		}
+	}
+	return base
+}
+
+func encodeTags(tags []string) string {
+	if len(tags) == 0 {
+		return "[]"
+	}
+	raw, err := json.Marshal(tags)
+	if err != nil {
+		return "[]"
+	}
+	return string(raw)
+}
+
+func sortedCopy(values []string) []string {
+	copied := append([]string{}, values...)
+	sort.Strings(copied)
+	return copied
+}
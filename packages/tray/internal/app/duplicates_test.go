@@ -0,0 +1,205 @@
+package app
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func testArticle(prefix string, words int) string {
+	var b strings.Builder
+	for i := range words {
+		fmt.Fprintf(&b, "%s%d ", prefix, i)
+	}
+	return b.String()
+}
+
+// testGradientScreenshot 生成水平渐变截图，patch 为真时在左上角加一小块色块，模拟轻微的页面差异。
+func testGradientScreenshot(t *testing.T, reverse, patch bool) string {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, 180, 120))
+	for y := range 120 {
+		for x := range 180 {
+			v := uint8(x * 255 / 179)
+			if reverse {
+				v = 255 - v
+			}
+			if patch && x < 6 && y < 6 {
+				v = 255
+			}
+			img.SetGray(x, y, color.Gray{Y: v})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func groupIDs(result *DuplicatesResult, reason string) [][]string {
+	var groups [][]string
+	for _, group := range result.Groups {
+		if group.Reason != reason {
+			continue
+		}
+		var ids []string
+		for _, item := range group.Items {
+			ids = append(ids, item.ID)
+		}
+		slices.Sort(ids)
+		groups = append(groups, ids)
+	}
+	return groups
+}
+
+func TestFindDuplicatesOmitsThumbnailData(t *testing.T) {
+	s := newTestService(t)
+	a := saveTestBookmark(t, s, "https://example.com/a", "A", "<p>same page</p>")
+	b := saveTestBookmark(t, s, "https://example.com/a", "A again", "<p>same page</p>")
+	saveTestBookmark(t, s, "https://example.com/other", "Other", "<p>something else entirely</p>")
+
+	result, err := s.FindDuplicates(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Groups) != 1 || result.Groups[0].Reason != duplicateReasonURL || len(result.Groups[0].Items) != 2 {
+		t.Fatalf("groups = %+v", result.Groups)
+	}
+	for _, item := range result.Groups[0].Items {
+		if item.ID != a.ID && item.ID != b.ID {
+			t.Fatalf("unexpected member %s", item.ID)
+		}
+		if item.ThumbData != "" || item.ThumbPath == "" {
+			t.Fatalf("duplicate item should carry only the thumbnail path: %+v", item)
+		}
+	}
+
+	thumb, err := s.GetThumbnail(a.ID)
+	if err != nil || thumb.ID != a.ID || thumb.DataURL != testScreenshot {
+		t.Fatalf("thumbnail = %+v, %v", thumb, err)
+	}
+	if _, err := s.GetThumbnail("missing"); err == nil {
+		t.Fatal("thumbnail returned for a missing bookmark")
+	}
+}
+
+func TestMergeBookmarks(t *testing.T) {
+	s := newTestService(t)
+	primary := saveTestBookmark(t, s, "https://example.com/a", "A", "<p>a</p>")
+	other := saveTestBookmark(t, s, "https://example.com/a", "A", "<p>a</p>")
+	if err := s.UpdateNotes(primary.ID, "first"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateNotes(other.ID, "second"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateAlias(other.ID, "nickname"); err != nil {
+		t.Fatal(err)
+	}
+
+	merged, err := s.MergeBookmarks(primary.ID, []string{other.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.Alias != "nickname" || !strings.Contains(merged.Notes, "first") || !strings.Contains(merged.Notes, "second") {
+		t.Fatalf("merged = %+v", merged)
+	}
+	if got, _ := s.GetBookmark(other.ID); got == nil || got.DeletedAt == 0 {
+		t.Fatal("merged duplicate not moved to trash")
+	}
+}
+
+func TestFindDuplicatesNearText(t *testing.T) {
+	s := newTestService(t)
+	article := testArticle("word", 300)
+	edited := strings.Replace(article, "word150 ", "typo ", 1)
+	if edited == article {
+		t.Fatal("fixture did not change")
+	}
+	a := saveTestBookmark(t, s, "https://a.example/post", "A", "<article>"+article+"</article>")
+	b := saveTestBookmark(t, s, "https://b.example/mirror", "B", "<div><p>"+edited+"</p><script>tracker()</script></div>")
+	saveTestBookmark(t, s, "https://c.example/other", "C", "<p>"+testArticle("term", 300)+"</p>")
+
+	result, err := s.FindDuplicates(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{a.ID, b.ID}
+	slices.Sort(want)
+	if got := groupIDs(result, duplicateReasonText); len(got) != 1 || !slices.Equal(got[0], want) || len(result.Groups) != 1 {
+		t.Fatalf("groups = %+v", result.Groups)
+	}
+
+	textHash := func(document string) uint64 {
+		hash, ok := parseHash(computeFingerprint([]byte(document), nil).TextSimhash)
+		if !ok {
+			t.Fatal("text not hashed")
+		}
+		return hash
+	}
+	if d := hammingDistance(textHash(article), textHash(testArticle("term", 300))); d <= defaultTextDistance {
+		t.Fatalf("unrelated articles at distance %d", d)
+	}
+}
+
+func TestFindDuplicatesNearScreenshot(t *testing.T) {
+	s := newTestService(t)
+	save := func(url, body, screenshot string) *Bookmark {
+		bm, err := s.SaveBookmark(SaveInput{URL: url, Title: url, HTML: "<p>" + body + "</p>", Screenshot: screenshot})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bm
+	}
+	a := save("https://a.example/", "alpha page", testGradientScreenshot(t, false, false))
+	b := save("https://b.example/", "beta site", testGradientScreenshot(t, false, true))
+	save("https://c.example/", "gamma other", testGradientScreenshot(t, true, false))
+
+	// 清空指纹，验证查找前会先补算旧收藏的指纹。
+	if _, err := s.db.Exec("UPDATE bookmarks SET content_hash = '', text_simhash = '', thumb_hash = ''"); err != nil {
+		t.Fatal(err)
+	}
+	result, err := s.FindDuplicates(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{a.ID, b.ID}
+	slices.Sort(want)
+	if got := groupIDs(result, duplicateReasonImage); len(got) != 1 || !slices.Equal(got[0], want) || len(result.Groups) != 1 {
+		t.Fatalf("groups = %+v", result.Groups)
+	}
+}
+
+func TestDifferenceHash(t *testing.T) {
+	decode := func(dataURL string) []byte {
+		data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(dataURL, "data:image/png;base64,"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	plain, ok := differenceHash(decode(testGradientScreenshot(t, false, false)))
+	if !ok {
+		t.Fatal("gradient not hashed")
+	}
+	patched, _ := differenceHash(decode(testGradientScreenshot(t, false, true)))
+	reversed, _ := differenceHash(decode(testGradientScreenshot(t, true, false)))
+	if d := hammingDistance(plain, patched); d > defaultImageDistance {
+		t.Fatalf("slightly changed screenshot at distance %d", d)
+	}
+	if d := hammingDistance(plain, reversed); d <= defaultImageDistance {
+		t.Fatalf("different screenshot at distance %d", d)
+	}
+	for _, data := range [][]byte{nil, []byte("not an image"), testPNG(t, 8, 8)} {
+		if _, ok := differenceHash(data); ok {
+			t.Fatalf("hash computed for %d bytes of unusable data", len(data))
+		}
+	}
+}
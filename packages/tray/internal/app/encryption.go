@@ -0,0 +1,672 @@
+package app
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	metaEncryption = "encryption"
+
+	sealedMagic       = "CCE1"
+	sealedFieldPrefix = "enc:v1:"
+	sealInfo          = "chrome-collect file key"
+	sealedPagesDir    = "vault"
+
+	argonTime    = 3
+	argonMemory  = 64 * 1024
+	argonThreads = 4
+	minPassLen   = 8
+)
+
+var ErrLibraryLocked = errors.New("资料库已锁定，请先解锁")
+
+type EncryptionStatus struct {
+	Enabled  bool `json:"enabled"`
+	Unlocked bool `json:"unlocked"`
+	// Pending 表示启用加密时的转换没有完成，下次解锁或重新启用时会继续。
+	Pending bool `json:"pending,omitempty"`
+}
+
+// encryptionConfig 保存在 app_meta 中。私钥用口令派生的密钥包裹，公钥明文保存，
+// 因此未解锁的进程（例如扩展的 Native Host）仍然可以加密写入新的收藏。
+type encryptionConfig struct {
+	Version    int    `json:"version"`
+	Salt       []byte `json:"salt"`
+	Time       uint32 `json:"time"`
+	Memory     uint32 `json:"memory"`
+	Threads    uint8  `json:"threads"`
+	PublicKey  []byte `json:"publicKey"`
+	WrappedKey []byte `json:"wrappedKey"`
+	Pending    bool   `json:"pending,omitempty"`
+}
+
+func (s *Service) EncryptionStatus() EncryptionStatus {
+	cfg, _ := s.encryptionConfig()
+	s.vaultMu.RLock()
+	defer s.vaultMu.RUnlock()
+	return EncryptionStatus{Enabled: cfg != nil, Unlocked: cfg != nil && s.vaultKey != nil, Pending: cfg != nil && cfg.Pending}
+}
+
+func (s *Service) EnableEncryption(passphrase string) (EncryptionStatus, error) {
+	if len([]rune(passphrase)) < minPassLen {
+		return EncryptionStatus{}, errors.New("口令至少需要 8 个字符")
+	}
+	if cfg, err := s.encryptionConfig(); err != nil {
+		return EncryptionStatus{}, err
+	} else if cfg != nil && cfg.Pending {
+		// 上次启用时转换中途失败：用同一口令重试即可继续
+		return s.UnlockLibrary(passphrase)
+	} else if cfg != nil {
+		return EncryptionStatus{}, errors.New("资料库已启用加密")
+	}
+
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return EncryptionStatus{}, err
+	}
+	cfg := &encryptionConfig{
+		Version:   1,
+		Salt:      randomBytes(16),
+		Time:      argonTime,
+		Memory:    argonMemory,
+		Threads:   argonThreads,
+		PublicKey: key.PublicKey().Bytes(),
+		Pending:   true,
+	}
+	if cfg.WrappedKey, err = sealWithKey(cfg.passphraseKey(passphrase), key.Bytes()); err != nil {
+		return EncryptionStatus{}, err
+	}
+	if err := s.saveEncryptionConfig(cfg); err != nil {
+		return EncryptionStatus{}, err
+	}
+
+	s.vaultMu.Lock()
+	s.vaultKey = key
+	s.vaultMu.Unlock()
+
+	if err := s.finishEncryption(cfg); err != nil {
+		return EncryptionStatus{}, err
+	}
+	return s.EncryptionStatus(), nil
+}
+
+func (s *Service) DisableEncryption(passphrase string) (EncryptionStatus, error) {
+	if _, err := s.unlock(passphrase); err != nil {
+		return EncryptionStatus{}, err
+	}
+	if err := s.convertLibrary(false); err != nil {
+		return EncryptionStatus{}, err
+	}
+	if _, err := s.db.Exec("DELETE FROM app_meta WHERE key = ?", metaEncryption); err != nil {
+		return EncryptionStatus{}, err
+	}
+	s.LockLibrary()
+	return s.EncryptionStatus(), nil
+}
+
+func (s *Service) UnlockLibrary(passphrase string) (EncryptionStatus, error) {
+	cfg, err := s.unlock(passphrase)
+	if err != nil {
+		return EncryptionStatus{}, err
+	}
+	if cfg.Pending {
+		if err := s.finishEncryption(cfg); err != nil {
+			return EncryptionStatus{}, err
+		}
+	}
+	return s.EncryptionStatus(), nil
+}
+
+func (s *Service) unlock(passphrase string) (*encryptionConfig, error) {
+	cfg, err := s.encryptionConfig()
+	if err != nil {
+		return nil, err
+	}
+	if cfg == nil {
+		return nil, errors.New("资料库未启用加密")
+	}
+	key, err := cfg.unwrap(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	s.vaultMu.Lock()
+	s.vaultKey = key
+	s.vaultMu.Unlock()
+	return cfg, nil
+}
+
+// finishEncryption 转换已有数据，全部成功后才清除 Pending，中途失败时配置仍然标记为未完成。
+func (s *Service) finishEncryption(cfg *encryptionConfig) error {
+	if err := s.convertLibrary(true); err != nil {
+		return err
+	}
+	cfg.Pending = false
+	return s.saveEncryptionConfig(cfg)
+}
+
+func (s *Service) LockLibrary() EncryptionStatus {
+	s.vaultMu.Lock()
+	s.vaultKey = nil
+	s.vaultMu.Unlock()
+	return s.EncryptionStatus()
+}
+
+func (s *Service) ChangePassphrase(oldPassphrase, newPassphrase string) (EncryptionStatus, error) {
+	if len([]rune(newPassphrase)) < minPassLen {
+		return EncryptionStatus{}, errors.New("口令至少需要 8 个字符")
+	}
+	cfg, err := s.encryptionConfig()
+	if err != nil {
+		return EncryptionStatus{}, err
+	}
+	if cfg == nil {
+		return EncryptionStatus{}, errors.New("资料库未启用加密")
+	}
+	key, err := cfg.unwrap(oldPassphrase)
+	if err != nil {
+		return EncryptionStatus{}, err
+	}
+	cfg.Salt = randomBytes(16)
+	if cfg.WrappedKey, err = sealWithKey(cfg.passphraseKey(newPassphrase), key.Bytes()); err != nil {
+		return EncryptionStatus{}, err
+	}
+	if err := s.saveEncryptionConfig(cfg); err != nil {
+		return EncryptionStatus{}, err
+	}
+	s.vaultMu.Lock()
+	s.vaultKey = key
+	s.vaultMu.Unlock()
+	return s.EncryptionStatus(), nil
+}
+
+func (s *Service) encryptionConfig() (*encryptionConfig, error) {
+	raw, err := s.getMeta(metaEncryption)
+	if err != nil || raw == "" {
+		return nil, err
+	}
+	var cfg encryptionConfig
+	if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func (s *Service) saveEncryptionConfig(cfg *encryptionConfig) error {
+	raw, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	return s.setMeta(metaEncryption, string(raw))
+}
+
+func (c *encryptionConfig) passphraseKey(passphrase string) []byte {
+	return argon2.IDKey([]byte(passphrase), c.Salt, c.Time, c.Memory, c.Threads, 32)
+}
+
+func (c *encryptionConfig) unwrap(passphrase string) (*ecdh.PrivateKey, error) {
+	raw, err := openWithKey(c.passphraseKey(passphrase), c.WrappedKey)
+	if err != nil {
+		return nil, errors.New("口令错误")
+	}
+	return ecdh.X25519().NewPrivateKey(raw)
+}
+
+// readArtifact 读取收藏的 HTML 或截图，已加密的文件在解锁后透明解密。
+func (s *Service) readArtifact(relative string) ([]byte, error) {
+	data, err := os.ReadFile(getAbsoluteFilePath(s.dataDir, relative))
+	if err != nil || !isSealed(data) {
+		return data, err
+	}
+	return s.openSealed(data)
+}
+
+// writeArtifact 在启用加密时用公钥加密写入，不需要解锁。
+func (s *Service) writeArtifact(path string, data []byte) error {
+	sealed, err := s.sealIfEnabled(data)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, sealed)
+}
+
+func (s *Service) sealIfEnabled(data []byte) ([]byte, error) {
+	cfg, err := s.encryptionConfig()
+	if err != nil || cfg == nil {
+		return data, err
+	}
+	recipient, err := ecdh.X25519().NewPublicKey(cfg.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return sealFor(recipient, data)
+}
+
+func (s *Service) openSealed(data []byte) ([]byte, error) {
+	s.vaultMu.RLock()
+	key := s.vaultKey
+	s.vaultMu.RUnlock()
+	if key == nil {
+		return nil, ErrLibraryLocked
+	}
+	return openFor(key, data)
+}
+
+// sealField 加密写入数据库的文本字段（标题、别名、备注等），空字符串保持为空便于筛选。
+func (s *Service) sealField(value string) (string, error) {
+	if value == "" || strings.HasPrefix(value, sealedFieldPrefix) {
+		return value, nil
+	}
+	sealed, err := s.sealIfEnabled([]byte(value))
+	if err != nil || !isSealed(sealed) {
+		return value, err
+	}
+	return sealedFieldPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (s *Service) openField(value string) (string, error) {
+	if !strings.HasPrefix(value, sealedFieldPrefix) {
+		return value, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, sealedFieldPrefix))
+	if err != nil {
+		return "", err
+	}
+	plain, err := s.openSealed(data)
+	return string(plain), err
+}
+
+// revealBookmark 解密标题、别名和备注；未解锁时清空并标记，避免把密文展示给用户。
+func (s *Service) revealBookmark(bm *Bookmark) {
+	if bm == nil {
+		return
+	}
+	for _, field := range []struct {
+		value  *string
+		locked *bool
+	}{{&bm.Title, &bm.TitleLocked}, {&bm.Alias, &bm.TitleLocked}, {&bm.Notes, &bm.NotesLocked}} {
+		plain, err := s.openField(*field.value)
+		if err != nil {
+			plain = ""
+			*field.locked = true
+		}
+		*field.value = plain
+	}
+}
+
+// revealField 用于只做展示的场合，无法解密时返回空字符串。
+func (s *Service) revealField(value string) string {
+	plain, err := s.openField(value)
+	if err != nil {
+		return ""
+	}
+	return plain
+}
+
+// sealedSnapshotFields 是收藏和标注中以密文保存的文本列，操作记录快照里的同名字段也一并处理。
+var sealedSnapshotFields = []string{"title", "alias", "notes", "exact", "comment"}
+
+// convertLibrary 加密或解密已有的文件、标题、备注和历史记录；可重复执行，已处理的条目会被跳过。
+func (s *Service) convertLibrary(encrypt bool) error {
+	rows, err := s.db.Query("SELECT id, file_path, thumb_path, title, alias, notes FROM bookmarks")
+	if err != nil {
+		return err
+	}
+	type item struct {
+		id, filePath, thumbPath string
+		fields                  [3]string
+	}
+	var items []item
+	for rows.Next() {
+		var it item
+		if err := rows.Scan(&it.id, &it.filePath, &it.thumbPath, &it.fields[0], &it.fields[1], &it.fields[2]); err != nil {
+			rows.Close()
+			return err
+		}
+		items = append(items, it)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, it := range items {
+		for _, relative := range []string{it.filePath, it.thumbPath} {
+			if relative == "" {
+				continue
+			}
+			if err := s.convertArtifact(relative, encrypt); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if encrypt {
+			if err := s.moveToVault(it.id, it.filePath, it.thumbPath); err != nil {
+				return err
+			}
+		}
+		var converted [3]string
+		for i, value := range it.fields {
+			if converted[i], err = s.convertField(value, encrypt); err != nil {
+				return err
+			}
+		}
+		if converted != it.fields {
+			if _, err := s.db.Exec("UPDATE bookmarks SET title = ?, alias = ?, notes = ? WHERE id = ?",
+				converted[0], converted[1], converted[2], it.id); err != nil {
+				return err
+			}
+		}
+	}
+
+	entries, err := os.ReadDir(s.faviconDir())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			if err := s.convertArtifact(faviconDirName+"/"+entry.Name(), encrypt); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := s.convertColumn("note_revisions", "content", "1", encrypt); err != nil {
+		return err
+	}
+	for _, column := range []string{"exact", "prefix", "suffix", "comment"} {
+		if err := s.convertColumn("annotations", column, "1", encrypt); err != nil {
+			return err
+		}
+	}
+	return s.convertActivitySnapshots(encrypt)
+}
+
+// moveToVault 把按域名和标题命名的文件改为 vault/<id>，关闭加密时不会改回原来的文件名。
+func (s *Service) moveToVault(id, filePath, thumbPath string) error {
+	vault := filepath.Join(s.dataDir, sealedPagesDir)
+	moved := map[string]string{}
+	for _, relative := range []string{filePath, thumbPath} {
+		if relative == "" || strings.HasPrefix(relative, sealedPagesDir+"/") {
+			continue
+		}
+		if err := os.MkdirAll(vault, 0o755); err != nil {
+			return err
+		}
+		target := getUniqueFilePath(vault, id, filepath.Ext(relative))
+		source := getAbsoluteFilePath(s.dataDir, relative)
+		if err := os.Rename(source, target); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		moved[relative] = toRelativePath(s.dataDir, target)
+		// 空的域名目录同样会透露访问过的网站，顺手删掉；目录非空时 Remove 会失败，忽略即可。
+		if dir := filepath.Dir(source); dir != filepath.Join(s.dataDir, "pages") {
+			_ = os.Remove(dir)
+		}
+	}
+	if len(moved) == 0 {
+		return nil
+	}
+	newFile, newThumb := filePath, thumbPath
+	if target, ok := moved[filePath]; ok {
+		newFile = target
+	}
+	if target, ok := moved[thumbPath]; ok {
+		newThumb = target
+	}
+	_, err := s.db.Exec("UPDATE bookmarks SET file_path = ?, thumb_path = ? WHERE id = ?", newFile, newThumb, id)
+	return err
+}
+
+func (s *Service) convertArtifact(relative string, encrypt bool) error {
+	path := getAbsoluteFilePath(s.dataDir, relative)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if isSealed(data) == encrypt {
+		return nil
+	}
+	if encrypt {
+		data, err = s.sealIfEnabled(data)
+	} else {
+		data, err = s.openSealed(data)
+	}
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+func (s *Service) convertField(value string, encrypt bool) (string, error) {
+	if encrypt {
+		return s.sealField(value)
+	}
+	return s.openField(value)
+}
+
+func (s *Service) convertColumn(table, column, where string, encrypt bool) error {
+	rows, err := s.db.Query("SELECT rowid, " + column + " FROM " + table + " WHERE " + where)
+	if err != nil {
+		return err
+	}
+	updates := map[int64]string{}
+	for rows.Next() {
+		var rowID int64
+		var value string
+		if err := rows.Scan(&rowID, &value); err != nil {
+			rows.Close()
+			return err
+		}
+		converted, err := s.convertField(value, encrypt)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		if converted != value {
+			updates[rowID] = converted
+		}
+	}
+	rows.Close()
+	for rowID, value := range updates {
+		if _, err := s.db.Exec("UPDATE "+table+" SET "+column+" = ? WHERE rowid = ?", value, rowID); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// convertActivitySnapshots 处理操作记录快照中的标题、备注和标注，避免历史记录里残留明文。
+func (s *Service) convertActivitySnapshots(encrypt bool) error {
+	rows, err := s.db.Query(`SELECT id, before_json, after_json FROM activity_log
+		WHERE target_type IN ('bookmark', 'annotation') AND (before_json != '' OR after_json != '')`)
+	if err != nil {
+		return err
+	}
+	type pending struct {
+		id            int64
+		before, after string
+	}
+	var updates []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.before, &p.after); err != nil {
+			rows.Close()
+			return err
+		}
+		before, err := s.convertSnapshot(p.before, encrypt)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		after, err := s.convertSnapshot(p.after, encrypt)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		if before != p.before || after != p.after {
+			updates = append(updates, pending{id: p.id, before: before, after: after})
+		}
+	}
+	rows.Close()
+	for _, p := range updates {
+		if _, err := s.db.Exec("UPDATE activity_log SET before_json = ?, after_json = ? WHERE id = ?", p.before, p.after, p.id); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *Service) convertSnapshot(raw string, encrypt bool) (string, error) {
+	snapshot, err := unmarshalSnapshot(raw)
+	if err != nil || snapshot == nil {
+		return raw, err
+	}
+	changed := false
+	for _, field := range sealedSnapshotFields {
+		value, ok := snapshot[field].(string)
+		if !ok {
+			continue
+		}
+		converted, err := s.convertField(value, encrypt)
+		if err != nil {
+			return raw, err
+		}
+		if converted != value {
+			snapshot[field] = converted
+			changed = true
+		}
+	}
+	if !changed {
+		return raw, nil
+	}
+	return marshalSnapshot(snapshot)
+}
+
+// sealFor 使用临时 X25519 密钥与收件公钥协商出每个文件独立的 AES-256-GCM 密钥。
+// 格式：magic | 临时公钥(32) | nonce(12) | 密文。
+func sealFor(recipient *ecdh.PublicKey, plaintext []byte) ([]byte, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return nil, err
+	}
+	key, err := deriveFileKey(shared, ephemeral.PublicKey().Bytes(), recipient.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := sealWithKey(key, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(sealedMagic)+32+len(sealed))
+	out = append(out, sealedMagic...)
+	out = append(out, ephemeral.PublicKey().Bytes()...)
+	return append(out, sealed...), nil
+}
+
+func openFor(key *ecdh.PrivateKey, data []byte) ([]byte, error) {
+	if !isSealed(data) || len(data) < len(sealedMagic)+32 {
+		return nil, errors.New("无效的加密数据")
+	}
+	body := data[len(sealedMagic):]
+	ephemeral, err := ecdh.X25519().NewPublicKey(body[:32])
+	if err != nil {
+		return nil, err
+	}
+	shared, err := key.ECDH(ephemeral)
+	if err != nil {
+		return nil, err
+	}
+	fileKey, err := deriveFileKey(shared, body[:32], key.PublicKey().Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return openWithKey(fileKey, body[32:])
+}
+
+func deriveFileKey(shared, ephemeral, recipient []byte) ([]byte, error) {
+	salt := append(append([]byte{}, ephemeral...), recipient...)
+	return hkdf.Key(sha256.New, shared, salt, sealInfo, 32)
+}
+
+func sealWithKey(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := randomBytes(gcm.NonceSize())
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func openWithKey(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("无效的加密数据")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func isSealed(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(sealedMagic))
+}
+
+func randomBytes(n int) []byte {
+	buf := make([]byte, n)
+	_, _ = rand.Read(buf)
+	return buf
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
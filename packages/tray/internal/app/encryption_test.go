@@ -0,0 +1,269 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testPassphrase = "correct horse battery"
+
+func TestSealWithKeyRoundTrip(t *testing.T) {
+	key := randomBytes(32)
+	sealed, err := sealWithKey(key, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, []byte("secret")) {
+		t.Fatal("plaintext visible in sealed data")
+	}
+	plain, err := openWithKey(key, sealed)
+	if err != nil || string(plain) != "secret" {
+		t.Fatalf("open = %q, %v", plain, err)
+	}
+	sealed[len(sealed)-1] ^= 1
+	if _, err := openWithKey(key, sealed); err == nil {
+		t.Fatal("tampered data accepted")
+	}
+	if _, err := openWithKey(randomBytes(32), sealed); err == nil {
+		t.Fatal("wrong key accepted")
+	}
+}
+
+func TestEncryptionSealsFilesAndHidesNames(t *testing.T) {
+	s := newTestService(t)
+	before, err := s.SaveBookmark(SaveInput{URL: "https://secret.example/page", Title: "Private Title",
+		HTML: "<p>page body</p>", Screenshot: testScreenshot, Favicon: testScreenshot})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateNotes(before.ID, "private note"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.EnableEncryption(testPassphrase); err != nil {
+		t.Fatal(err)
+	}
+	after := saveTestBookmark(t, s, "https://other.example/x", "Another Title", "<p>second</p>")
+
+	for _, id := range []string{before.ID, after.ID} {
+		bm, err := s.GetBookmark(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, relative := range []string{bm.FilePath, bm.ThumbPath} {
+			if !strings.HasPrefix(relative, sealedPagesDir+"/"+id) {
+				t.Fatalf("file %q not stored under an opaque name", relative)
+			}
+			raw, err := os.ReadFile(getAbsoluteFilePath(s.dataDir, relative))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !isSealed(raw) {
+				t.Fatalf("%s stored in plaintext", relative)
+			}
+		}
+	}
+	if _, err := os.Stat(filepath.Join(s.dataDir, "pages", "secret.example")); !os.IsNotExist(err) {
+		t.Fatalf("domain directory left behind: %v", err)
+	}
+
+	favicons, err := os.ReadDir(s.faviconDir())
+	if err != nil || len(favicons) == 0 {
+		t.Fatalf("favicons = %v, %v", favicons, err)
+	}
+	for _, entry := range favicons {
+		raw, _ := os.ReadFile(filepath.Join(s.faviconDir(), entry.Name()))
+		if !isSealed(raw) {
+			t.Fatalf("favicon %s stored in plaintext", entry.Name())
+		}
+	}
+	if _, err := s.GetFavicon("", "secret.example", 0); err != nil {
+		t.Fatalf("favicon not readable while unlocked: %v", err)
+	}
+
+	s.LockLibrary()
+	if _, err := s.GetFavicon("", "secret.example", 0); !errors.Is(err, ErrLibraryLocked) {
+		t.Fatalf("locked favicon error = %v", err)
+	}
+	if _, err := s.UnlockLibrary(testPassphrase); err != nil {
+		t.Fatal(err)
+	}
+	content, err := s.GetBookmarkHTML(before.ID)
+	if err != nil || !strings.Contains(content.HTML, "page body") {
+		t.Fatalf("page after unlock = %v, %v", content, err)
+	}
+
+	if _, err := s.DisableEncryption(testPassphrase); err != nil {
+		t.Fatal(err)
+	}
+	bm, _ := s.GetBookmark(before.ID)
+	raw, err := os.ReadFile(getAbsoluteFilePath(s.dataDir, bm.FilePath))
+	if err != nil || isSealed(raw) || !strings.Contains(string(raw), "page body") {
+		t.Fatalf("page not decrypted after disabling: %v", err)
+	}
+}
+
+func TestSearchSkipsSealedFields(t *testing.T) {
+	s := newTestService(t)
+	plain := saveTestBookmark(t, s, "https://example.com/plain", "Hidden Words", "<p>a</p>")
+	if err := s.UpdateNotes(plain.ID, "find me"); err != nil {
+		t.Fatal(err)
+	}
+	search := func(q string) []string {
+		t.Helper()
+		where, args := s.bookmarkWhere("deleted_at = 0", q, "", BookmarkFilter{})
+		items, err := s.queryBookmarks(where, args, defaultOrderBy, 10, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, item := range items {
+			ids = append(ids, item.ID)
+		}
+		return ids
+	}
+	if got := search("find me"); len(got) != 1 {
+		t.Fatalf("plaintext notes search = %v", got)
+	}
+
+	if _, err := s.EnableEncryption(testPassphrase); err != nil {
+		t.Fatal(err)
+	}
+	if got := search("find me"); len(got) != 0 {
+		t.Fatalf("sealed notes matched: %v", got)
+	}
+	if got := search(sealedFieldPrefix); len(got) != 0 {
+		t.Fatalf("ciphertext prefix matched: %v", got)
+	}
+	if got := search("Hidden Words"); len(got) != 0 {
+		t.Fatalf("sealed title matched: %v", got)
+	}
+	if got := search("example.com/plain"); len(got) != 1 {
+		t.Fatalf("url search = %v", got)
+	}
+}
+
+func TestEncryptionSealsTitleAndAlias(t *testing.T) {
+	s := newTestService(t)
+	bm := saveTestBookmark(t, s, "https://example.com/a", "Private Title", "<p>a</p>")
+	if err := s.UpdateAlias(bm.ID, "secret alias"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.EnableEncryption(testPassphrase); err != nil {
+		t.Fatal(err)
+	}
+	locked := saveTestBookmark(t, s, "https://example.com/b", "Saved While Enabled", "<p>b</p>")
+
+	leaks := func() []string {
+		t.Helper()
+		var found []string
+		for _, query := range []string{
+			"SELECT title || alias FROM bookmarks",
+			"SELECT content FROM note_revisions",
+			"SELECT before_json || after_json FROM activity_log",
+		} {
+			rows, err := s.db.Query(query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for rows.Next() {
+				var value string
+				if err := rows.Scan(&value); err != nil {
+					t.Fatal(err)
+				}
+				for _, plain := range []string{"Private Title", "secret alias", "Saved While Enabled"} {
+					if strings.Contains(value, plain) {
+						found = append(found, plain)
+					}
+				}
+			}
+			rows.Close()
+		}
+		return found
+	}
+	if got := leaks(); len(got) != 0 {
+		t.Fatalf("plaintext left in database: %v", got)
+	}
+
+	got, err := s.GetBookmark(bm.ID)
+	if err != nil || got.Title != "Private Title" || got.Alias != "secret alias" || got.TitleLocked {
+		t.Fatalf("unlocked bookmark = %+v, %v", got, err)
+	}
+	if _, err := s.ListBookmarks(ListQuery{Sort: "title"}); err == nil {
+		t.Fatal("title sort accepted on an encrypted library")
+	}
+
+	s.LockLibrary()
+	got, err = s.GetBookmark(locked.ID)
+	if err != nil || got.Title != "" || !got.TitleLocked {
+		t.Fatalf("locked bookmark = %+v, %v", got, err)
+	}
+
+	if _, err := s.DisableEncryption(testPassphrase); err != nil {
+		t.Fatal(err)
+	}
+	var title, alias string
+	if err := s.db.QueryRow("SELECT title, alias FROM bookmarks WHERE id = ?", bm.ID).Scan(&title, &alias); err != nil {
+		t.Fatal(err)
+	}
+	if title != "Private Title" || alias != "secret alias" {
+		t.Fatalf("after disabling title = %q, alias = %q", title, alias)
+	}
+}
+
+func TestEnableEncryptionResumesAfterFailure(t *testing.T) {
+	s := newTestService(t)
+	a := saveTestBookmark(t, s, "https://example.com/a", "First", "<p>a</p>")
+	b := saveTestBookmark(t, s, "https://example.com/b", "Second", "<p>b</p>")
+
+	// 把 b 的 HTML 换成目录，让转换在读取时失败
+	broken := getAbsoluteFilePath(s.dataDir, b.FilePath)
+	original, err := os.ReadFile(broken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(broken); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(broken, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.EnableEncryption(testPassphrase); err == nil {
+		t.Fatal("conversion failure not reported")
+	}
+	if status := s.EncryptionStatus(); !status.Enabled || !status.Pending {
+		t.Fatalf("status after failure = %+v", status)
+	}
+	if _, err := s.EnableEncryption(testPassphrase); err == nil || strings.Contains(err.Error(), "已启用") {
+		t.Fatalf("retry error = %v", err)
+	}
+	if _, err := s.EnableEncryption("wrong passphrase"); err == nil {
+		t.Fatal("retry with a different passphrase accepted")
+	}
+
+	if err := os.Remove(broken); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(broken, original, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s.LockLibrary()
+	status, err := s.UnlockLibrary(testPassphrase)
+	if err != nil || status.Pending {
+		t.Fatalf("unlock = %+v, %v", status, err)
+	}
+	for _, id := range []string{a.ID, b.ID} {
+		var filePath, title string
+		if err := s.db.QueryRow("SELECT file_path, title FROM bookmarks WHERE id = ?", id).Scan(&filePath, &title); err != nil {
+			t.Fatal(err)
+		}
+		raw, err := os.ReadFile(getAbsoluteFilePath(s.dataDir, filePath))
+		if err != nil || !isSealed(raw) || !strings.HasPrefix(title, sealedFieldPrefix) {
+			t.Fatalf("%s not converted after resuming: path %q, title %q, %v", id, filePath, title, err)
+		}
+	}
+}
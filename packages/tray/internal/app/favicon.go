@@ -0,0 +1,391 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	faviconDirName     = "favicons"
+	maxFaviconBytes    = 1 << 20
+	defaultFaviconSize = 32
+	// maxFaviconDimension 在解码前限制图片宽高，防止很小的文件声明巨大的尺寸耗尽内存。
+	maxFaviconDimension = 1024
+	// faviconGracePeriod 内新写入的图标不参与清理：保存收藏时先登记图标，之后才插入收藏。
+	faviconGracePeriod = time.Hour
+)
+
+var faviconSizes = []int{16, 32, 64}
+
+type FaviconResult struct {
+	ID      string `json:"id"`
+	Mime    string `json:"mime"`
+	Size    int    `json:"size"`
+	DataURL string `json:"dataUrl"`
+}
+
+type FaviconRefreshResult struct {
+	Domain    string `json:"domain"`
+	FaviconID string `json:"faviconId"`
+	Updated   int    `json:"updated"`
+}
+
+func (s *Service) faviconDir() string {
+	return filepath.Join(s.dataDir, faviconDirName)
+}
+
+// storeFavicon 按内容哈希去重保存 favicon，并将其登记为该域名的当前图标。
+func (s *Service) storeFavicon(domain string, data []byte, mime string) (string, error) {
+	if len(data) == 0 || len(data) > maxFaviconBytes {
+		return "", errors.New("favicon 为空或过大")
+	}
+	if mime == "" {
+		mime = http.DetectContentType(data)
+	}
+	sum := sha256.Sum256(data)
+	id := hex.EncodeToString(sum[:16])
+
+	var existing string
+	err := s.db.QueryRow("SELECT id FROM favicons WHERE id = ?", id).Scan(&existing)
+	if err == sql.ErrNoRows {
+		if err := os.MkdirAll(s.faviconDir(), 0o755); err != nil {
+			return "", err
+		}
+		if err := s.writeArtifact(filepath.Join(s.faviconDir(), id), data); err != nil {
+			return "", err
+		}
+		sizes := s.writeFaviconSizes(id, data)
+		if _, err := s.db.Exec("INSERT INTO favicons (id, mime, sizes, created_at) VALUES (?, ?, ?, ?)",
+			id, mime, sizes, time.Now().UnixMilli()); err != nil {
+			return "", err
+		}
+	} else if err != nil {
+		return "", err
+	}
+
+	_, err = s.db.Exec(`INSERT INTO domain_favicons (domain, favicon_id, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(domain) DO UPDATE SET favicon_id = excluded.favicon_id, updated_at = excluded.updated_at`,
+		domain, id, time.Now().UnixMilli())
+	return id, err
+}
+
+func (s *Service) storeFaviconDataURL(domain, dataURL string) string {
+	data, mime, ok := decodeDataURL(dataURL)
+	if !ok {
+		return ""
+	}
+	id, err := s.storeFavicon(domain, data, mime)
+	if err != nil {
+		return ""
+	}
+	return id
+}
+
+func (s *Service) GetFavicon(id, domain string, size int) (*FaviconResult, error) {
+	if id == "" && domain != "" {
+		err := s.db.QueryRow("SELECT favicon_id FROM domain_favicons WHERE domain = ?", domain).Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if id == "" {
+		return nil, errors.New("缺少 favicon id 或域名")
+	}
+	if size <= 0 {
+		size = defaultFaviconSize
+	}
+
+	var mime, sizes string
+	if err := s.db.QueryRow("SELECT mime, sizes FROM favicons WHERE id = ?", id).Scan(&mime, &sizes); err != nil {
+		return nil, err
+	}
+
+	name := id
+	chosen := pickFaviconSize(sizes, size)
+	if chosen > 0 {
+		name = fmt.Sprintf("%s-%d.png", id, chosen)
+		mime = "image/png"
+	}
+	data, err := s.readArtifact(faviconDirName + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	return &FaviconResult{
+		ID:      id,
+		Mime:    mime,
+		Size:    chosen,
+		DataURL: "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(data),
+	}, nil
+}
+
+func (s *Service) RefreshFavicon(domain string) (*FaviconRefreshResult, error) {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if domain == "" || domain == "unknown" {
+		return nil, errors.New("缺少域名")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+domain+"/favicon.ico", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("获取 favicon 失败: HTTP %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFaviconBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	id, err := s.storeFavicon(domain, data, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+	updated, err := s.relinkDomainFavicon(domain, id)
+	if err != nil {
+		return nil, err
+	}
+	return &FaviconRefreshResult{Domain: domain, FaviconID: id, Updated: updated}, nil
+}
+
+func (s *Service) relinkDomainFavicon(domain, faviconID string) (int, error) {
+	result, err := s.db.Exec("UPDATE bookmarks SET favicon_id = ? WHERE domain = ?", faviconID, domain)
+	if err != nil {
+		return 0, err
+	}
+	affected, _ := result.RowsAffected()
+	return int(affected), nil
+}
+
+// PruneFavicons 删除已没有任何收藏（含回收站）使用的域名图标登记，以及不再被引用的图标文件。
+func (s *Service) PruneFavicons() (int, error) {
+	cutoff := time.Now().Add(-faviconGracePeriod).UnixMilli()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM domain_favicons WHERE updated_at < ?
+		AND NOT EXISTS (SELECT 1 FROM bookmarks b WHERE b.domain = domain_favicons.domain)`, cutoff); err != nil {
+		return 0, err
+	}
+	rows, err := tx.Query(`SELECT id, sizes FROM favicons WHERE created_at < ?
+		AND id NOT IN (SELECT favicon_id FROM domain_favicons)
+		AND id NOT IN (SELECT favicon_id FROM bookmarks WHERE favicon_id != '')`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	var ids, names []string
+	for rows.Next() {
+		var id, sizes string
+		if err := rows.Scan(&id, &sizes); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+		names = append(names, id)
+		for _, size := range strings.Split(sizes, ",") {
+			if size != "" {
+				names = append(names, id+"-"+size+".png")
+			}
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	for _, id := range ids {
+		if _, err := tx.Exec("DELETE FROM favicons WHERE id = ?", id); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	for _, name := range names {
+		_ = os.Remove(filepath.Join(s.faviconDir(), name))
+	}
+	return len(ids), nil
+}
+
+func (s *Service) migrateInlineFavicons() error {
+	rows, err := s.db.Query("SELECT id, url, favicon FROM bookmarks WHERE favicon != ''")
+	if err != nil {
+		return err
+	}
+	type inlineRow struct {
+		id      string
+		url     string
+		favicon string
+	}
+	var items []inlineRow
+	for rows.Next() {
+		var item inlineRow
+		if err := rows.Scan(&item.id, &item.url, &item.favicon); err != nil {
+			rows.Close()
+			return err
+		}
+		items = append(items, item)
+	}
+	rows.Close()
+
+	for _, item := range items {
+		faviconID := s.storeFaviconDataURL(getDomain(item.url), item.favicon)
+		if _, err := s.db.Exec("UPDATE bookmarks SET favicon = '', favicon_id = ? WHERE id = ?", faviconID, item.id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeDataURL(dataURL string) ([]byte, string, bool) {
+	if !strings.HasPrefix(dataURL, "data:") {
+		return nil, "", false
+	}
+	header, body, ok := strings.Cut(strings.TrimPrefix(dataURL, "data:"), ",")
+	if !ok || !strings.HasSuffix(header, ";base64") {
+		return nil, "", false
+	}
+	data, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return nil, "", false
+	}
+	return data, strings.TrimSuffix(header, ";base64"), true
+}
+
+func pickFaviconSize(sizes string, want int) int {
+	chosen := 0
+	for _, part := range strings.Split(sizes, ",") {
+		size, err := strconv.Atoi(part)
+		if err != nil {
+			continue
+		}
+		if chosen == 0 || (chosen < want && size > chosen) || (size >= want && size < chosen) {
+			chosen = size
+		}
+	}
+	return chosen
+}
+
+func (s *Service) writeFaviconSizes(id string, data []byte) string {
+	img, ok := decodeFaviconImage(data)
+	if !ok {
+		return ""
+	}
+	var written []string
+	for _, size := range faviconSizes {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, resizeSquare(img, size)); err != nil {
+			continue
+		}
+		if err := s.writeArtifact(filepath.Join(s.faviconDir(), fmt.Sprintf("%s-%d.png", id, size)), buf.Bytes()); err != nil {
+			continue
+		}
+		written = append(written, strconv.Itoa(size))
+	}
+	return strings.Join(written, ",")
+}
+
+func decodeFaviconImage(data []byte) (image.Image, bool) {
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		if !faviconDimensionsOK(cfg) {
+			return nil, false
+		}
+		if img, _, err := image.Decode(bytes.NewReader(data)); err == nil {
+			return img, true
+		}
+	}
+	return decodeICOPNG(data)
+}
+
+func faviconDimensionsOK(cfg image.Config) bool {
+	return cfg.Width > 0 && cfg.Height > 0 && cfg.Width <= maxFaviconDimension && cfg.Height <= maxFaviconDimension
+}
+
+// decodeICOPNG 只处理内嵌 PNG 的 ICO 条目，BMP 条目保留原文件不做缩放。
+func decodeICOPNG(data []byte) (image.Image, bool) {
+	if len(data) < 6 || binary.LittleEndian.Uint16(data[0:2]) != 0 || binary.LittleEndian.Uint16(data[2:4]) != 1 {
+		return nil, false
+	}
+	count := int(binary.LittleEndian.Uint16(data[4:6]))
+	var best image.Image
+	bestWidth := 0
+	for i := 0; i < count; i++ {
+		entry := 6 + i*16
+		if entry+16 > len(data) {
+			break
+		}
+		size := int(binary.LittleEndian.Uint32(data[entry+8 : entry+12]))
+		offset := int(binary.LittleEndian.Uint32(data[entry+12 : entry+16]))
+		if offset < 0 || size <= 0 || offset+size > len(data) {
+			continue
+		}
+		entryData := data[offset : offset+size]
+		if cfg, err := png.DecodeConfig(bytes.NewReader(entryData)); err != nil || !faviconDimensionsOK(cfg) {
+			continue
+		}
+		img, err := png.Decode(bytes.NewReader(entryData))
+		if err != nil {
+			continue
+		}
+		if width := img.Bounds().Dx(); width > bestWidth {
+			best, bestWidth = img, width
+		}
+	}
+	return best, best != nil
+}
+
+func resizeSquare(src image.Image, size int) *image.NRGBA {
+	bounds := src.Bounds()
+	rgba := image.NewNRGBA(bounds)
+	draw.Draw(rgba, bounds, src, bounds.Min, draw.Src)
+
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/size
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*bounds.Dy()/size)
+		for x := 0; x < size; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/size
+			x1 := max(x0+1, bounds.Min.X+(x+1)*bounds.Dx()/size)
+			var r, g, b, a, count int
+			for py := y0; py < y1; py++ {
+				for px := x0; px < x1; px++ {
+					offset := rgba.PixOffset(px, py)
+					r += int(rgba.Pix[offset])
+					g += int(rgba.Pix[offset+1])
+					b += int(rgba.Pix[offset+2])
+					a += int(rgba.Pix[offset+3])
+					count++
+				}
+			}
+			offset := dst.PixOffset(x, y)
+			dst.Pix[offset] = uint8(r / count)
+			dst.Pix[offset+1] = uint8(g / count)
+			dst.Pix[offset+2] = uint8(b / count)
+			dst.Pix[offset+3] = uint8(a / count)
+		}
+	}
+	return dst
+}
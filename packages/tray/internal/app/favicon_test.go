@@ -0,0 +1,111 @@
+package app
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withDeclaredSize 改写 PNG 头中声明的宽高（并修正校验和），像素数据保持不变。
+func withDeclaredSize(data []byte, width, height uint32) []byte {
+	out := bytes.Clone(data)
+	binary.BigEndian.PutUint32(out[16:20], width)
+	binary.BigEndian.PutUint32(out[20:24], height)
+	binary.BigEndian.PutUint32(out[29:33], crc32.ChecksumIEEE(out[12:29]))
+	return out
+}
+
+func testICO(images ...[]byte) []byte {
+	header := make([]byte, 6+16*len(images))
+	binary.LittleEndian.PutUint16(header[2:4], 1)
+	binary.LittleEndian.PutUint16(header[4:6], uint16(len(images)))
+	offset := len(header)
+	var body []byte
+	for i, data := range images {
+		entry := header[6+i*16:]
+		binary.LittleEndian.PutUint32(entry[8:12], uint32(len(data)))
+		binary.LittleEndian.PutUint32(entry[12:16], uint32(offset))
+		offset += len(data)
+		body = append(body, data...)
+	}
+	return append(header, body...)
+}
+
+func TestDecodeFaviconImageLimitsDimensions(t *testing.T) {
+	small := testPNG(t, 48, 48)
+	if img, ok := decodeFaviconImage(small); !ok || img.Bounds().Dx() != 48 {
+		t.Fatal("regular PNG rejected")
+	}
+	huge := withDeclaredSize(small, 100000, 100000)
+	if _, _, err := image.DecodeConfig(bytes.NewReader(huge)); err != nil {
+		t.Fatalf("crafted header unreadable: %v", err)
+	}
+	if _, ok := decodeFaviconImage(huge); ok {
+		t.Fatal("PNG declaring 100000x100000 was decoded")
+	}
+
+	if img, ok := decodeFaviconImage(testICO(testPNG(t, 16, 16), testPNG(t, 32, 32))); !ok || img.Bounds().Dx() != 32 {
+		t.Fatal("ICO should use its largest PNG entry")
+	}
+	if img, ok := decodeFaviconImage(testICO(testPNG(t, 16, 16), huge)); !ok || img.Bounds().Dx() != 16 {
+		t.Fatal("oversized ICO entry not skipped")
+	}
+}
+
+func TestPruneFavicons(t *testing.T) {
+	s := newTestService(t)
+	icon := "data:image/png;base64," + base64.StdEncoding.EncodeToString(testPNG(t, 48, 48))
+	kept, err := s.SaveBookmark(SaveInput{URL: "https://kept.example/a", Title: "Kept", HTML: "<p>a</p>", Favicon: icon})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gone, err := s.SaveBookmark(SaveInput{URL: "https://gone.example/a", Title: "Gone", HTML: "<p>a</p>",
+		Favicon: "data:image/png;base64," + base64.StdEncoding.EncodeToString(testPNG(t, 20, 20))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.permanentDelete("trash.delete", gone.ID); err != nil {
+		t.Fatal(err)
+	}
+	if removed, err := s.PruneFavicons(); err != nil || removed != 0 {
+		t.Fatalf("prune within grace period = %d, %v", removed, err)
+	}
+
+	for _, table := range []string{"favicons SET created_at", "domain_favicons SET updated_at"} {
+		if _, err := s.db.Exec("UPDATE " + table + " = 0"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	removed, err := s.PruneFavicons()
+	if err != nil || removed != 1 {
+		t.Fatalf("prune = %d, %v", removed, err)
+	}
+	if _, err := s.GetFavicon("", "gone.example", 0); err == nil {
+		t.Fatal("favicon of a domain without bookmarks still registered")
+	}
+	if _, err := s.GetFavicon(kept.FaviconID, "", 0); err != nil {
+		t.Fatalf("favicon in use removed: %v", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(s.faviconDir(), gone.FaviconID+"*"))
+	if len(files) != 0 {
+		t.Fatalf("files left behind: %v", files)
+	}
+	if _, err := os.Stat(filepath.Join(s.faviconDir(), kept.FaviconID+"-16.png")); err != nil {
+		t.Fatalf("resized favicon in use removed: %v", err)
+	}
+}
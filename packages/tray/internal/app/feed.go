@@ -0,0 +1,413 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+
+	"chrome-collect-tray/internal/protocol"
+)
+
+const (
+	metaFeedExport      = "feed_export"
+	metaFeedActivity    = "feed_export_activity"
+	defaultFeedLimit    = 50
+	maxFeedLimit        = 500
+	feedSummaryMaxRunes = 280
+	defaultFeedTitle    = "Chrome Collect"
+	jsonFeedVersion     = "https://jsonfeed.org/version/1.1"
+)
+
+type FeedConfig struct {
+	AtomPath   string `json:"atomPath"`
+	JSONPath   string `json:"jsonPath"`
+	Title      string `json:"title"`
+	Author     string `json:"author"`
+	Tag        string `json:"tag"`
+	Collection string `json:"collection"`
+	Limit      int    `json:"limit"`
+	// IncludePrivate 为 false 时，加密的资料库不把标题、备注和正文摘要写入明文订阅源，标题以网址代替。
+	IncludePrivate bool `json:"includePrivate"`
+}
+
+type FeedExportResult struct {
+	Count    int    `json:"count"`
+	AtomPath string `json:"atomPath,omitempty"`
+	JSONPath string `json:"jsonPath,omitempty"`
+}
+
+type feedItem struct {
+	ID        string
+	Title     string
+	URL       string
+	Notes     string
+	Summary   string
+	Tags      []string
+	CreatedAt time.Time
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomPerson  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Content    *atomText      `xml:"content,omitempty"`
+	Categories []atomCategory `xml:"category"`
+}
+
+type jsonFeed struct {
+	Version string         `json:"version"`
+	Title   string         `json:"title"`
+	Authors []jsonFeedName `json:"authors,omitempty"`
+	Items   []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedName struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedItem struct {
+	ID            string   `json:"id"`
+	URL           string   `json:"url"`
+	Title         string   `json:"title"`
+	ContentText   string   `json:"content_text"`
+	Summary       string   `json:"summary,omitempty"`
+	DatePublished string   `json:"date_published"`
+	Tags          []string `json:"tags,omitempty"`
+}
+
+func (s *Service) feedConfig() FeedConfig {
+	cfg := FeedConfig{}
+	if value, err := s.getMeta(metaFeedExport); err == nil && value != "" {
+		_ = json.Unmarshal([]byte(value), &cfg)
+	}
+	if cfg.Limit <= 0 {
+		cfg.Limit = defaultFeedLimit
+	}
+	return cfg
+}
+
+// SetFeedConfig 保存订阅源配置并立即生成一次；两个路径都为空即关闭。
+func (s *Service) SetFeedConfig(cfg FeedConfig) (*FeedExportResult, error) {
+	cfg.AtomPath = strings.TrimSpace(cfg.AtomPath)
+	cfg.JSONPath = strings.TrimSpace(cfg.JSONPath)
+	cfg.Tag = strings.TrimSpace(cfg.Tag)
+	cfg.Title = strings.TrimSpace(cfg.Title)
+	cfg.Author = strings.TrimSpace(cfg.Author)
+	for _, path := range []string{cfg.AtomPath, cfg.JSONPath} {
+		if path != "" && !filepath.IsAbs(path) {
+			return nil, fmt.Errorf("订阅源路径需为绝对路径: %s", path)
+		}
+	}
+	if cfg.AtomPath != "" && cfg.AtomPath == cfg.JSONPath {
+		return nil, errors.New("Atom 与 JSON Feed 不能写入同一个文件")
+	}
+	if cfg.Collection != "" {
+		if _, ok := findCollection(cfg.Collection); !ok {
+			return nil, fmt.Errorf("未知的合集: %s", cfg.Collection)
+		}
+	}
+	if cfg.Limit < 0 || cfg.Limit > maxFeedLimit {
+		return nil, fmt.Errorf("条目数量需在 1 到 %d 之间", maxFeedLimit)
+	}
+	raw, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.setSetting(protocol.MethodSettingsFeed, metaFeedExport, string(raw)); err != nil {
+		return nil, err
+	}
+	return s.ExportFeeds()
+}
+
+// RunFeedExporter 在操作记录出现新条目后重新生成订阅源，保存、移入回收站、删除、改标题和标签都会触发。
+// 变更也可能来自 Native Host 进程，因此以操作记录为准，而不是在保存路径上同步生成。
+// 订阅源只由桌面端（托盘进程）生成：桌面端未运行时通过扩展保存的收藏，要等它下次启动后才会写入。
+func (s *Service) RunFeedExporter(ctx context.Context, tick time.Duration) {
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		_ = s.exportFeedsIfChanged()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) exportFeedsIfChanged() error {
+	cfg := s.feedConfig()
+	if cfg.AtomPath == "" && cfg.JSONPath == "" {
+		return nil
+	}
+	latest, err := s.latestActivityID()
+	if err != nil {
+		return err
+	}
+	if exported, _ := s.getMeta(metaFeedActivity); exported == strconv.FormatInt(latest, 10) {
+		return nil
+	}
+	_, err = s.ExportFeeds()
+	return err
+}
+
+func (s *Service) latestActivityID() (int64, error) {
+	var id int64
+	err := s.db.QueryRow("SELECT COALESCE(MAX(id), 0) FROM activity_log").Scan(&id)
+	return id, err
+}
+
+// ExportFeeds 按当前配置重新生成订阅源文件；未配置路径时什么也不写。
+func (s *Service) ExportFeeds() (*FeedExportResult, error) {
+	cfg := s.feedConfig()
+	result := &FeedExportResult{AtomPath: cfg.AtomPath, JSONPath: cfg.JSONPath}
+	if cfg.AtomPath == "" && cfg.JSONPath == "" {
+		return result, nil
+	}
+	// 先记下操作记录的位置，生成期间发生的变更会在下一轮再次触发。
+	latest, err := s.latestActivityID()
+	if err != nil {
+		return nil, err
+	}
+	items, err := s.feedItems(cfg)
+	if err != nil {
+		return nil, err
+	}
+	result.Count = len(items)
+	if cfg.Title == "" {
+		cfg.Title = defaultFeedTitle
+	}
+	if cfg.AtomPath != "" {
+		if err := writeFeedFile(cfg.AtomPath, func() ([]byte, error) { return renderAtomFeed(cfg, items) }); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.JSONPath != "" {
+		if err := writeFeedFile(cfg.JSONPath, func() ([]byte, error) { return renderJSONFeed(cfg, items) }); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.setMeta(metaFeedActivity, strconv.FormatInt(latest, 10)); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// feedItems 返回最近的收藏；标签存在 JSON 数组中，因此标签和合集在 Go 中过滤。
+func (s *Service) feedItems(cfg FeedConfig) ([]feedItem, error) {
+	collection, hasCollection := findCollection(cfg.Collection)
+	private := cfg.IncludePrivate || !s.EncryptionStatus().Enabled
+	items := []feedItem{}
+	const batch = 200
+	for offset := 0; len(items) < cfg.Limit; offset += batch {
+		bookmarks, err := s.selectBookmarks("deleted_at = 0", nil, defaultOrderBy, batch, offset)
+		if err != nil {
+			return nil, err
+		}
+		for i := range bookmarks {
+			bm := &bookmarks[i]
+			tags := parseTags(bm.Tags)
+			if cfg.Tag != "" && !slices.Contains(tags, cfg.Tag) {
+				continue
+			}
+			if hasCollection && !collection.match(bm) {
+				continue
+			}
+			item := feedItem{
+				ID:        bm.ID,
+				Title:     bm.URL,
+				URL:       bm.URL,
+				Tags:      tags,
+				CreatedAt: time.UnixMilli(bm.CreatedAt).UTC(),
+			}
+			if private {
+				item.Title = bookmarkDisplayName(*bm)
+				item.Notes = bm.Notes
+				item.Summary = s.bookmarkSummary(bm.FilePath)
+			}
+			items = append(items, item)
+			if len(items) == cfg.Limit {
+				break
+			}
+		}
+		if len(bookmarks) < batch {
+			break
+		}
+	}
+	return items, nil
+}
+
+// bookmarkSummary 优先使用页面自带的 description，没有时截取正文开头；资料库锁定时为空。
+func (s *Service) bookmarkSummary(filePath string) string {
+	data, err := s.readArtifact(filePath)
+	if err != nil {
+		return ""
+	}
+	if doc, err := html.Parse(strings.NewReader(string(data))); err == nil {
+		if description := pageDescription(doc); description != "" {
+			return truncateRunes(description, feedSummaryMaxRunes)
+		}
+	}
+	return truncateRunes(extractText(string(data)), feedSummaryMaxRunes)
+}
+
+func pageDescription(n *html.Node) string {
+	if n.Type == html.ElementNode && n.Data == "meta" {
+		key := strings.ToLower(htmlAttr(n, "name") + htmlAttr(n, "property"))
+		if key == "description" || key == "og:description" {
+			return strings.Join(strings.Fields(htmlAttr(n, "content")), " ")
+		}
+	}
+	if n.Type == html.ElementNode && n.Data == "body" {
+		return ""
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if description := pageDescription(child); description != "" {
+			return description
+		}
+	}
+	return ""
+}
+
+func truncateRunes(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return strings.TrimSpace(string(runes[:limit])) + "…"
+}
+
+func renderAtomFeed(cfg FeedConfig, items []feedItem) ([]byte, error) {
+	author := cfg.Author
+	if author == "" {
+		author = cfg.Title
+	}
+	feed := atomFeed{
+		ID:      "urn:chrome-collect:feed:" + feedIdentity(cfg),
+		Title:   cfg.Title,
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		Author:  atomPerson{Name: author},
+	}
+	if len(items) > 0 {
+		feed.Updated = items[0].CreatedAt.Format(time.RFC3339)
+	}
+	for _, item := range items {
+		entry := atomEntry{
+			ID:        "urn:uuid:" + item.ID,
+			Title:     item.Title,
+			Link:      atomLink{Href: item.URL, Rel: "alternate"},
+			Published: item.CreatedAt.Format(time.RFC3339),
+			Updated:   item.CreatedAt.Format(time.RFC3339),
+		}
+		if item.Summary != "" {
+			entry.Summary = &atomText{Type: "text", Body: item.Summary}
+		}
+		if item.Notes != "" {
+			entry.Content = &atomText{Type: "text", Body: item.Notes}
+		}
+		for _, tag := range item.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	data, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+func renderJSONFeed(cfg FeedConfig, items []feedItem) ([]byte, error) {
+	feed := jsonFeed{Version: jsonFeedVersion, Title: cfg.Title, Items: []jsonFeedItem{}}
+	if cfg.Author != "" {
+		feed.Authors = []jsonFeedName{{Name: cfg.Author}}
+	}
+	for _, item := range items {
+		feed.Items = append(feed.Items, jsonFeedItem{
+			ID:            item.ID,
+			URL:           item.URL,
+			Title:         item.Title,
+			ContentText:   item.Notes,
+			Summary:       item.Summary,
+			DatePublished: item.CreatedAt.Format(time.RFC3339),
+			Tags:          item.Tags,
+		})
+	}
+	data, err := json.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// feedIdentity 让不同过滤条件的订阅源拥有不同且稳定的 ID。
+func feedIdentity(cfg FeedConfig) string {
+	parts := []string{"all"}
+	if cfg.Tag != "" {
+		parts = append(parts, "tag="+cfg.Tag)
+	}
+	if cfg.Collection != "" {
+		parts = append(parts, "collection="+cfg.Collection)
+	}
+	return strings.Join(parts, ";")
+}
+
+// writeFeedFile 先写临时文件再重命名，避免同步工具或静态服务器读到写了一半的文件。
+func writeFeedFile(path string, render func() ([]byte, error)) error {
+	data, err := render()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
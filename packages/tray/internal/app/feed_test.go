@@ -0,0 +1,119 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readFeed(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestFeedExportFollowsActivity(t *testing.T) {
+	s := newTestService(t)
+	dir := t.TempDir()
+	cfg := FeedConfig{AtomPath: filepath.Join(dir, "feed.atom"), JSONPath: filepath.Join(dir, "feed.json")}
+	if _, err := s.SetFeedConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	keep := saveTestBookmark(t, s, "https://example.com/keep", "Keep me", "<p>kept</p>")
+	gone := saveTestBookmark(t, s, "https://example.com/gone", "Trash me", "<p>gone</p>")
+
+	// 保存路径本身不再生成订阅源，由后台任务根据操作记录补上。
+	if strings.Contains(readFeed(t, cfg.JSONPath), "Keep me") {
+		t.Fatal("feed regenerated synchronously on save")
+	}
+	if err := s.exportFeedsIfChanged(); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{cfg.AtomPath, cfg.JSONPath} {
+		feed := readFeed(t, path)
+		if !strings.Contains(feed, "Keep me") || !strings.Contains(feed, "Trash me") {
+			t.Fatalf("%s missing entries:\n%s", path, feed)
+		}
+	}
+
+	if err := s.DeleteBookmark(gone.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateAlias(keep.ID, "Renamed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.exportFeedsIfChanged(); err != nil {
+		t.Fatal(err)
+	}
+	feed := readFeed(t, cfg.JSONPath)
+	if strings.Contains(feed, "Trash me") || !strings.Contains(feed, "Renamed") {
+		t.Fatalf("feed not refreshed after trash and rename:\n%s", feed)
+	}
+
+	// 没有新的操作记录时不重写文件。
+	if err := os.Remove(cfg.JSONPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.exportFeedsIfChanged(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(cfg.JSONPath); !os.IsNotExist(err) {
+		t.Fatalf("feed rewritten without changes: %v", err)
+	}
+}
+
+// 桌面端关闭期间（例如经由 Native Host）保存的收藏，在 RunFeedExporter 启动时的第一轮写入。
+func TestFeedExporterCatchesUpOnStart(t *testing.T) {
+	s := newTestService(t)
+	cfg := FeedConfig{JSONPath: filepath.Join(t.TempDir(), "feed.json")}
+	if _, err := s.SetFeedConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	saveTestBookmark(t, s, "https://example.com/offline", "Saved offline", "<p>x</p>")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.RunFeedExporter(ctx, time.Hour)
+	if feed := readFeed(t, cfg.JSONPath); !strings.Contains(feed, "Saved offline") {
+		t.Fatalf("feed not written on start:\n%s", feed)
+	}
+}
+
+func TestFeedOmitsPrivateFieldsWhenEncrypted(t *testing.T) {
+	s := newTestService(t)
+	dir := t.TempDir()
+	cfg := FeedConfig{JSONPath: filepath.Join(dir, "feed.json")}
+	bm := saveTestBookmark(t, s, "https://example.com/a", "Private title", "<p>secret body text</p>")
+	if err := s.UpdateNotes(bm.ID, "secret note"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.EnableEncryption("correct horse battery"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.SetFeedConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	feed := readFeed(t, cfg.JSONPath)
+	if !strings.Contains(feed, `"title": "https://example.com/a"`) {
+		t.Fatalf("feed missing entry:\n%s", feed)
+	}
+	if strings.Contains(feed, "Private title") || strings.Contains(feed, "secret note") || strings.Contains(feed, "secret body text") {
+		t.Fatalf("encrypted library leaked title, notes or summary into the feed:\n%s", feed)
+	}
+
+	cfg.IncludePrivate = true
+	if _, err := s.SetFeedConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	feed = readFeed(t, cfg.JSONPath)
+	if !strings.Contains(feed, "Private title") || !strings.Contains(feed, "secret note") || !strings.Contains(feed, "secret body text") {
+		t.Fatalf("opt-in did not include title, notes and summary:\n%s", feed)
+	}
+}
@@ -0,0 +1,618 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	capturePageTimeout = 2 * time.Minute
+	captureReqTimeout  = 30 * time.Second
+	captureWorkers     = 8
+	maxCapturePageSize = 20 << 20
+	maxCaptureResource = 15 << 20
+	maxCaptureBatch    = 500
+	maxCSSImportDepth  = 5
+	captureUserAgent   = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+)
+
+var (
+	cssURLRe    = regexp.MustCompile(`url\(\s*(?:"([^"]*)"|'([^']*)'|([^'")\s]+))\s*\)`)
+	cssImportRe = regexp.MustCompile(`@import\s+(?:url\(\s*)?['"]?([^'")\s;]+)['"]?\s*\)?[^;]*;`)
+
+	// 与 capture.js 一致：这些 <link> 会触发额外请求，静态化后没有用处。
+	captureDropRels = map[string]bool{
+		"preload": true, "modulepreload": true, "prefetch": true, "preconnect": true,
+		"dns-prefetch": true, "manifest": true, "prerender": true,
+	}
+	captureDropTags = map[string]bool{
+		"script": true, "noscript": true, "base": true, "iframe": true,
+		"object": true, "embed": true, "applet": true,
+	}
+)
+
+type CaptureRequest struct {
+	URLs         []string `json:"urls"`
+	SkipExisting bool     `json:"skipExisting"`
+}
+
+type CaptureFailure struct {
+	URL   string `json:"url"`
+	Error string `json:"error"`
+}
+
+type CaptureResult struct {
+	Saved   []Bookmark       `json:"saved"`
+	Skipped []string         `json:"skipped"`
+	Failed  []CaptureFailure `json:"failed"`
+}
+
+// pageFetcher 负责一个页面及其资源的下载；同一资源只下载一次，并发请求数受 captureWorkers 限制。
+type pageFetcher struct {
+	ctx     context.Context
+	client  *http.Client
+	referer string
+	workers chan struct{}
+	mu      sync.Mutex
+	cache   map[string]*fetchEntry
+}
+
+type fetchEntry struct {
+	ready chan struct{}
+	data  []byte
+	mime  string
+	err   error
+}
+
+// captureTask 先并发执行 fetch 下载资源，再按文档顺序执行 apply 修改节点树。
+type captureTask struct {
+	fetch func()
+	apply func()
+}
+
+// CaptureURL 不经过浏览器扩展，直接抓取网址并按 capture.js 的规则静态化后保存。
+func (s *Service) CaptureURL(rawURL string) (*Bookmark, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), capturePageTimeout)
+	defer cancel()
+	input, err := capturePage(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return s.SaveBookmark(*input)
+}
+
+func (s *Service) CaptureURLs(req CaptureRequest) (*CaptureResult, error) {
+	if len(req.URLs) > maxCaptureBatch {
+		return nil, fmt.Errorf("一次最多抓取 %d 个网址", maxCaptureBatch)
+	}
+	result := &CaptureResult{Saved: []Bookmark{}, Skipped: []string{}, Failed: []CaptureFailure{}}
+	seen := map[string]bool{}
+	for _, rawURL := range req.URLs {
+		rawURL = strings.TrimSpace(rawURL)
+		if rawURL == "" || seen[rawURL] {
+			continue
+		}
+		seen[rawURL] = true
+		if req.SkipExisting {
+			if exists, err := s.ExistsByURL(rawURL); err == nil && exists {
+				result.Skipped = append(result.Skipped, rawURL)
+				continue
+			}
+		}
+		bm, err := s.CaptureURL(rawURL)
+		if err != nil {
+			result.Failed = append(result.Failed, CaptureFailure{URL: rawURL, Error: err.Error()})
+			continue
+		}
+		result.Saved = append(result.Saved, *bm)
+	}
+	return result, nil
+}
+
+func capturePage(ctx context.Context, rawURL string) (*SaveInput, error) {
+	pageURL, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (pageURL.Scheme != "http" && pageURL.Scheme != "https") || pageURL.Host == "" {
+		return nil, errors.New("仅支持 http/https 网址")
+	}
+
+	f := &pageFetcher{
+		ctx:     ctx,
+		client:  &http.Client{Timeout: captureReqTimeout},
+		referer: pageURL.String(),
+		workers: make(chan struct{}, captureWorkers),
+		cache:   map[string]*fetchEntry{},
+	}
+	resp, err := f.get(pageURL.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != "" && mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, fmt.Errorf("不是 HTML 页面: %s", mediaType)
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxCapturePageSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) > maxCapturePageSize {
+		return nil, errors.New("页面过大")
+	}
+	body, err := charset.NewReader(bytes.NewReader(raw), contentType)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := html.Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("解析 HTML 失败: %w", err)
+	}
+
+	base := resp.Request.URL
+	if node := findElement(doc, "base"); node != nil {
+		if href, err := base.Parse(strings.TrimSpace(htmlAttr(node, "href"))); err == nil && htmlAttr(node, "href") != "" {
+			base = href
+		}
+	}
+	f.referer = resp.Request.URL.String()
+
+	root := findElement(doc, "html")
+	if root == nil {
+		return nil, errors.New("页面没有 <html> 元素")
+	}
+	title := ""
+	if node := findElement(doc, "title"); node != nil {
+		title = strings.TrimSpace(nodeText(node))
+	}
+	if title == "" {
+		title = resp.Request.URL.String()
+	}
+	favicon := f.favicon(doc, base)
+
+	var removals []*html.Node
+	var tasks []captureTask
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if shouldDropNode(n) {
+				removals = append(removals, n)
+				return
+			}
+			tasks = append(tasks, f.nodeTasks(n, base)...)
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(root)
+	for _, n := range removals {
+		n.Parent.RemoveChild(n)
+	}
+
+	var wg sync.WaitGroup
+	for _, task := range tasks {
+		if task.fetch == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(fetch func()) {
+			defer wg.Done()
+			fetch()
+		}(task.fetch)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	for _, task := range tasks {
+		task.apply()
+	}
+
+	var out bytes.Buffer
+	out.WriteString("<!DOCTYPE html>\n")
+	if err := html.Render(&out, root); err != nil {
+		return nil, err
+	}
+	return &SaveInput{URL: pageURL.String(), Title: title, Favicon: favicon, HTML: out.String()}, nil
+}
+
+func shouldDropNode(n *html.Node) bool {
+	if captureDropTags[n.Data] {
+		return true
+	}
+	switch n.Data {
+	case "link":
+		if strings.EqualFold(htmlAttr(n, "as"), "script") {
+			return true
+		}
+		for _, rel := range strings.Fields(strings.ToLower(htmlAttr(n, "rel"))) {
+			if captureDropRels[rel] {
+				return true
+			}
+		}
+	case "meta":
+		equiv := strings.ToLower(htmlAttr(n, "http-equiv"))
+		return equiv == "content-security-policy" || equiv == "refresh"
+	}
+	return false
+}
+
+// nodeTasks 对应 capture.js 中的样式表、图片、CSS url() 和链接处理。
+func (f *pageFetcher) nodeTasks(n *html.Node, base *url.URL) []captureTask {
+	var tasks []captureTask
+	switch n.Data {
+	case "video", "audio":
+		if htmlAttr(n, "src") != "" {
+			removeHTMLAttr(n, "src")
+			for child := n.FirstChild; child != nil; {
+				next := child.NextSibling
+				if child.Type == html.ElementNode && child.Data == "source" {
+					n.RemoveChild(child)
+				}
+				child = next
+			}
+		}
+		if poster := resolveRef(base, htmlAttr(n, "poster")); poster != "" {
+			var dataURI string
+			tasks = append(tasks, captureTask{
+				fetch: func() { dataURI, _ = f.dataURI(poster) },
+				apply: func() {
+					if dataURI != "" {
+						setHTMLAttr(n, "poster", dataURI)
+					}
+				},
+			})
+		}
+	case "link":
+		rels := strings.Fields(strings.ToLower(htmlAttr(n, "rel")))
+		href := resolveRef(base, htmlAttr(n, "href"))
+		if href == "" || !slices.Contains(rels, "stylesheet") {
+			break
+		}
+		var css string
+		var err error
+		tasks = append(tasks, captureTask{
+			fetch: func() { css, err = f.stylesheet(href, 0, map[string]bool{}) },
+			apply: func() {
+				if err != nil {
+					setHTMLAttr(n, "href", href)
+					return
+				}
+				style := &html.Node{Type: html.ElementNode, Data: "style"}
+				if media := htmlAttr(n, "media"); media != "" {
+					setHTMLAttr(style, "media", media)
+				}
+				style.AppendChild(&html.Node{Type: html.TextNode, Data: css})
+				n.Parent.InsertBefore(style, n)
+				n.Parent.RemoveChild(n)
+			},
+		})
+	case "img":
+		src := htmlAttr(n, "src")
+		if src == "" {
+			src = htmlAttr(n, "data-src")
+		}
+		if src == "" {
+			src = htmlAttr(n, "data-lazy-src")
+		}
+		if src == "" || strings.HasPrefix(src, "data:") {
+			break
+		}
+		src = resolveRef(base, src)
+		var dataURI string
+		tasks = append(tasks, captureTask{
+			fetch: func() { dataURI, _ = f.dataURI(src) },
+			apply: func() {
+				if dataURI != "" {
+					setHTMLAttr(n, "src", dataURI)
+				} else if src != "" {
+					setHTMLAttr(n, "src", src)
+				}
+				removeHTMLAttr(n, "srcset", "data-src", "data-lazy-src", "loading")
+			},
+		})
+	case "source":
+		srcset := htmlAttr(n, "srcset")
+		if srcset == "" {
+			break
+		}
+		first := strings.Fields(strings.Split(srcset, ",")[0])
+		if len(first) == 0 || strings.HasPrefix(first[0], "data:") {
+			break
+		}
+		src := resolveRef(base, first[0])
+		var dataURI string
+		tasks = append(tasks, captureTask{
+			fetch: func() { dataURI, _ = f.dataURI(src) },
+			apply: func() {
+				if dataURI == "" {
+					n.Parent.RemoveChild(n)
+					return
+				}
+				setHTMLAttr(n, "srcset", dataURI)
+			},
+		})
+	case "style":
+		if n.FirstChild == nil || n.FirstChild.Type != html.TextNode {
+			break
+		}
+		text := n.FirstChild
+		var css string
+		tasks = append(tasks, captureTask{
+			fetch: func() { css = f.inlineCSSURLs(text.Data, base) },
+			apply: func() { text.Data = css },
+		})
+	case "a":
+		if href := resolveRef(base, htmlAttr(n, "href")); href != "" {
+			tasks = append(tasks, captureTask{apply: func() { setHTMLAttr(n, "href", href) }})
+		}
+	}
+
+	if style := htmlAttr(n, "style"); strings.Contains(style, "url(") {
+		var css string
+		tasks = append(tasks, captureTask{
+			fetch: func() { css = f.inlineCSSURLs(style, base) },
+			apply: func() { setHTMLAttr(n, "style", css) },
+		})
+	}
+	return tasks
+}
+
+// stylesheet 下载样式表并递归展开 @import，其中的 url() 以各自样式表的地址为基准解析。
+func (f *pageFetcher) stylesheet(sheetURL string, depth int, visited map[string]bool) (string, error) {
+	if depth > maxCSSImportDepth || visited[sheetURL] {
+		return "", nil
+	}
+	visited[sheetURL] = true
+	data, _, err := f.fetch(sheetURL)
+	if err != nil {
+		return "", err
+	}
+	base, err := url.Parse(sheetURL)
+	if err != nil {
+		return "", err
+	}
+	css := strings.TrimPrefix(string(data), "\ufeff")
+	css = cssImportRe.ReplaceAllStringFunc(css, func(statement string) string {
+		ref := resolveRef(base, cssImportRe.FindStringSubmatch(statement)[1])
+		if ref == "" {
+			return ""
+		}
+		imported, err := f.stylesheet(ref, depth+1, visited)
+		if err != nil {
+			return ""
+		}
+		return imported
+	})
+	return f.inlineCSSURLs(css, base), nil
+}
+
+// inlineCSSURLs 把 url() 换成 data URI；下载失败的保留为绝对地址。
+func (f *pageFetcher) inlineCSSURLs(css string, base *url.URL) string {
+	matches := cssURLRe.FindAllStringSubmatch(css, -1)
+	if len(matches) == 0 {
+		return css
+	}
+	// 先在当前 goroutine 里收集去重后的引用，下载结果按下标写回，map 只在 Wait 之后填充。
+	resolved := map[string]string{}
+	var refs []string
+	for _, match := range matches {
+		ref := cssURLRef(match)
+		if _, ok := resolved[ref]; ok || ref == "" || strings.HasPrefix(ref, "data:") || strings.HasPrefix(ref, "#") {
+			continue
+		}
+		resolved[ref] = ""
+		refs = append(refs, ref)
+	}
+	values := make([]string, len(refs))
+	var wg sync.WaitGroup
+	for i, ref := range refs {
+		target := resolveRef(base, ref)
+		if target == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, target string) {
+			defer wg.Done()
+			values[i] = target
+			if dataURI, err := f.dataURI(target); err == nil {
+				values[i] = dataURI
+			}
+		}(i, target)
+	}
+	wg.Wait()
+	for i, ref := range refs {
+		resolved[ref] = values[i]
+	}
+	return cssURLRe.ReplaceAllStringFunc(css, func(match string) string {
+		if value := resolved[cssURLRef(cssURLRe.FindStringSubmatch(match))]; value != "" {
+			return `url("` + value + `")`
+		}
+		return match
+	})
+}
+
+func cssURLRef(match []string) string {
+	for _, group := range match[1:] {
+		if group != "" {
+			return strings.TrimSpace(group)
+		}
+	}
+	return ""
+}
+
+func (f *pageFetcher) favicon(doc *html.Node, base *url.URL) string {
+	href := ""
+	var find func(n *html.Node)
+	find = func(n *html.Node) {
+		if href != "" {
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "link" {
+			rels := strings.Fields(strings.ToLower(htmlAttr(n, "rel")))
+			if slices.Contains(rels, "icon") || slices.Contains(rels, "shortcut") {
+				href = resolveRef(base, htmlAttr(n, "href"))
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			find(child)
+		}
+	}
+	find(doc)
+	if href == "" {
+		href = resolveRef(base, "/favicon.ico")
+	}
+	dataURI, err := f.dataURI(href)
+	if err != nil {
+		return ""
+	}
+	return dataURI
+}
+
+func (f *pageFetcher) dataURI(rawURL string) (string, error) {
+	data, mediaType, err := f.fetch(rawURL)
+	if err != nil {
+		return "", err
+	}
+	return "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+func (f *pageFetcher) fetch(rawURL string) ([]byte, string, error) {
+	f.mu.Lock()
+	entry, ok := f.cache[rawURL]
+	if !ok {
+		entry = &fetchEntry{ready: make(chan struct{})}
+		f.cache[rawURL] = entry
+	}
+	f.mu.Unlock()
+	if ok {
+		<-entry.ready
+		return entry.data, entry.mime, entry.err
+	}
+	defer close(entry.ready)
+
+	resp, err := f.get(rawURL)
+	if err != nil {
+		entry.err = err
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxCaptureResource+1))
+	if err == nil && len(data) > maxCaptureResource {
+		err = fmt.Errorf("资源过大: %s", rawURL)
+	}
+	if err != nil {
+		entry.err = err
+		return nil, "", err
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "" {
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(data))
+	}
+	entry.data, entry.mime = data, mediaType
+	return data, mediaType, nil
+}
+
+func (f *pageFetcher) get(rawURL string) (*http.Response, error) {
+	select {
+	case f.workers <- struct{}{}:
+	case <-f.ctx.Done():
+		return nil, f.ctx.Err()
+	}
+	defer func() { <-f.workers }()
+
+	req, err := http.NewRequestWithContext(f.ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", captureUserAgent)
+	if f.referer != "" && f.referer != rawURL {
+		req.Header.Set("Referer", f.referer)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("请求失败 %s: %s", rawURL, resp.Status)
+	}
+	return resp, nil
+}
+
+func resolveRef(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+	target, err := base.Parse(ref)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") {
+		return ""
+	}
+	return target.String()
+}
+
+func findElement(n *html.Node, tag string) *html.Node {
+	if n.Type == html.ElementNode && n.Data == tag {
+		return n
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if found := findElement(child, tag); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func nodeText(n *html.Node) string {
+	var text strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.TextNode {
+			text.WriteString(child.Data)
+		}
+	}
+	return text.String()
+}
+
+func htmlAttr(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Namespace == "" && attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+func setHTMLAttr(n *html.Node, key, value string) {
+	for i, attr := range n.Attr {
+		if attr.Namespace == "" && attr.Key == key {
+			n.Attr[i].Val = value
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: key, Val: value})
+}
+
+func removeHTMLAttr(n *html.Node, keys ...string) {
+	attrs := n.Attr[:0]
+	for _, attr := range n.Attr {
+		if attr.Namespace != "" || !slices.Contains(keys, attr.Key) {
+			attrs = append(attrs, attr)
+		}
+	}
+	n.Attr = attrs
+}
@@ -0,0 +1,159 @@
+package app
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func captureTestServer(t *testing.T, routes map[string]string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		body, ok := routes[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		switch {
+		case strings.HasSuffix(r.URL.Path, ".css"):
+			w.Header().Set("Content-Type", "text/css")
+		case strings.HasSuffix(r.URL.Path, ".png"):
+			w.Header().Set("Content-Type", "image/png")
+		default:
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, &hits
+}
+
+func dataURIOf(mediaType, body string) string {
+	return "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString([]byte(body))
+}
+
+func TestCapturePageInlinesCSSURLs(t *testing.T) {
+	server, _ := captureTestServer(t, map[string]string{
+		"/": `<html><head><title>Page</title>
+<link rel="stylesheet" href="/css/main.css">
+<style>.a{background:url(img/a.png)} .b{background:url('img/a.png')} .c{background:url("/missing.png")}</style>
+<script>alert(1)</script></head>
+<body><div style="background-image:url(img/b.png)"></div><img src="img/b.png"><a href="next">next</a></body></html>`,
+		"/css/main.css":      `@import "sub/extra.css"; body{background:url(../img/a.png)}`,
+		"/css/sub/extra.css": `.x{background:url(../../img/b.png)}`,
+		"/img/a.png":         "PNG-A",
+		"/img/b.png":         "PNG-B",
+	})
+
+	input, err := capturePage(context.Background(), server.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if input.Title != "Page" {
+		t.Fatalf("title = %q", input.Title)
+	}
+	a, b := dataURIOf("image/png", "PNG-A"), dataURIOf("image/png", "PNG-B")
+	for _, want := range []string{
+		`.a{background:url("` + a + `")}`,
+		`.b{background:url("` + a + `")}`,
+		`.c{background:url("` + server.URL + `/missing.png")}`,
+		`body{background:url("` + a + `")}`,
+		`.x{background:url("` + b + `")}`,
+		`style="background-image:url(&#34;` + b + `&#34;)"`,
+		`<img src="` + b + `"/>`,
+		`href="` + server.URL + `/next"`,
+	} {
+		if !strings.Contains(input.HTML, want) {
+			t.Errorf("output missing %s\n%s", want, input.HTML)
+		}
+	}
+	for _, unwanted := range []string{"<script", "@import", `rel="stylesheet"`} {
+		if strings.Contains(input.HTML, unwanted) {
+			t.Errorf("output still contains %s", unwanted)
+		}
+	}
+}
+
+func TestInlineCSSURLsManyRefs(t *testing.T) {
+	routes := map[string]string{}
+	var css strings.Builder
+	for i := range 50 {
+		name := "/img/" + strings.Repeat("x", i+1) + ".png"
+		routes[name] = name
+		css.WriteString(".r{background:url(" + name + ")} .s{background:url(" + name + ")}\n")
+	}
+	server, hits := captureTestServer(t, routes)
+	base, _ := url.Parse(server.URL + "/")
+	f := &pageFetcher{
+		ctx:     context.Background(),
+		client:  server.Client(),
+		workers: make(chan struct{}, captureWorkers),
+		cache:   map[string]*fetchEntry{},
+	}
+
+	out := f.inlineCSSURLs(css.String(), base)
+	if strings.Contains(out, "url(/img/") {
+		t.Fatalf("unresolved url() left:\n%s", out)
+	}
+	if got := hits.Load(); got != 50 {
+		t.Fatalf("requests = %d, want each resource fetched once (50)", got)
+	}
+}
+
+func TestCapturePageFollowsRedirects(t *testing.T) {
+	server, _ := captureTestServer(t, map[string]string{
+		"/new/page.html": `<html><head><title></title></head><body><img src="pic.png"></body></html>`,
+		"/new/pic.png":   "PIC",
+	})
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, server.URL+"/new/page.html", http.StatusFound)
+	}))
+	defer redirect.Close()
+
+	input, err := capturePage(context.Background(), redirect.URL+"/old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if input.URL != redirect.URL+"/old" {
+		t.Fatalf("url = %q, want the requested address", input.URL)
+	}
+	if input.Title != server.URL+"/new/page.html" {
+		t.Fatalf("title = %q, want the final address", input.Title)
+	}
+	if !strings.Contains(input.HTML, dataURIOf("image/png", "PIC")) {
+		t.Fatalf("relative image not resolved against the final address:\n%s", input.HTML)
+	}
+}
+
+func TestCapturePageLimits(t *testing.T) {
+	server, _ := captureTestServer(t, map[string]string{
+		"/":        `<html><body><img src="/big.png"></body></html>`,
+		"/big.png": strings.Repeat("x", maxCaptureResource+1),
+		"/huge":    "<html><body>" + strings.Repeat("x", maxCapturePageSize) + "</body></html>",
+	})
+
+	input, err := capturePage(context.Background(), server.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(input.HTML, `<img src="`+server.URL+`/big.png"/>`) {
+		t.Fatalf("oversized image should stay an absolute link:\n%s", input.HTML)
+	}
+
+	if _, err := capturePage(context.Background(), server.URL+"/huge"); err == nil || err.Error() != "页面过大" {
+		t.Fatalf("oversized page error = %v", err)
+	}
+	if _, err := capturePage(context.Background(), server.URL+"/big.png"); err == nil || !strings.Contains(err.Error(), "不是 HTML 页面") {
+		t.Fatalf("non-HTML error = %v", err)
+	}
+	if _, err := capturePage(context.Background(), "ftp://example.com/"); err == nil {
+		t.Fatal("non-http scheme accepted")
+	}
+}
@@ -0,0 +1,151 @@
+package app
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/fnv"
+	"html"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math/bits"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const shingleSize = 3
+
+var (
+	invisibleBlockRe = regexp.MustCompile(`(?is)<(script|style|noscript|template|svg)\b.*?</(script|style|noscript|template|svg)>`)
+	htmlCommentRe    = regexp.MustCompile(`(?s)<!--.*?-->`)
+	htmlTagRe        = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+type fingerprint struct {
+	ContentHash string
+	TextSimhash string
+	ThumbHash   string
+}
+
+func computeFingerprint(htmlData, thumbData []byte) fingerprint {
+	sum := sha256.Sum256(htmlData)
+	fp := fingerprint{ContentHash: hex.EncodeToString(sum[:])}
+	if hash, ok := simhash(extractText(string(htmlData))); ok {
+		fp.TextSimhash = formatHash(hash)
+	}
+	if hash, ok := differenceHash(thumbData); ok {
+		fp.ThumbHash = formatHash(hash)
+	}
+	return fp
+}
+
+func extractText(document string) string {
+	text := invisibleBlockRe.ReplaceAllString(document, " ")
+	text = htmlCommentRe.ReplaceAllString(text, " ")
+	text = htmlTagRe.ReplaceAllString(text, " ")
+	return strings.Join(strings.Fields(html.UnescapeString(text)), " ")
+}
+
+func simhash(text string) (uint64, bool) {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) == 0 {
+		return 0, false
+	}
+
+	var weights [64]int
+	addShingle := func(shingle string) {
+		hasher := fnv.New64a()
+		_, _ = hasher.Write([]byte(shingle))
+		value := hasher.Sum64()
+		for bit := 0; bit < 64; bit++ {
+			if value&(1<<uint(bit)) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+	if len(words) < shingleSize {
+		addShingle(strings.Join(words, " "))
+	}
+	for i := 0; i+shingleSize <= len(words); i++ {
+		addShingle(strings.Join(words[i:i+shingleSize], " "))
+	}
+
+	var hash uint64
+	for bit, weight := range weights {
+		if weight > 0 {
+			hash |= 1 << uint(bit)
+		}
+	}
+	return hash, true
+}
+
+// differenceHash 计算 9x8 灰度缩略图的 dHash，用于比较截图是否近似。
+func differenceHash(data []byte) (uint64, bool) {
+	if len(data) == 0 {
+		return 0, false
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return 0, false
+	}
+	bounds := img.Bounds()
+	if bounds.Dx() < 9 || bounds.Dy() < 8 {
+		return 0, false
+	}
+
+	var gray [8][9]float64
+	for y := 0; y < 8; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/8
+		y1 := bounds.Min.Y + (y+1)*bounds.Dy()/8
+		for x := 0; x < 9; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/9
+			x1 := bounds.Min.X + (x+1)*bounds.Dx()/9
+			var sum float64
+			var count int
+			for py := y0; py < y1; py += max(1, (y1-y0)/8) {
+				for px := x0; px < x1; px += max(1, (x1-x0)/8) {
+					r, g, b, _ := img.At(px, py).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+					count++
+				}
+			}
+			if count > 0 {
+				gray[y][x] = sum / float64(count)
+			}
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if gray[y][x] > gray[y][x+1] {
+				hash |= 1 << uint(y*8+x)
+			}
+		}
+	}
+	return hash, true
+}
+
+func formatHash(value uint64) string {
+	return strconv.FormatUint(value, 16)
+}
+
+func parseHash(value string) (uint64, bool) {
+	if value == "" {
+		return 0, false
+	}
+	parsed, err := strconv.ParseUint(value, 16, 64)
+	return parsed, err == nil
+}
+
+func hammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
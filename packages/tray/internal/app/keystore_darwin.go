@@ -0,0 +1,59 @@
+//go:build darwin
+
+package app
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+const (
+	keychainPrefix  = "keychain:"
+	keychainService = "chrome-collect"
+	securityTool    = "/usr/bin/security"
+)
+
+// keystoreSave 把凭据存入登录钥匙串，数据库里只记录条目名称。
+// 命令经 security -i 从标准输入传入，密码不会出现在进程参数里；内容按十六进制保存，避免转义问题。
+func keystoreSave(account, secret string) (string, error) {
+	cmd := exec.Command(securityTool, "-i")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n",
+		keychainService, account, hex.EncodeToString([]byte(secret))))
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("写入钥匙串失败: %s", strings.TrimSpace(string(output)))
+	}
+	// security -i 遇到错误时退出码仍可能为 0，读回来确认已经写入。
+	ref := keychainPrefix + account
+	if stored, err := keystoreLoad(account, ref); err != nil || stored != secret {
+		return "", errors.New("写入钥匙串失败")
+	}
+	return ref, nil
+}
+
+func keystoreLoad(account, ref string) (string, error) {
+	if ref != keychainPrefix+account {
+		return "", errors.New("不是钥匙串保存的凭据")
+	}
+	output, err := exec.Command(securityTool, "find-generic-password", "-s", keychainService, "-a", account, "-w").Output()
+	if err != nil {
+		return "", fmt.Errorf("读取钥匙串失败: %w", err)
+	}
+	secret, err := hex.DecodeString(strings.TrimSpace(string(output)))
+	if err != nil {
+		return "", err
+	}
+	return string(secret), nil
+}
+
+func keystoreDelete(account, ref string) error {
+	err := exec.Command(securityTool, "delete-generic-password", "-s", keychainService, "-a", account).Run()
+	// 44 表示条目不存在
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 44 {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,52 @@
+//go:build windows
+
+package app
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const dpapiPrefix = "dpapi:"
+
+// keystoreSave 用 DPAPI 以当前 Windows 用户身份加密凭据，只有同一台机器上的同一用户能解开；
+// account 作为附加熵，密文不能挪给其他条目使用。
+func keystoreSave(account, secret string) (string, error) {
+	var out windows.DataBlob
+	err := windows.CryptProtectData(dataBlob([]byte(secret)), nil, dataBlob([]byte(account)), 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out)
+	if err != nil {
+		return "", err
+	}
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(out.Data)))
+	return dpapiPrefix + base64.StdEncoding.EncodeToString(unsafe.Slice(out.Data, out.Size)), nil
+}
+
+func keystoreLoad(account, ref string) (string, error) {
+	if !strings.HasPrefix(ref, dpapiPrefix) {
+		return "", errors.New("不是 DPAPI 保存的凭据")
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(ref, dpapiPrefix))
+	if err != nil {
+		return "", err
+	}
+	var out windows.DataBlob
+	err = windows.CryptUnprotectData(dataBlob(data), nil, dataBlob([]byte(account)), 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out)
+	if err != nil {
+		return "", err
+	}
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(out.Data)))
+	return string(unsafe.Slice(out.Data, out.Size)), nil
+}
+
+// keystoreDelete 无事可做：DPAPI 密文只保存在数据库中，随设置一起清除。
+func keystoreDelete(account, ref string) error {
+	return nil
+}
+
+func dataBlob(data []byte) *windows.DataBlob {
+	return &windows.DataBlob{Size: uint32(len(data)), Data: unsafe.SliceData(data)}
+}
@@ -0,0 +1,397 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"chrome-collect-tray/internal/protocol"
+)
+
+const (
+	metaLinkCheckInterval = "linkcheck_interval_hours"
+	maxLinkCheckInterval  = 24 * 90
+	linkCheckBatch        = 200
+	linkCheckWorkers      = 4
+	linkCheckHostDelay    = 5 * time.Second
+	linkCheckTimeout      = 20 * time.Second
+	linkCheckMaxFailures  = 3
+	linkCheckUserAgent    = "Mozilla/5.0 (compatible; chrome-collect-linkcheck)"
+
+	linkStateOK         = "ok"
+	linkStateDead       = "dead"
+	linkStateRedirected = "redirected"
+	linkStateChanged    = "changed"
+	linkStateError      = "error"
+)
+
+type LinkStatus struct {
+	BookmarkID string `json:"bookmarkId"`
+	Title      string `json:"title"`
+	URL        string `json:"url"`
+	State      string `json:"state"`
+	StatusCode int    `json:"statusCode"`
+	FinalURL   string `json:"finalUrl,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Failures   int    `json:"failures"`
+	CheckedAt  int64  `json:"checkedAt"`
+	ChangedAt  int64  `json:"changedAt,omitempty"`
+}
+
+type LinkCheckReport struct {
+	Dead          []LinkStatus `json:"dead"`
+	Redirected    []LinkStatus `json:"redirected"`
+	Changed       []LinkStatus `json:"changed"`
+	Checked       int          `json:"checked"`
+	Unchecked     int          `json:"unchecked"`
+	Failing       int          `json:"failing"`
+	IntervalHours int          `json:"intervalHours"`
+}
+
+type LinkCheckResult struct {
+	Checked int          `json:"checked"`
+	Items   []LinkStatus `json:"items"`
+}
+
+type linkTarget struct {
+	id    string
+	url   string
+	title string
+}
+
+type linkProbe struct {
+	target      linkTarget
+	statusCode  int
+	finalURL    string
+	fingerprint string
+	err         error
+	checkedAt   int64
+}
+
+func (s *Service) SetLinkCheckInterval(hours int) (Settings, error) {
+	if hours < 0 || hours > maxLinkCheckInterval {
+		return Settings{}, fmt.Errorf("检查间隔需在 0 到 %d 小时之间", maxLinkCheckInterval)
+	}
+	if err := s.setSetting(protocol.MethodSettingsLinkCheck, metaLinkCheckInterval, strconv.Itoa(hours)); err != nil {
+		return Settings{}, err
+	}
+	return s.GetSettings(), nil
+}
+
+// linkCheckInterval 为 0 表示不自动检查，默认关闭以免未经同意访问收藏的网址。
+func (s *Service) linkCheckInterval() int {
+	value, err := s.getMeta(metaLinkCheckInterval)
+	if err != nil || value == "" {
+		return 0
+	}
+	hours, convErr := strconv.Atoi(value)
+	if convErr != nil || hours < 0 {
+		return 0
+	}
+	return hours
+}
+
+// RunLinkChecker 每个周期检查一批超过间隔未检查的收藏，直到 ctx 取消。
+func (s *Service) RunLinkChecker(ctx context.Context, tick time.Duration) {
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if hours := s.linkCheckInterval(); hours > 0 {
+			_, _ = s.checkDueLinks(ctx, time.Duration(hours)*time.Hour)
+		}
+	}
+}
+
+// CheckLinks 立即检查指定收藏；ids 为空时检查最久未检查的一批。
+func (s *Service) CheckLinks(ids []string) (*LinkCheckResult, error) {
+	if len(ids) == 0 {
+		return s.checkDueLinks(context.Background(), 0)
+	}
+	if len(ids) > linkCheckBatch {
+		return nil, fmt.Errorf("一次最多检查 %d 条", linkCheckBatch)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	targets, err := s.linkTargets("SELECT id, url, title FROM bookmarks WHERE deleted_at = 0 AND id IN ("+placeholders+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	return s.checkLinks(context.Background(), targets)
+}
+
+func (s *Service) checkDueLinks(ctx context.Context, maxAge time.Duration) (*LinkCheckResult, error) {
+	cutoff := time.Now().Add(-maxAge).UnixMilli()
+	targets, err := s.linkTargets(`SELECT b.id, b.url, b.title FROM bookmarks b
+		LEFT JOIN link_checks c ON c.bookmark_id = b.id
+		WHERE b.deleted_at = 0 AND COALESCE(c.checked_at, 0) < ?
+		ORDER BY COALESCE(c.checked_at, 0) ASC, b.created_at ASC LIMIT ?`, cutoff, linkCheckBatch)
+	if err != nil {
+		return nil, err
+	}
+	return s.checkLinks(ctx, targets)
+}
+
+func (s *Service) linkTargets(query string, args ...any) ([]linkTarget, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var targets []linkTarget
+	for rows.Next() {
+		var target linkTarget
+		if err := rows.Scan(&target.id, &target.url, &target.title); err != nil {
+			return nil, err
+		}
+		target.title = s.revealField(target.title)
+		targets = append(targets, target)
+	}
+	return targets, rows.Err()
+}
+
+// checkLinks 按主机分组：同一主机的请求依次间隔 linkCheckHostDelay，不同主机之间最多并发 linkCheckWorkers 个。
+func (s *Service) checkLinks(ctx context.Context, targets []linkTarget) (*LinkCheckResult, error) {
+	if !s.linkCheckMu.TryLock() {
+		return nil, errors.New("链接检查正在进行中")
+	}
+	defer s.linkCheckMu.Unlock()
+
+	hosts := map[string][]linkTarget{}
+	for _, target := range targets {
+		parsed, err := url.Parse(target.url)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			continue
+		}
+		host := strings.ToLower(parsed.Host)
+		hosts[host] = append(hosts[host], target)
+	}
+
+	client := &http.Client{Timeout: linkCheckTimeout}
+	workers := make(chan struct{}, linkCheckWorkers)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var probes []linkProbe
+	for _, queue := range hosts {
+		wg.Add(1)
+		go func(queue []linkTarget) {
+			defer wg.Done()
+			for i, target := range queue {
+				if i > 0 {
+					select {
+					case <-ctx.Done():
+						return
+					case <-time.After(linkCheckHostDelay):
+					}
+				}
+				workers <- struct{}{}
+				probe := probeLink(ctx, client, target)
+				<-workers
+				if ctx.Err() != nil {
+					return
+				}
+				mu.Lock()
+				probes = append(probes, probe)
+				mu.Unlock()
+			}
+		}(queue)
+	}
+	wg.Wait()
+
+	result := &LinkCheckResult{Items: []LinkStatus{}}
+	if len(probes) == 0 {
+		return result, nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	for _, probe := range probes {
+		status, err := s.recordLinkCheck(tx, probe)
+		if err != nil {
+			return nil, err
+		}
+		result.Items = append(result.Items, status)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	result.Checked = len(result.Items)
+	return result, nil
+}
+
+// probeLink 先发 HEAD，服务器不支持或拒绝时改用 GET；只读取响应头，不下载正文。
+func probeLink(ctx context.Context, client *http.Client, target linkTarget) linkProbe {
+	resp, err := linkRequest(ctx, client, http.MethodHead, target.url)
+	if err != nil || resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented || resp.StatusCode == http.StatusForbidden {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		resp, err = linkRequest(ctx, client, http.MethodGet, target.url)
+	}
+	probe := linkProbe{target: target, checkedAt: time.Now().UnixMilli()}
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		probe.err = err
+		return probe
+	}
+	defer resp.Body.Close()
+
+	probe.statusCode = resp.StatusCode
+	probe.finalURL = resp.Request.URL.String()
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		probe.fingerprint = "etag:" + etag
+	} else if modified := resp.Header.Get("Last-Modified"); modified != "" {
+		probe.fingerprint = "modified:" + modified
+	}
+	return probe
+}
+
+func linkRequest(ctx context.Context, client *http.Client, method, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", linkCheckUserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,*/*;q=0.8")
+	return client.Do(req)
+}
+
+// recordLinkCheck 根据本次结果和上次记录推算状态。首次拿到的 ETag/Last-Modified 作为基线，之后与基线不同即视为页面已变化；
+// 404/410 直接判为失效，其他错误连续 linkCheckMaxFailures 次后才判为失效。
+func (s *Service) recordLinkCheck(tx *sql.Tx, probe linkProbe) (LinkStatus, error) {
+	var baseline string
+	var failures int
+	var changedAt int64
+	err := tx.QueryRow("SELECT baseline, failures, changed_at FROM link_checks WHERE bookmark_id = ?", probe.target.id).Scan(&baseline, &failures, &changedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return LinkStatus{}, err
+	}
+
+	status := LinkStatus{
+		BookmarkID: probe.target.id,
+		Title:      probe.target.title,
+		URL:        probe.target.url,
+		StatusCode: probe.statusCode,
+		CheckedAt:  probe.checkedAt,
+	}
+	switch {
+	case probe.err != nil || probe.statusCode >= 400:
+		failures++
+		if probe.err != nil {
+			status.Error = probe.err.Error()
+		} else {
+			status.Error = http.StatusText(probe.statusCode)
+		}
+		status.State = linkStateError
+		if failures >= linkCheckMaxFailures || probe.statusCode == http.StatusNotFound || probe.statusCode == http.StatusGone {
+			status.State = linkStateDead
+		}
+	default:
+		failures = 0
+		if baseline == "" {
+			baseline = probe.fingerprint
+		}
+		if probe.fingerprint != "" && fingerprintKind(probe.fingerprint) == fingerprintKind(baseline) && probe.fingerprint != baseline {
+			if changedAt == 0 {
+				changedAt = probe.checkedAt
+			}
+		} else {
+			changedAt = 0
+		}
+		switch {
+		case !s.sameLinkTarget(probe.target.url, probe.finalURL):
+			status.State = linkStateRedirected
+			status.FinalURL = probe.finalURL
+		case changedAt > 0:
+			status.State = linkStateChanged
+		default:
+			status.State = linkStateOK
+		}
+	}
+	status.Failures = failures
+	status.ChangedAt = changedAt
+
+	_, err = tx.Exec(`INSERT INTO link_checks (bookmark_id, state, status_code, final_url, error, baseline, fingerprint, failures, checked_at, changed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(bookmark_id) DO UPDATE SET state = excluded.state, status_code = excluded.status_code, final_url = excluded.final_url,
+			error = excluded.error, baseline = excluded.baseline, fingerprint = excluded.fingerprint, failures = excluded.failures,
+			checked_at = excluded.checked_at, changed_at = excluded.changed_at`,
+		status.BookmarkID, status.State, status.StatusCode, status.FinalURL, status.Error, baseline, probe.fingerprint, failures, status.CheckedAt, changedAt)
+	return status, err
+}
+
+func fingerprintKind(fingerprint string) string {
+	kind, _, _ := strings.Cut(fingerprint, ":")
+	return kind
+}
+
+// sameLinkTarget 忽略协议升级和 www 前缀这类无关紧要的跳转。
+func (s *Service) sameLinkTarget(original, final string) bool {
+	strip := func(rawURL string) string {
+		_, rest, _ := strings.Cut(s.normalizeURL(rawURL), "://")
+		return strings.TrimPrefix(rest, "www.")
+	}
+	return strip(original) == strip(final)
+}
+
+func (s *Service) LinkCheckReport() (*LinkCheckReport, error) {
+	report := &LinkCheckReport{
+		Dead:          []LinkStatus{},
+		Redirected:    []LinkStatus{},
+		Changed:       []LinkStatus{},
+		IntervalHours: s.linkCheckInterval(),
+	}
+	err := s.db.QueryRow(`SELECT COUNT(c.bookmark_id), COUNT(*) - COUNT(c.bookmark_id), COALESCE(SUM(c.state = ?), 0)
+		FROM bookmarks b LEFT JOIN link_checks c ON c.bookmark_id = b.id WHERE b.deleted_at = 0`, linkStateError).
+		Scan(&report.Checked, &report.Unchecked, &report.Failing)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`SELECT c.bookmark_id, b.title, b.url, c.state, c.status_code, c.final_url, c.error, c.failures, c.checked_at, c.changed_at
+		FROM link_checks c JOIN bookmarks b ON b.id = c.bookmark_id
+		WHERE b.deleted_at = 0 AND c.state IN (?, ?, ?) ORDER BY c.checked_at DESC`,
+		linkStateDead, linkStateRedirected, linkStateChanged)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var status LinkStatus
+		if err := rows.Scan(&status.BookmarkID, &status.Title, &status.URL, &status.State, &status.StatusCode, &status.FinalURL,
+			&status.Error, &status.Failures, &status.CheckedAt, &status.ChangedAt); err != nil {
+			return nil, err
+		}
+		status.Title = s.revealField(status.Title)
+		switch status.State {
+		case linkStateDead:
+			report.Dead = append(report.Dead, status)
+		case linkStateRedirected:
+			report.Redirected = append(report.Redirected, status)
+		case linkStateChanged:
+			report.Changed = append(report.Changed, status)
+		}
+	}
+	return report, rows.Err()
+}
@@ -0,0 +1,139 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func checkOneLink(t *testing.T, s *Service, id string) LinkStatus {
+	t.Helper()
+	// 逐条检查，避免同一主机的请求之间等待 linkCheckHostDelay。
+	result, err := s.CheckLinks([]string{id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Checked != 1 {
+		t.Fatalf("checked = %d", result.Checked)
+	}
+	return result.Items[0]
+}
+
+func TestCheckLinksStates(t *testing.T) {
+	var etag atomic.Value
+	etag.Store(`"v1"`)
+	var headRefused atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", etag.Load().(string))
+	})
+	mux.HandleFunc("/gone", http.NotFound)
+	mux.HandleFunc("/flaky", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/elsewhere", http.StatusFound)
+	})
+	mux.HandleFunc("/elsewhere", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/get-only", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			headRefused.Add(1)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	s := newTestService(t)
+	page := saveTestBookmark(t, s, srv.URL+"/page", "Page", "<p>page</p>")
+	gone := saveTestBookmark(t, s, srv.URL+"/gone", "Gone", "<p>gone</p>")
+	flaky := saveTestBookmark(t, s, srv.URL+"/flaky", "Flaky", "<p>flaky</p>")
+	moved := saveTestBookmark(t, s, srv.URL+"/moved", "Moved", "<p>moved</p>")
+	getOnly := saveTestBookmark(t, s, srv.URL+"/get-only", "GET only", "<p>get</p>")
+	saveTestBookmark(t, s, srv.URL+"/unchecked", "Unchecked", "<p>unchecked</p>")
+
+	if status := checkOneLink(t, s, page.ID); status.State != linkStateOK {
+		t.Fatalf("first check = %+v", status)
+	}
+	etag.Store(`"v2"`)
+	status := checkOneLink(t, s, page.ID)
+	if status.State != linkStateChanged || status.ChangedAt == 0 {
+		t.Fatalf("check after ETag change = %+v", status)
+	}
+	// 变化时间记录首次发现变化的时刻，之后再查不会刷新。
+	if again := checkOneLink(t, s, page.ID); again.State != linkStateChanged || again.ChangedAt != status.ChangedAt {
+		t.Fatalf("repeated check = %+v, want changedAt %d", again, status.ChangedAt)
+	}
+
+	if status := checkOneLink(t, s, gone.ID); status.State != linkStateDead || status.StatusCode != http.StatusNotFound {
+		t.Fatalf("404 = %+v", status)
+	}
+	if status := checkOneLink(t, s, moved.ID); status.State != linkStateRedirected || status.FinalURL != srv.URL+"/elsewhere" {
+		t.Fatalf("redirect = %+v", status)
+	}
+	if status := checkOneLink(t, s, getOnly.ID); status.State != linkStateOK || headRefused.Load() != 1 {
+		t.Fatalf("HEAD fallback = %+v, refused %d", status, headRefused.Load())
+	}
+
+	for want := 1; want < linkCheckMaxFailures; want++ {
+		if status := checkOneLink(t, s, flaky.ID); status.State != linkStateError || status.Failures != want {
+			t.Fatalf("failure %d = %+v", want, status)
+		}
+	}
+	report, err := s.LinkCheckReport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Checked != 5 || report.Unchecked != 1 || report.Failing != 1 {
+		t.Fatalf("report counts = %+v", report)
+	}
+	if status := checkOneLink(t, s, flaky.ID); status.State != linkStateDead || status.Failures != linkCheckMaxFailures {
+		t.Fatalf("failure %d = %+v", linkCheckMaxFailures, status)
+	}
+
+	report, err = s.LinkCheckReport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Dead) != 2 || len(report.Redirected) != 1 || len(report.Changed) != 1 || report.Failing != 0 {
+		t.Fatalf("report = %+v", report)
+	}
+	if report.Changed[0].BookmarkID != page.ID || report.Redirected[0].BookmarkID != moved.ID {
+		t.Fatalf("report items = %+v", report)
+	}
+
+	// 恢复正常后清零失败次数并移出失效列表。
+	mux.HandleFunc("/recovered", func(w http.ResponseWriter, r *http.Request) {})
+	if _, err := s.db.Exec("UPDATE bookmarks SET url = ? WHERE id = ?", srv.URL+"/recovered", flaky.ID); err != nil {
+		t.Fatal(err)
+	}
+	if status := checkOneLink(t, s, flaky.ID); status.State != linkStateOK || status.Failures != 0 {
+		t.Fatalf("recovered = %+v", status)
+	}
+}
+
+func TestLinkCheckSettings(t *testing.T) {
+	s := newTestService(t)
+	for _, hours := range []int{-1, maxLinkCheckInterval + 1} {
+		if _, err := s.SetLinkCheckInterval(hours); err == nil {
+			t.Fatalf("interval %d accepted", hours)
+		}
+	}
+	if _, err := s.SetLinkCheckInterval(24); err != nil {
+		t.Fatal(err)
+	}
+	report, err := s.LinkCheckReport()
+	if err != nil || report.IntervalHours != 24 {
+		t.Fatalf("report = %+v, %v", report, err)
+	}
+
+	if _, err := s.CheckLinks(make([]string, linkCheckBatch+1)); err == nil {
+		t.Fatal("oversized batch accepted")
+	}
+	// 非 http(s) 网址不发请求，也不记录结果。
+	bm := saveTestBookmark(t, s, "file:///tmp/page.html", "Local", "<p>local</p>")
+	if result, err := s.CheckLinks([]string{bm.ID}); err != nil || result.Checked != 0 {
+		t.Fatalf("local file check = %+v, %v", result, err)
+	}
+}
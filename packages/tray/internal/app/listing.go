@@ -0,0 +1,331 @@
+package app
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	defaultOrderBy  = "created_at DESC, id DESC"
+	defaultPageSize = 50
+	maxPageSize     = 1000
+	maxFacetDomains = 50
+)
+
+type sortColumn struct {
+	expr    string
+	numeric bool
+	value   func(Bookmark) any
+}
+
+var sortColumns = map[string]sortColumn{
+	"":           {expr: "created_at", numeric: true, value: func(b Bookmark) any { return b.CreatedAt }},
+	"created":    {expr: "created_at", numeric: true, value: func(b Bookmark) any { return b.CreatedAt }},
+	"title":      {expr: "title COLLATE NOCASE", value: func(b Bookmark) any { return b.Title }},
+	"alias":      {expr: "alias COLLATE NOCASE", value: func(b Bookmark) any { return b.Alias }},
+	"size":       {expr: "file_size", numeric: true, value: func(b Bookmark) any { return b.FileSize }},
+	"domain":     {expr: "domain", value: func(b Bookmark) any { return b.Domain }},
+	"lastOpened": {expr: "last_opened_at", numeric: true, value: func(b Bookmark) any { return b.LastOpenedAt }},
+}
+
+type ListQuery struct {
+	Limit     int    `json:"limit"`
+	Offset    int    `json:"offset"`
+	Cursor    string `json:"cursor"`
+	SkipTotal bool   `json:"skipTotal"`
+	Q         string `json:"q"`
+	Sort      string `json:"sort"`
+	Order     string `json:"order"`
+	Facets    bool   `json:"facets"`
+	BookmarkFilter
+}
+
+type TrashQuery struct {
+	Limit     int    `json:"limit"`
+	Cursor    string `json:"cursor"`
+	SkipTotal bool   `json:"skipTotal"`
+}
+
+type BookmarkFilter struct {
+	ReadStatus   string `json:"readStatus"`
+	Starred      *bool  `json:"starred"`
+	Archived     *bool  `json:"archived"`
+	ColorLabel   string `json:"colorLabel"`
+	Domain       string `json:"domain"`
+	DateFrom     int64  `json:"dateFrom"`
+	DateTo       int64  `json:"dateTo"`
+	SizeMin      int64  `json:"sizeMin"`
+	SizeMax      int64  `json:"sizeMax"`
+	HasNotes     *bool  `json:"hasNotes"`
+	HasThumbnail *bool  `json:"hasThumbnail"`
+}
+
+type Facets struct {
+	Domains []FacetCount `json:"domains"`
+	Months  []FacetCount `json:"months"`
+}
+
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// listCursor 记录上一页最后一条的排序键，created_at + id 作为稳定的次级排序。
+type listCursor struct {
+	Sort      string `json:"s,omitempty"`
+	Order     string `json:"o,omitempty"`
+	Value     any    `json:"v,omitempty"`
+	CreatedAt int64  `json:"c"`
+	ID        string `json:"i"`
+}
+
+func encodeListCursor(cursor listCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeListCursor(raw string) (listCursor, error) {
+	var cursor listCursor
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return cursor, fmt.Errorf("无效的分页游标")
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&cursor); err != nil || cursor.ID == "" {
+		return cursor, fmt.Errorf("无效的分页游标")
+	}
+	return cursor, nil
+}
+
+func pageLimit(limit int) int {
+	if limit <= 0 {
+		return defaultPageSize
+	}
+	if limit > maxPageSize {
+		return maxPageSize
+	}
+	return limit
+}
+
+func (q ListQuery) sortColumn(encrypted bool) (sortColumn, error) {
+	column, ok := sortColumns[q.Sort]
+	if !ok {
+		return column, fmt.Errorf("不支持的排序字段: %s", q.Sort)
+	}
+	// 加密后数据库里只有密文，按它排序没有意义
+	if encrypted && (q.Sort == "title" || q.Sort == "alias") {
+		return column, errors.New("资料库已加密，无法按标题或别名排序")
+	}
+	switch strings.ToLower(q.Order) {
+	case "", "asc", "desc":
+	default:
+		return column, fmt.Errorf("无效的排序方向: %s", q.Order)
+	}
+	return column, nil
+}
+
+func (q ListQuery) descending() bool {
+	return strings.ToLower(q.Order) != "asc"
+}
+
+func (c sortColumn) orderBy(desc bool) string {
+	direction := "ASC"
+	if desc {
+		direction = "DESC"
+	}
+	if c.expr == "created_at" {
+		return "created_at " + direction + ", id " + direction
+	}
+	return c.expr + " " + direction + ", " + defaultOrderBy
+}
+
+// after 生成“排在游标之后”的条件，必须与 orderBy 的排序方向保持一致。
+func (c sortColumn) after(cursor listCursor, sort string, desc bool) (string, []any, error) {
+	if cursor.Sort != sort || (cursor.Order == "asc") != !desc {
+		return "", nil, fmt.Errorf("分页游标与排序条件不匹配")
+	}
+	op := ">"
+	if desc {
+		op = "<"
+	}
+	if c.expr == "created_at" {
+		return "(created_at " + op + " ? OR (created_at = ? AND id " + op + " ?))",
+			[]any{cursor.CreatedAt, cursor.CreatedAt, cursor.ID}, nil
+	}
+
+	value, err := c.cursorValue(cursor.Value)
+	if err != nil {
+		return "", nil, err
+	}
+	return "(" + c.expr + " " + op + " ? OR (" + c.expr + " = ? AND (created_at < ? OR (created_at = ? AND id < ?))))",
+		[]any{value, value, cursor.CreatedAt, cursor.CreatedAt, cursor.ID}, nil
+}
+
+func (c sortColumn) cursorValue(raw any) (any, error) {
+	if c.numeric {
+		number, ok := raw.(json.Number)
+		if raw == nil {
+			return int64(0), nil
+		}
+		if !ok {
+			return nil, fmt.Errorf("无效的分页游标")
+		}
+		return number.Int64()
+	}
+	if raw == nil {
+		return "", nil
+	}
+	text, ok := raw.(string)
+	if !ok {
+		return nil, fmt.Errorf("无效的分页游标")
+	}
+	return text, nil
+}
+
+func (f BookmarkFilter) validate() error {
+	if f.ReadStatus != "" && !validReadStatus(f.ReadStatus) {
+		return fmt.Errorf("无效的阅读状态: %s", f.ReadStatus)
+	}
+	if f.ColorLabel != "" && !colorLabels[f.ColorLabel] {
+		return fmt.Errorf("无效的颜色标签: %s", f.ColorLabel)
+	}
+	if f.DateFrom > 0 && f.DateTo > 0 && f.DateFrom > f.DateTo {
+		return fmt.Errorf("起始日期不能晚于结束日期")
+	}
+	if f.SizeMin > 0 && f.SizeMax > 0 && f.SizeMin > f.SizeMax {
+		return fmt.Errorf("最小体积不能大于最大体积")
+	}
+	return nil
+}
+
+func (f BookmarkFilter) apply(where string, args []any) (string, []any) {
+	if f.ReadStatus != "" {
+		where += " AND read_status = ?"
+		args = append(args, f.ReadStatus)
+	}
+	if f.Starred != nil {
+		where += " AND starred = ?"
+		args = append(args, boolToInt(*f.Starred))
+	}
+	if f.Archived != nil {
+		where += " AND archived = ?"
+		args = append(args, boolToInt(*f.Archived))
+	}
+	if f.ColorLabel != "" {
+		where += " AND color_label = ?"
+		args = append(args, f.ColorLabel)
+	}
+	if f.Domain != "" {
+		where += " AND domain = ?"
+		args = append(args, strings.ToLower(f.Domain))
+	}
+	if f.DateFrom > 0 {
+		where += " AND created_at >= ?"
+		args = append(args, f.DateFrom)
+	}
+	if f.DateTo > 0 {
+		where += " AND created_at <= ?"
+		args = append(args, f.DateTo)
+	}
+	if f.SizeMin > 0 {
+		where += " AND file_size >= ?"
+		args = append(args, f.SizeMin)
+	}
+	if f.SizeMax > 0 {
+		where += " AND file_size <= ?"
+		args = append(args, f.SizeMax)
+	}
+	if f.HasNotes != nil {
+		if *f.HasNotes {
+			where += " AND notes != ''"
+		} else {
+			where += " AND notes = ''"
+		}
+	}
+	if f.HasThumbnail != nil {
+		if *f.HasThumbnail {
+			where += " AND thumb_path != ''"
+		} else {
+			where += " AND thumb_path = ''"
+		}
+	}
+	return where, args
+}
+
+// bookmarkFacets 统计每个分面时忽略该分面自身的筛选条件，便于前端切换选项。
+func (s *Service) bookmarkFacets(q string, filter BookmarkFilter) (*Facets, error) {
+	facets := &Facets{}
+
+	domainFilter := filter
+	domainFilter.Domain = ""
+	where, args := s.bookmarkWhere("deleted_at = 0", q, "", domainFilter)
+	var err error
+	facets.Domains, err = s.facetCounts("domain", where, "COUNT(*) DESC, value ASC LIMIT "+fmt.Sprint(maxFacetDomains), args)
+	if err != nil {
+		return nil, err
+	}
+
+	monthFilter := filter
+	monthFilter.DateFrom, monthFilter.DateTo = 0, 0
+	where, args = s.bookmarkWhere("deleted_at = 0", q, "", monthFilter)
+	facets.Months, err = s.facetCounts("strftime('%Y-%m', created_at / 1000, 'unixepoch', 'localtime')", where, "value DESC", args)
+	if err != nil {
+		return nil, err
+	}
+	return facets, nil
+}
+
+func (s *Service) facetCounts(expr, where, orderBy string, args []any) ([]FacetCount, error) {
+	rows, err := s.db.Query("SELECT "+expr+" AS value, COUNT(*) FROM bookmarks WHERE "+where+" GROUP BY value ORDER BY "+orderBy, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []FacetCount{}
+	for rows.Next() {
+		var count FacetCount
+		if err := rows.Scan(&count.Value, &count.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
+
+func (s *Service) backfillDomains() error {
+	rows, err := s.db.Query("SELECT id, url FROM bookmarks WHERE domain = '' OR domain IS NULL")
+	if err != nil {
+		return err
+	}
+	updates := map[string]string{}
+	for rows.Next() {
+		var id, rawURL string
+		if err := rows.Scan(&id, &rawURL); err != nil {
+			rows.Close()
+			return err
+		}
+		updates[id] = getDomain(rawURL)
+	}
+	rows.Close()
+	if len(updates) == 0 {
+		return rows.Err()
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for id, domain := range updates {
+		if _, err := tx.Exec("UPDATE bookmarks SET domain = ? WHERE id = ?", domain, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,263 @@
+package app
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+type listingRow struct {
+	url, title string
+	createdAt  int64
+	size       int64
+}
+
+// listingFixture 保存若干收藏，并直接改写创建时间、体积等列，使排序和筛选结果可预测。
+func listingFixture(t *testing.T, s *Service, rows []listingRow) []string {
+	t.Helper()
+	var ids []string
+	for _, row := range rows {
+		bm := saveTestBookmark(t, s, row.url, row.title, "<p>"+row.title+"</p>")
+		if _, err := s.db.Exec("UPDATE bookmarks SET created_at = ?, file_size = ? WHERE id = ?", row.createdAt, row.size, bm.ID); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, bm.ID)
+	}
+	return ids
+}
+
+func listIDs(t *testing.T, s *Service, query ListQuery) []string {
+	t.Helper()
+	result, err := s.ListBookmarks(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, item := range result.Items {
+		ids = append(ids, item.ID)
+	}
+	return ids
+}
+
+func TestListBookmarksSortAndFilter(t *testing.T) {
+	s := newTestService(t)
+	jan := time.Date(2024, 1, 15, 12, 0, 0, 0, time.Local).UnixMilli()
+	feb := time.Date(2024, 2, 15, 12, 0, 0, 0, time.Local).UnixMilli()
+	ids := listingFixture(t, s, []listingRow{
+		{"https://a.example/1", "banana", jan, 300},
+		{"https://b.example/1", "Apple", feb, 100},
+		{"https://a.example/2", "cherry", feb + 1, 200},
+	})
+	banana, apple, cherry := ids[0], ids[1], ids[2]
+	yes := true
+	if err := s.UpdateNotes(apple, "note"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RunBulk(BulkRequest{IDs: []string{cherry}, Action: bulkActionSetState, State: &StateUpdate{Starred: &yes}}); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name  string
+		query ListQuery
+		want  []string
+	}{
+		{"default newest first", ListQuery{}, []string{cherry, apple, banana}},
+		{"title ignores case", ListQuery{Sort: "title", Order: "asc"}, []string{apple, banana, cherry}},
+		{"size descending", ListQuery{Sort: "size"}, []string{banana, cherry, apple}},
+		{"domain filter is case-insensitive", ListQuery{BookmarkFilter: BookmarkFilter{Domain: "A.example"}}, []string{cherry, banana}},
+		{"date range", ListQuery{BookmarkFilter: BookmarkFilter{DateFrom: feb, DateTo: feb}}, []string{apple}},
+		{"size range", ListQuery{BookmarkFilter: BookmarkFilter{SizeMin: 150, SizeMax: 250}}, []string{cherry}},
+		{"has notes", ListQuery{BookmarkFilter: BookmarkFilter{HasNotes: &yes}}, []string{apple}},
+		{"starred", ListQuery{BookmarkFilter: BookmarkFilter{Starred: &yes}}, []string{cherry}},
+		{"query and filter combine", ListQuery{Q: "an", BookmarkFilter: BookmarkFilter{Domain: "a.example"}}, []string{banana}},
+	}
+	for _, c := range cases {
+		if got := listIDs(t, s, c.query); !slices.Equal(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+
+	for _, query := range []ListQuery{
+		{Sort: "url"},
+		{Order: "sideways"},
+		{BookmarkFilter: BookmarkFilter{ReadStatus: "skimmed"}},
+		{BookmarkFilter: BookmarkFilter{ColorLabel: "pink"}},
+		{BookmarkFilter: BookmarkFilter{DateFrom: feb, DateTo: jan}},
+		{BookmarkFilter: BookmarkFilter{SizeMin: 2, SizeMax: 1}},
+	} {
+		if _, err := s.ListBookmarks(query); err == nil {
+			t.Errorf("invalid query accepted: %+v", query)
+		}
+	}
+}
+
+func TestListBookmarksFacets(t *testing.T) {
+	s := newTestService(t)
+	jan := time.Date(2024, 1, 15, 12, 0, 0, 0, time.Local).UnixMilli()
+	feb := time.Date(2024, 2, 15, 12, 0, 0, 0, time.Local).UnixMilli()
+	listingFixture(t, s, []listingRow{
+		{"https://a.example/1", "one", jan, 1},
+		{"https://a.example/2", "two", feb, 1},
+		{"https://b.example/1", "three", feb, 1},
+	})
+
+	result, err := s.ListBookmarks(ListQuery{Facets: true, BookmarkFilter: BookmarkFilter{Domain: "b.example", DateFrom: feb}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *result.Total != 1 || len(result.Items) != 1 {
+		t.Fatalf("filtered result = %+v", result)
+	}
+	// 域名分面忽略域名筛选，但仍受日期筛选约束；月份分面反之。
+	wantDomains := []FacetCount{{"a.example", 1}, {"b.example", 1}}
+	if !slices.Equal(result.Facets.Domains, wantDomains) {
+		t.Fatalf("domain facets = %+v", result.Facets.Domains)
+	}
+	wantMonths := []FacetCount{{"2024-02", 1}}
+	if !slices.Equal(result.Facets.Months, wantMonths) {
+		t.Fatalf("month facets = %+v", result.Facets.Months)
+	}
+}
+
+func TestListBookmarksCursorPagination(t *testing.T) {
+	s := newTestService(t)
+	// 相同的创建时间、体积和标题制造排序键相同的情况，翻页时必须靠 id 区分，既不重复也不遗漏。
+	var rows []listingRow
+	for i := range 7 {
+		rows = append(rows, listingRow{"https://example.com/" + string(rune('a'+i)), []string{"Same", "other"}[i%2], int64(1000 + i/3), int64(i % 2)})
+	}
+	listingFixture(t, s, rows)
+
+	for _, query := range []ListQuery{{}, {Order: "asc"}, {Sort: "size"}, {Sort: "title", Order: "asc"}, {Sort: "domain"}} {
+		want := listIDs(t, s, query)
+		var got []string
+		page := query
+		page.Limit = 2
+		page.SkipTotal = true
+		for range 10 {
+			result, err := s.ListBookmarks(page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Total != nil {
+				t.Fatal("total computed although skipTotal was set")
+			}
+			for _, item := range result.Items {
+				got = append(got, item.ID)
+			}
+			if result.NextCursor == "" {
+				break
+			}
+			page.Cursor = result.NextCursor
+			// 按创建时间倒序翻页时新保存的收藏排在最前，不应让后续页面错位。
+			if query.Sort == "" && query.Order == "" && len(got) == 2 {
+				saveTestBookmark(t, s, "https://example.com/new", "new", "<p>new</p>")
+			}
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("sort %q %q: paged %v, want %v", query.Sort, query.Order, got, want)
+		}
+	}
+
+	first, err := s.ListBookmarks(ListQuery{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, query := range []ListQuery{
+		{Cursor: first.NextCursor, Sort: "size"},
+		{Cursor: first.NextCursor, Order: "asc"},
+		{Cursor: first.NextCursor, Offset: 1},
+		{Cursor: "not-a-cursor"},
+	} {
+		if _, err := s.ListBookmarks(query); err == nil {
+			t.Errorf("cursor misuse accepted: %+v", query)
+		}
+	}
+}
+
+func TestListTrashCursorPagination(t *testing.T) {
+	s := newTestService(t)
+	var ids []string
+	for i := range 5 {
+		ids = append(ids, saveTestBookmark(t, s, "https://example.com/"+string(rune('a'+i)), "T", "<p>t</p>").ID)
+	}
+	if _, err := s.RunBulk(BulkRequest{IDs: ids, Action: bulkActionTrash}); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	query := TrashQuery{Limit: 2}
+	for range 10 {
+		result, err := s.ListTrash(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *result.Total != 5 {
+			t.Fatalf("total = %d", *result.Total)
+		}
+		for _, item := range result.Items {
+			got = append(got, item.ID)
+		}
+		if result.NextCursor == "" {
+			break
+		}
+		query.Cursor = result.NextCursor
+	}
+	// 批量删除的 deleted_at 相同，按 id 倒序排列。
+	slices.Sort(ids)
+	slices.Reverse(ids)
+	if !slices.Equal(got, ids) {
+		t.Fatalf("trash pages = %v, want %v", got, ids)
+	}
+}
+
+func TestListBookmarksThumbnails(t *testing.T) {
+	s := newTestService(t)
+	listingFixture(t, s, []listingRow{
+		{url: "https://example.com/a", title: "A", createdAt: 1},
+		{url: "https://example.com/b", title: "B", createdAt: 2},
+		{url: "https://example.com/c", title: "C", createdAt: 3},
+	})
+
+	withThumbs, err := s.ListBookmarks(ListQuery{Limit: 2})
+	if err != nil || len(withThumbs.Items) != 2 || withThumbs.NextCursor == "" {
+		t.Fatalf("list = %+v, %v", withThumbs, err)
+	}
+	for _, item := range withThumbs.Items {
+		if item.ThumbData == "" {
+			t.Fatalf("%s listed without thumbnail", item.ID)
+		}
+	}
+
+	plain, err := s.listBookmarks(ListQuery{Limit: 2}, false)
+	if err != nil || len(plain.Items) != 2 || plain.NextCursor != withThumbs.NextCursor {
+		t.Fatalf("list without thumbnails = %+v, %v", plain, err)
+	}
+	for _, item := range plain.Items {
+		if item.ThumbData != "" || item.ThumbPath == "" {
+			t.Fatalf("%s: thumbnail read anyway (path %q)", item.ID, item.ThumbPath)
+		}
+	}
+}
+
+func TestExistsByURL(t *testing.T) {
+	s := newTestService(t)
+	ids := listingFixture(t, s, []listingRow{
+		{url: "https://example.com/kept", title: "Kept", createdAt: 1},
+		{url: "https://example.com/trashed", title: "Trashed", createdAt: 2},
+	})
+	if _, err := s.RunBulk(BulkRequest{IDs: ids[1:], Action: bulkActionTrash}); err != nil {
+		t.Fatal(err)
+	}
+	for rawURL, want := range map[string]bool{
+		"https://example.com/kept":    true,
+		"https://EXAMPLE.com/kept#x":  true,
+		"https://example.com/trashed": false,
+		"https://example.com/missing": false,
+	} {
+		if got, err := s.ExistsByURL(rawURL); err != nil || got != want {
+			t.Fatalf("ExistsByURL(%q) = %v, %v", rawURL, got, err)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+type SizeRecalcResult struct {
+	Checked int   `json:"checked"`
+	Updated int   `json:"updated"`
+	Missing int   `json:"missing"`
+	Total   int64 `json:"total"`
+	// Delta 是更正后与原记录大小之差，负数表示原先多算了。
+	Delta int64 `json:"delta"`
+}
+
+func (s *Service) RunMaintenance(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		_, _ = s.PurgeExpiredTrash()
+		_, _ = s.RecalculateSizes()
+		_ = s.sanitizeOutdated()
+		_, _ = s.PruneActivity()
+		_, _ = s.PruneFavicons()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Service) RecalculateSizes() (*SizeRecalcResult, error) {
+	rows, err := s.db.Query("SELECT id, file_path, thumb_path, file_size FROM bookmarks")
+	if err != nil {
+		return nil, err
+	}
+	type sizeRow struct {
+		id        string
+		filePath  string
+		thumbPath string
+		size      int64
+	}
+	var items []sizeRow
+	for rows.Next() {
+		var item sizeRow
+		if err := rows.Scan(&item.id, &item.filePath, &item.thumbPath, &item.size); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := &SizeRecalcResult{}
+	for _, item := range items {
+		result.Checked++
+		if !s.artifactsExist(item.filePath, item.thumbPath) {
+			result.Missing++
+		}
+		size := s.artifactSize(item.filePath, item.thumbPath)
+		result.Total += size
+		if size == item.size {
+			continue
+		}
+		if _, err := s.db.Exec("UPDATE bookmarks SET file_size = ? WHERE id = ?", size, item.id); err != nil {
+			return nil, err
+		}
+		result.Updated++
+		result.Delta += size - item.size
+	}
+	return result, nil
+}
+
+func (s *Service) artifactSize(relativePaths ...string) int64 {
+	var total int64
+	for _, relative := range relativePaths {
+		if relative == "" {
+			continue
+		}
+		if info, err := os.Stat(getAbsoluteFilePath(s.dataDir, relative)); err == nil {
+			total += info.Size()
+		}
+	}
+	return total
+}
+
+func (s *Service) artifactsExist(relativePaths ...string) bool {
+	for _, relative := range relativePaths {
+		if relative == "" {
+			continue
+		}
+		if _, err := os.Stat(getAbsoluteFilePath(s.dataDir, relative)); err != nil {
+			return false
+		}
+	}
+	return true
+}
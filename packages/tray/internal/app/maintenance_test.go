@@ -0,0 +1,56 @@
+package app
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRecalculateSizes(t *testing.T) {
+	s := newTestService(t)
+	grown := saveTestBookmark(t, s, "https://example.com/a", "A", "<p>a</p>")
+	lost := saveTestBookmark(t, s, "https://example.com/b", "B", "<p>b</p>")
+	saveTestBookmark(t, s, "https://example.com/c", "C", "<p>c</p>")
+
+	file, err := os.OpenFile(getAbsoluteFilePath(s.dataDir, grown.FilePath), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteString(strings.Repeat("x", 100)); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	thumb := getAbsoluteFilePath(s.dataDir, lost.ThumbPath)
+	info, err := os.Stat(thumb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(thumb); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := s.RecalculateSizes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Checked != 3 || result.Updated != 2 || result.Missing != 1 || result.Delta != 100-info.Size() {
+		t.Fatalf("result = %+v, want delta %d", result, 100-info.Size())
+	}
+	for _, bm := range []*Bookmark{grown, lost} {
+		got, err := s.lookupBookmark(bm.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := s.artifactSize(got.FilePath, got.ThumbPath); got.FileSize != want {
+			t.Fatalf("%s file_size = %d, want %d", bm.Title, got.FileSize, want)
+		}
+	}
+	if got, _ := s.lookupBookmark(grown.ID); got.FileSize != grown.FileSize+100 {
+		t.Fatalf("grown file_size = %d, was %d", got.FileSize, grown.FileSize)
+	}
+
+	again, err := s.RecalculateSizes()
+	if err != nil || again.Updated != 0 || again.Delta != 0 || again.Total != result.Total {
+		t.Fatalf("second run = %+v, %v", again, err)
+	}
+}
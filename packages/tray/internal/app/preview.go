@@ -0,0 +1,90 @@
+package app
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// previewCSP 禁止脚本和一切外部请求；sandbox 不带 allow-same-origin，页面运行在不透明来源中。
+const previewCSP = "default-src 'none'; img-src data:; style-src 'unsafe-inline' data:; font-src data:; media-src data:; " +
+	"form-action 'none'; base-uri 'none'; sandbox"
+
+// PreviewServer 在独立的回环端口上提供收藏预览，与绑定了桥接接口的管理窗口不同源，
+// 即使存档页面带有恶意内容也无法调用 bookmark.delete、update.start 等方法。
+type PreviewServer struct {
+	service  *Service
+	server   *http.Server
+	listener net.Listener
+	token    string
+}
+
+func StartPreviewServer(service *Service) (*PreviewServer, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	p := &PreviewServer{
+		service:  service,
+		listener: listener,
+		token:    hex.EncodeToString(randomBytes(16)),
+	}
+	p.server = &http.Server{Handler: p, ReadHeaderTimeout: 10 * time.Second}
+	go func() { _ = p.server.Serve(listener) }()
+	return p, nil
+}
+
+// URL 返回带随机令牌的预览地址，令牌只在本进程内有效。
+func (p *PreviewServer) URL(id string) string {
+	return "http://" + p.listener.Addr().String() + "/preview/" + p.token + "/" + url.PathEscape(id)
+}
+
+func (p *PreviewServer) Close() error {
+	return p.server.Close()
+}
+
+func (p *PreviewServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// 校验 Host 以防 DNS 重绑定。
+	if r.Host != p.listener.Addr().String() {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if len(parts) != 3 || parts[0] != "preview" || parts[2] == "" || subtle.ConstantTimeCompare([]byte(parts[1]), []byte(p.token)) != 1 {
+		http.NotFound(w, r)
+		return
+	}
+
+	content, err := p.service.GetBookmarkHTML(parts[2])
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.NotFound(w, r)
+		return
+	case errors.Is(err, ErrLibraryLocked):
+		http.Error(w, err.Error(), http.StatusLocked)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", "text/html; charset=utf-8")
+	header.Set("Content-Security-Policy", previewCSP)
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set("Referrer-Policy", "no-referrer")
+	header.Set("Cache-Control", "no-store")
+	if r.Method == http.MethodGet {
+		_, _ = w.Write([]byte(content.HTML))
+	}
+}
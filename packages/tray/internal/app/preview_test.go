@@ -0,0 +1,79 @@
+package app
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPreviewServer(t *testing.T) {
+	s := newTestService(t)
+	bm := saveTestBookmark(t, s, "https://example.com/a", "A", "<p>preview body</p>")
+	p, err := StartPreviewServer(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+
+	resp, err := http.Get(p.URL(bm.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "preview body") {
+		t.Fatalf("preview = %d %q", resp.StatusCode, body)
+	}
+	if csp := resp.Header.Get("Content-Security-Policy"); csp != previewCSP || !strings.HasSuffix(csp, "sandbox") {
+		t.Fatalf("CSP = %q", csp)
+	}
+	for key, want := range map[string]string{"X-Content-Type-Options": "nosniff", "Referrer-Policy": "no-referrer", "Cache-Control": "no-store"} {
+		if got := resp.Header.Get(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+
+	base := strings.TrimSuffix(p.URL(bm.ID), "/"+bm.ID)
+	wrongToken := strings.Replace(p.URL(bm.ID), p.token, strings.Repeat("0", len(p.token)), 1)
+	cases := []struct {
+		name   string
+		method string
+		url    string
+		host   string
+		status int
+	}{
+		{"wrong token", http.MethodGet, wrongToken, "", http.StatusNotFound},
+		{"missing id", http.MethodGet, base + "/", "", http.StatusNotFound},
+		{"unknown id", http.MethodGet, base + "/missing", "", http.StatusNotFound},
+		{"write method", http.MethodPost, p.URL(bm.ID), "", http.StatusMethodNotAllowed},
+		{"rebound host", http.MethodGet, p.URL(bm.ID), "attacker.example", http.StatusForbidden},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest(c.method, c.url, nil)
+		if c.host != "" {
+			req.Host = c.host
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != c.status {
+			t.Errorf("%s: status = %d, want %d", c.name, resp.StatusCode, c.status)
+		}
+	}
+
+	if _, err := s.EnableEncryption(testPassphrase); err != nil {
+		t.Fatal(err)
+	}
+	s.LockLibrary()
+	resp, err = http.Get(p.URL(bm.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusLocked {
+		t.Fatalf("locked library status = %d", resp.StatusCode)
+	}
+}
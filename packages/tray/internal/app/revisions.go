@@ -0,0 +1,286 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"chrome-collect-tray/internal/protocol"
+)
+
+const (
+	metaRevisionLimit    = "notes_revision_limit"
+	defaultRevisionLimit = 50
+	maxRevisionLimit     = 10000
+	maxDiffLines         = 1000
+)
+
+var revisionFields = map[string]bool{"notes": true, "alias": true}
+
+type NoteRevision struct {
+	ID         int64  `json:"id"`
+	BookmarkID string `json:"bookmarkId"`
+	Field      string `json:"field"`
+	Content    string `json:"content"`
+	Source     string `json:"source"`
+	CreatedAt  int64  `json:"createdAt"`
+}
+
+type DiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+type NoteDiff struct {
+	FromID int64      `json:"fromId"`
+	ToID   int64      `json:"toId"`
+	Field  string     `json:"field"`
+	Lines  []DiffLine `json:"lines"`
+}
+
+func (s *Service) SetRevisionLimit(limit int) (Settings, error) {
+	if limit < 0 || limit > maxRevisionLimit {
+		return Settings{}, fmt.Errorf("保留版本数需在 0 到 %d 之间", maxRevisionLimit)
+	}
+	if err := s.setSetting(protocol.MethodSettingsRevisions, metaRevisionLimit, strconv.Itoa(limit)); err != nil {
+		return Settings{}, err
+	}
+	if limit > 0 {
+		if _, err := s.db.Exec(`DELETE FROM note_revisions WHERE id NOT IN (
+			SELECT id FROM (
+				SELECT id, ROW_NUMBER() OVER (PARTITION BY bookmark_id, field ORDER BY id DESC) AS rank FROM note_revisions
+			) WHERE rank <= ?)`, limit); err != nil {
+			return Settings{}, err
+		}
+	}
+	return s.GetSettings(), nil
+}
+
+// revisionLimit 为 0 表示不限制保留的版本数。
+func (s *Service) revisionLimit() int {
+	value, err := s.getMeta(metaRevisionLimit)
+	if err != nil || value == "" {
+		return defaultRevisionLimit
+	}
+	limit, convErr := strconv.Atoi(value)
+	if convErr != nil || limit < 0 {
+		return defaultRevisionLimit
+	}
+	return limit
+}
+
+// updateRevisioned 更新 notes 或 alias，同时写入版本记录和操作记录。
+func (s *Service) updateRevisioned(action, id, field, value string) error {
+	if !revisionFields[field] {
+		return fmt.Errorf("不支持的字段: %s", field)
+	}
+	value, err := s.sealField(value)
+	if err != nil {
+		return err
+	}
+	return s.journaled(action, "bookmark", id, func(tx *sql.Tx) error {
+		if err := s.recordRevision(tx, id, field, value); err != nil {
+			return err
+		}
+		return execAffected(tx, "UPDATE bookmarks SET "+field+" = ? WHERE id = ? AND deleted_at = 0", value, id)
+	})
+}
+
+// recordRevision 需在字段更新之前调用：首次编辑时会先把原值存为基线版本。
+func (s *Service) recordRevision(tx *sql.Tx, id, field, value string) error {
+	var current string
+	var createdAt int64
+	err := tx.QueryRow("SELECT "+field+", created_at FROM bookmarks WHERE id = ?", id).Scan(&current, &createdAt)
+	if err != nil {
+		return err
+	}
+
+	var latest string
+	err = tx.QueryRow("SELECT content FROM note_revisions WHERE bookmark_id = ? AND field = ? ORDER BY id DESC LIMIT 1", id, field).Scan(&latest)
+	switch {
+	case err == sql.ErrNoRows:
+		if s.sameField(current, value) {
+			return nil
+		}
+		if current != "" {
+			if err := s.insertRevision(tx, id, field, current, createdAt); err != nil {
+				return err
+			}
+		}
+	case err != nil:
+		return err
+	case s.sameField(latest, value):
+		return nil
+	}
+
+	if err := s.insertRevision(tx, id, field, value, time.Now().UnixMilli()); err != nil {
+		return err
+	}
+	if limit := s.revisionLimit(); limit > 0 {
+		_, err = tx.Exec(`DELETE FROM note_revisions WHERE bookmark_id = ? AND field = ? AND id NOT IN (
+			SELECT id FROM note_revisions WHERE bookmark_id = ? AND field = ? ORDER BY id DESC LIMIT ?)`,
+			id, field, id, field, limit)
+	}
+	return err
+}
+
+// sameField 比较可能已加密的字段内容；未解锁时只能比较密文。
+func (s *Service) sameField(a, b string) bool {
+	if a == b {
+		return true
+	}
+	plainA, errA := s.openField(a)
+	plainB, errB := s.openField(b)
+	return errA == nil && errB == nil && plainA == plainB
+}
+
+func (s *Service) insertRevision(tx *sql.Tx, id, field, content string, createdAt int64) error {
+	_, err := tx.Exec("INSERT INTO note_revisions (bookmark_id, field, content, source, created_at) VALUES (?, ?, ?, ?, ?)",
+		id, field, content, s.source, createdAt)
+	return err
+}
+
+func (s *Service) ListNoteRevisions(bookmarkID, field string) ([]NoteRevision, error) {
+	if bookmarkID == "" {
+		return nil, errors.New("缺少收藏 ID")
+	}
+	query := "SELECT id, bookmark_id, field, content, source, created_at FROM note_revisions WHERE bookmark_id = ?"
+	args := []any{bookmarkID}
+	if field != "" {
+		if !revisionFields[field] {
+			return nil, fmt.Errorf("不支持的字段: %s", field)
+		}
+		query += " AND field = ?"
+		args = append(args, field)
+	}
+	rows, err := s.db.Query(query+" ORDER BY id DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []NoteRevision{}
+	for rows.Next() {
+		var rev NoteRevision
+		if err := rows.Scan(&rev.ID, &rev.BookmarkID, &rev.Field, &rev.Content, &rev.Source, &rev.CreatedAt); err != nil {
+			return nil, err
+		}
+		if rev.Content, err = s.openField(rev.Content); err != nil {
+			return nil, err
+		}
+		items = append(items, rev)
+	}
+	return items, rows.Err()
+}
+
+func (s *Service) getNoteRevision(id int64) (*NoteRevision, error) {
+	var rev NoteRevision
+	err := s.db.QueryRow("SELECT id, bookmark_id, field, content, source, created_at FROM note_revisions WHERE id = ?", id).
+		Scan(&rev.ID, &rev.BookmarkID, &rev.Field, &rev.Content, &rev.Source, &rev.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if rev.Content, err = s.openField(rev.Content); err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// DiffNoteRevisions 对比两个版本；toID 为 0 时与当前内容对比。
+func (s *Service) DiffNoteRevisions(fromID, toID int64) (*NoteDiff, error) {
+	from, err := s.getNoteRevision(fromID)
+	if err != nil {
+		return nil, err
+	}
+	var target string
+	if toID > 0 {
+		to, err := s.getNoteRevision(toID)
+		if err != nil {
+			return nil, err
+		}
+		if to.BookmarkID != from.BookmarkID || to.Field != from.Field {
+			return nil, errors.New("只能对比同一收藏同一字段的版本")
+		}
+		target = to.Content
+	} else if err := s.db.QueryRow("SELECT "+from.Field+" FROM bookmarks WHERE id = ?", from.BookmarkID).Scan(&target); err != nil {
+		return nil, err
+	} else if target, err = s.openField(target); err != nil {
+		return nil, err
+	}
+	return &NoteDiff{FromID: fromID, ToID: toID, Field: from.Field, Lines: diffLines(from.Content, target)}, nil
+}
+
+func (s *Service) RestoreNoteRevision(revisionID int64) (*Bookmark, error) {
+	rev, err := s.getNoteRevision(revisionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.updateRevisioned(protocol.MethodBookmarkRestoreRev, rev.BookmarkID, rev.Field, rev.Content); err != nil {
+		return nil, err
+	}
+	return s.GetBookmark(rev.BookmarkID)
+}
+
+// diffLines 基于最长公共子序列的逐行对比，超长内容退化为整体替换。
+func diffLines(before, after string) []DiffLine {
+	a := splitLines(before)
+	b := splitLines(after)
+	if len(a) > maxDiffLines || len(b) > maxDiffLines {
+		lines := make([]DiffLine, 0, len(a)+len(b))
+		for _, line := range a {
+			lines = append(lines, DiffLine{Op: "delete", Text: line})
+		}
+		for _, line := range b {
+			lines = append(lines, DiffLine{Op: "insert", Text: line})
+		}
+		return lines
+	}
+
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	lines := []DiffLine{}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, DiffLine{Op: "equal", Text: a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, DiffLine{Op: "delete", Text: a[i]})
+			i++
+		default:
+			lines = append(lines, DiffLine{Op: "insert", Text: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, DiffLine{Op: "delete", Text: a[i]})
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, DiffLine{Op: "insert", Text: b[j]})
+	}
+	return lines
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+}
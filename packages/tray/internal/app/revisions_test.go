@@ -0,0 +1,106 @@
+package app
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func revisionContents(t *testing.T, s *Service, id, field string) []string {
+	t.Helper()
+	revisions, err := s.ListNoteRevisions(id, field)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var contents []string
+	for _, rev := range revisions {
+		contents = append(contents, rev.Content)
+	}
+	return contents
+}
+
+func TestNoteRevisionsHistoryAndRestore(t *testing.T) {
+	s := newTestService(t)
+	bm := saveTestBookmark(t, s, "https://example.com/a", "A", "<p>a</p>")
+	for _, notes := range []string{"one", "one", "two\nlines"} {
+		if err := s.UpdateNotes(bm.ID, notes); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := revisionContents(t, s, bm.ID, "notes"); !slices.Equal(got, []string{"two\nlines", "one"}) {
+		t.Fatalf("revisions = %q, want unchanged saves skipped", got)
+	}
+
+	revisions, _ := s.ListNoteRevisions(bm.ID, "notes")
+	diff, err := s.DiffNoteRevisions(revisions[1].ID, revisions[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DiffLine{{"delete", "one"}, {"insert", "two"}, {"insert", "lines"}}
+	if !slices.Equal(diff.Lines, want) {
+		t.Fatalf("diff = %+v", diff.Lines)
+	}
+
+	restored, err := s.RestoreNoteRevision(revisions[1].ID)
+	if err != nil || restored.Notes != "one" {
+		t.Fatalf("restore = %+v, %v", restored, err)
+	}
+	if got := revisionContents(t, s, bm.ID, "notes"); len(got) != 3 || got[0] != "one" {
+		t.Fatalf("restore not recorded as a revision: %q", got)
+	}
+	if diff, err := s.DiffNoteRevisions(revisions[1].ID, 0); err != nil || diff.Lines[0].Op != "equal" {
+		t.Fatalf("diff against current = %+v, %v", diff, err)
+	}
+
+	if err := s.UpdateAlias(bm.ID, "nick"); err != nil {
+		t.Fatal(err)
+	}
+	alias, _ := s.ListNoteRevisions(bm.ID, "alias")
+	if _, err := s.DiffNoteRevisions(revisions[0].ID, alias[0].ID); err == nil {
+		t.Fatal("diff across fields accepted")
+	}
+	if _, err := s.ListNoteRevisions(bm.ID, "title"); err == nil {
+		t.Fatal("unsupported field accepted")
+	}
+}
+
+func TestNoteRevisionsKeepBaselineAndLimit(t *testing.T) {
+	s := newTestService(t)
+	bm := saveTestBookmark(t, s, "https://example.com/a", "A", "<p>a</p>")
+	// 模拟在版本功能之前就已存在的备注：第一次编辑时应先保存原值。
+	if _, err := s.db.Exec("UPDATE bookmarks SET notes = 'legacy' WHERE id = ?", bm.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateNotes(bm.ID, "edited"); err != nil {
+		t.Fatal(err)
+	}
+	if got := revisionContents(t, s, bm.ID, "notes"); !slices.Equal(got, []string{"edited", "legacy"}) {
+		t.Fatalf("revisions = %q", got)
+	}
+
+	if _, err := s.SetRevisionLimit(maxRevisionLimit + 1); err == nil {
+		t.Fatal("limit above the maximum accepted")
+	}
+	if _, err := s.SetRevisionLimit(2); err != nil {
+		t.Fatal(err)
+	}
+	for _, notes := range []string{"a", "b", "c"} {
+		if err := s.UpdateNotes(bm.ID, notes); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := revisionContents(t, s, bm.ID, "notes"); !slices.Equal(got, []string{"c", "b"}) {
+		t.Fatalf("revisions after limit = %q", got)
+	}
+}
+
+func TestDiffLinesFallsBackForLongInput(t *testing.T) {
+	long := make([]string, maxDiffLines+1)
+	lines := diffLines(strings.Join(long, "\n"), "x")
+	if len(lines) != maxDiffLines+2 || lines[len(lines)-1] != (DiffLine{"insert", "x"}) {
+		t.Fatalf("fallback diff has %d lines", len(lines))
+	}
+	if got := diffLines("a\r\nb", "a\nb"); len(got) != 2 || got[0].Op != "equal" || got[1].Op != "equal" {
+		t.Fatalf("CRLF diff = %+v", got)
+	}
+}
@@ -0,0 +1,559 @@
+package app
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"chrome-collect-tray/internal/protocol"
+)
+
+const (
+	metaS3              = "s3_backup"
+	metaS3Secret        = "s3_secret"
+	metaS3BackupPending = "s3_backup_pending"
+	metaS3LastBackup    = "s3_last_backup"
+	metaS3BackupError   = "s3_backup_error"
+	s3SnapshotFile      = "s3-backup.db"
+	defaultS3Region     = "us-east-1"
+	s3EncryptionAES256  = "AES256"
+	s3EncryptionKMS     = "aws:kms"
+	s3RequestTimeout    = 5 * time.Minute
+	s3EmptyPayloadHash  = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+var s3BucketPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+// S3Config 描述一个 S3 兼容的存储桶。MinIO 等自建服务通常需要开启 PathStyle。
+type S3Config struct {
+	Endpoint    string `json:"endpoint"`
+	Region      string `json:"region"`
+	Bucket      string `json:"bucket"`
+	Prefix      string `json:"prefix"`
+	AccessKeyID string `json:"accessKeyId"`
+	PathStyle   bool   `json:"pathStyle"`
+	Encryption  string `json:"encryption"`
+	KMSKeyID    string `json:"kmsKeyId"`
+	Enabled     bool   `json:"enabled"`
+	Keep        int    `json:"keep"`
+}
+
+// S3Input 中的 SecretAccessKey 为 nil 时保留已保存的密钥。
+type S3Input struct {
+	S3Config
+	SecretAccessKey *string `json:"secretAccessKey"`
+}
+
+type S3Status struct {
+	S3Config
+	HasSecret       bool   `json:"hasSecret"`
+	LastBackupAt    int64  `json:"lastBackupAt"`
+	LastBackupError string `json:"lastBackupError"`
+	PendingBackup   string `json:"pendingBackup"`
+}
+
+func (s *Service) s3Config() S3Config {
+	cfg := S3Config{}
+	if value, err := s.getMeta(metaS3); err == nil && value != "" {
+		_ = json.Unmarshal([]byte(value), &cfg)
+	}
+	if cfg.Keep <= 0 {
+		cfg.Keep = defaultBackupKeep
+	}
+	return cfg
+}
+
+func (s *Service) S3Status() (*S3Status, error) {
+	status := &S3Status{S3Config: s.s3Config()}
+	stored, err := s.getMeta(metaS3Secret)
+	if err != nil {
+		return nil, err
+	}
+	status.HasSecret = stored != ""
+	if value, _ := s.getMeta(metaS3LastBackup); value != "" {
+		status.LastBackupAt, _ = strconv.ParseInt(value, 10, 64)
+	}
+	status.LastBackupError, _ = s.getMeta(metaS3BackupError)
+	status.PendingBackup, _ = s.getMeta(metaS3BackupPending)
+	return status, nil
+}
+
+// SetS3Config 保存存储桶配置并立即测试列举权限；存储桶为空即关闭。
+func (s *Service) SetS3Config(input S3Input) (*S3Status, error) {
+	cfg := input.S3Config
+	cfg.Endpoint = strings.TrimRight(strings.TrimSpace(cfg.Endpoint), "/")
+	cfg.Region = strings.TrimSpace(cfg.Region)
+	cfg.Bucket = strings.TrimSpace(cfg.Bucket)
+	cfg.Prefix = strings.Trim(strings.TrimSpace(cfg.Prefix), "/")
+	cfg.AccessKeyID = strings.TrimSpace(cfg.AccessKeyID)
+	cfg.KMSKeyID = strings.TrimSpace(cfg.KMSKeyID)
+	if cfg.Keep == 0 {
+		cfg.Keep = defaultBackupKeep
+	}
+	if cfg.Keep < 1 || cfg.Keep > maxBackupKeep {
+		return nil, fmt.Errorf("保留的备份数量需在 1 到 %d 之间", maxBackupKeep)
+	}
+	previous := s.s3Config()
+
+	secret := ""
+	if input.SecretAccessKey != nil {
+		secret = strings.TrimSpace(*input.SecretAccessKey)
+	} else if cfg.Bucket != "" {
+		var err error
+		if secret, err = s.s3Secret(); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Bucket == "" {
+		cfg = S3Config{Keep: cfg.Keep}
+		secret = ""
+	} else {
+		if !s3BucketPattern.MatchString(cfg.Bucket) {
+			return nil, fmt.Errorf("无效的存储桶名称: %s", cfg.Bucket)
+		}
+		if cfg.Region == "" {
+			cfg.Region = defaultS3Region
+		}
+		if cfg.Endpoint == "" {
+			cfg.Endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+		}
+		parsed, err := url.Parse(cfg.Endpoint)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, fmt.Errorf("无效的 S3 地址: %s", cfg.Endpoint)
+		}
+		switch cfg.Encryption {
+		case "", s3EncryptionAES256:
+			cfg.KMSKeyID = ""
+		case s3EncryptionKMS:
+		default:
+			return nil, fmt.Errorf("不支持的服务端加密方式: %s", cfg.Encryption)
+		}
+		if cfg.AccessKeyID == "" || secret == "" {
+			return nil, errors.New("请填写 Access Key ID 和 Secret Access Key")
+		}
+		client, err := newS3Client(cfg, secret)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := client.list("", "/", 1); err != nil {
+			return nil, err
+		}
+	}
+
+	raw, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.setSetting(protocol.MethodSettingsS3, metaS3, string(raw)); err != nil {
+		return nil, err
+	}
+	// 密钥不进入操作记录。
+	if err := s.saveSecret(metaS3Secret, secret); err != nil {
+		return nil, err
+	}
+	if previous.Endpoint != cfg.Endpoint || previous.Bucket != cfg.Bucket || previous.Prefix != cfg.Prefix {
+		if err := s.discardPendingBackup(s.s3BackupTarget(nil, cfg)); err != nil {
+			return nil, err
+		}
+	}
+	return s.S3Status()
+}
+
+func (s *Service) s3Secret() (string, error) {
+	secret, err := s.loadSecret(metaS3Secret)
+	if err != nil {
+		return "", errors.New("无法读取保存的 S3 密钥，请重新输入")
+	}
+	return secret, nil
+}
+
+func (s *Service) newS3Client() (*s3Client, S3Config, error) {
+	cfg := s.s3Config()
+	if cfg.Bucket == "" {
+		return nil, cfg, errors.New("尚未设置 S3 存储桶")
+	}
+	secret, err := s.s3Secret()
+	if err != nil {
+		return nil, cfg, err
+	}
+	client, err := newS3Client(cfg, secret)
+	return client, cfg, err
+}
+
+func (s *Service) s3BackupDue() bool {
+	cfg := s.s3Config()
+	if cfg.Bucket == "" || !cfg.Enabled {
+		return false
+	}
+	return s.backupDue(metaS3BackupPending, metaS3LastBackup)
+}
+
+// BackupToS3 把资料库推送到存储桶的 backups/ 前缀下，页面文件以内容哈希为键增量上传。
+func (s *Service) BackupToS3() (*BackupResult, error) {
+	return s.runBackup(metaS3LastBackup, metaS3BackupError, func() (*BackupResult, error) {
+		client, cfg, err := s.newS3Client()
+		if err != nil {
+			return nil, err
+		}
+		return s.pushBackup(s.s3BackupTarget(client, cfg))
+	})
+}
+
+func (s *Service) ListS3Backups() ([]BackupInfo, error) {
+	client, _, err := s.newS3Client()
+	if err != nil {
+		return nil, err
+	}
+	return listBackups(&s3Store{client: client})
+}
+
+// RestoreFromS3 用存储桶中的备份替换本机资料库，期间暂停同步和备份。
+func (s *Service) RestoreFromS3(name string) (*RestoreResult, error) {
+	client, _, err := s.newS3Client()
+	if err != nil {
+		return nil, err
+	}
+	if !s.backupMu.TryLock() {
+		return nil, errors.New("备份正在进行中")
+	}
+	defer s.backupMu.Unlock()
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+	return s.restoreBackup(&s3Store{client: client}, name)
+}
+
+func (s *Service) s3BackupTarget(client *s3Client, cfg S3Config) backupTarget {
+	return backupTarget{
+		store:      &s3Store{client: client},
+		keep:       cfg.Keep,
+		pendingKey: metaS3BackupPending,
+		snapshot:   s3SnapshotFile,
+	}
+}
+
+// s3Client 只实现备份需要的几个接口，请求使用 AWS Signature V4 签名。
+type s3Client struct {
+	endpoint   *url.URL
+	region     string
+	bucket     string
+	prefix     string
+	accessKey  string
+	secretKey  string
+	pathStyle  bool
+	encryption string
+	kmsKeyID   string
+	http       *http.Client
+}
+
+type s3ListResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	CommonPrefixes []struct {
+		Prefix string `xml:"Prefix"`
+	} `xml:"CommonPrefixes"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func newS3Client(cfg S3Config, secret string) (*s3Client, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	prefix := cfg.Prefix
+	if prefix != "" {
+		prefix += "/"
+	}
+	return &s3Client{
+		endpoint:   endpoint,
+		region:     cfg.Region,
+		bucket:     cfg.Bucket,
+		prefix:     prefix,
+		accessKey:  cfg.AccessKeyID,
+		secretKey:  secret,
+		pathStyle:  cfg.PathStyle,
+		encryption: cfg.Encryption,
+		kmsKeyID:   cfg.KMSKeyID,
+		http:       &http.Client{Timeout: s3RequestTimeout},
+	}, nil
+}
+
+// objectURL 返回对象（key 为空时为存储桶本身）的地址，路径已按 S3 规则编码。
+func (c *s3Client) objectURL(key string, query url.Values) string {
+	host := c.endpoint.Host
+	base := strings.TrimSuffix(c.endpoint.Path, "/")
+	if c.pathStyle {
+		base += "/" + c.bucket
+	} else {
+		host = c.bucket + "." + host
+	}
+	target := c.endpoint.Scheme + "://" + host + s3Escape(base+"/"+key, true)
+	if len(query) > 0 {
+		target += "?" + s3CanonicalQuery(query)
+	}
+	return target
+}
+
+func (c *s3Client) do(method, key string, query url.Values, body []byte, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.objectURL(key, query), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	c.sign(req, body, time.Now())
+	return c.http.Do(req)
+}
+
+// sign 按 AWS Signature V4 为请求签名，签入 host、range 和所有 x-amz- 开头的请求头。
+func (c *s3Client) sign(req *http.Request, body []byte, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payloadHash := s3EmptyPayloadHash
+	if len(body) > 0 {
+		payloadHash = sha256Hex(body)
+	}
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "range" || lower == "content-md5" {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + c.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+c.secretKey), day)
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+c.accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape 按 S3 的规则编码：只保留非保留字符，路径中的 / 可选择保留。
+func s3Escape(value string, keepSlash bool) string {
+	var out strings.Builder
+	for i := 0; i < len(value); i++ {
+		ch := value[i]
+		if ch >= 'A' && ch <= 'Z' || ch >= 'a' && ch <= 'z' || ch >= '0' && ch <= '9' ||
+			ch == '-' || ch == '_' || ch == '.' || ch == '~' || (keepSlash && ch == '/') {
+			out.WriteByte(ch)
+			continue
+		}
+		fmt.Fprintf(&out, "%%%02X", ch)
+	}
+	return out.String()
+}
+
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, key := range keys {
+		values := slices.Clone(query[key])
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, s3Escape(key, false)+"="+s3Escape(value, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// check 读取并关闭响应，状态码不在 ok 中时把 S3 的错误信息转成 error。
+func (c *s3Client) check(resp *http.Response, method, key string, ok ...int) ([]byte, error) {
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(ok, resp.StatusCode) {
+		return data, nil
+	}
+	var detail s3Error
+	_ = xml.Unmarshal(data, &detail)
+	if resp.StatusCode == http.StatusNotFound && detail.Code != "NoSuchBucket" {
+		return nil, fmt.Errorf("S3 %s %s: %w", method, key, os.ErrNotExist)
+	}
+	if detail.Code != "" {
+		return nil, fmt.Errorf("S3 %s %s 失败: %s %s", method, key, detail.Code, detail.Message)
+	}
+	return nil, fmt.Errorf("S3 %s %s 失败: %s", method, key, resp.Status)
+}
+
+func (c *s3Client) get(key string) ([]byte, error) {
+	resp, err := c.do(http.MethodGet, c.prefix+key, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.check(resp, http.MethodGet, key, http.StatusOK)
+}
+
+// put 上传单个对象；S3 的 PUT 本身是原子的，无需临时对象。
+func (c *s3Client) put(key string, data []byte) error {
+	header := http.Header{}
+	if c.encryption != "" {
+		header.Set("X-Amz-Server-Side-Encryption", c.encryption)
+		if c.kmsKeyID != "" {
+			header.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", c.kmsKeyID)
+		}
+	}
+	resp, err := c.do(http.MethodPut, c.prefix+key, nil, data, header)
+	if err != nil {
+		return err
+	}
+	_, err = c.check(resp, http.MethodPut, key, http.StatusOK)
+	return err
+}
+
+func (c *s3Client) delete(key string) error {
+	resp, err := c.do(http.MethodDelete, c.prefix+key, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	_, err = c.check(resp, http.MethodDelete, key, http.StatusOK, http.StatusNoContent)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// list 用 ListObjectsV2 列出 prefix 下的对象；delimiter 为 / 时子目录以 CommonPrefixes 返回。limit 为 0 时读完所有分页。
+func (c *s3Client) list(prefix, delimiter string, limit int) (*s3ListResult, error) {
+	all := &s3ListResult{}
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {c.prefix + prefix}}
+		if delimiter != "" {
+			query.Set("delimiter", delimiter)
+		}
+		if limit > 0 {
+			query.Set("max-keys", strconv.Itoa(limit))
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := c.do(http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		data, err := c.check(resp, "LIST", c.bucket+"/"+c.prefix+prefix, http.StatusOK)
+		if err != nil {
+			return nil, err
+		}
+		var page s3ListResult
+		if err := xml.Unmarshal(data, &page); err != nil {
+			return nil, fmt.Errorf("无法解析 S3 响应: %w", err)
+		}
+		all.Contents = append(all.Contents, page.Contents...)
+		all.CommonPrefixes = append(all.CommonPrefixes, page.CommonPrefixes...)
+		if !page.IsTruncated || page.NextContinuationToken == "" || limit > 0 {
+			return all, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// s3Store 把备份目录映射到对象键前缀。
+type s3Store struct {
+	client *s3Client
+}
+
+func (st *s3Store) read(name string) ([]byte, error) {
+	return st.client.get(name)
+}
+
+func (st *s3Store) write(name string, data []byte) error {
+	return st.client.put(name, data)
+}
+
+func (st *s3Store) entries(dir string) ([]remoteEntry, error) {
+	result, err := st.client.list(dir+"/", "/", 0)
+	if err != nil {
+		return nil, err
+	}
+	var entries []remoteEntry
+	for _, object := range result.Contents {
+		entries = append(entries, remoteEntry{name: path.Base(object.Key), size: object.Size, modified: object.LastModified})
+	}
+	for _, prefix := range result.CommonPrefixes {
+		entries = append(entries, remoteEntry{name: path.Base(strings.TrimSuffix(prefix.Prefix, "/")), collection: true})
+	}
+	return entries, nil
+}
+
+// remove 删除名为 dir 的对象以及 dir/ 前缀下的所有对象，单个文件和目录都可以用它删除。
+func (st *s3Store) remove(dir string) error {
+	if err := st.client.delete(dir); err != nil {
+		return err
+	}
+	result, err := st.client.list(dir+"/", "", 0)
+	if err != nil {
+		return err
+	}
+	for _, object := range result.Contents {
+		if err := st.client.delete(strings.TrimPrefix(object.Key, st.client.prefix)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// sanitizerVersion 在清理规则变化时递增，维护任务会据此重新清理已有收藏。
+const (
+	sanitizerVersion     = 1
+	metaSanitizerVersion = "sanitizer_version"
+)
+
+var (
+	sanitizeDropTags = map[string]bool{
+		"script": true, "iframe": true, "frame": true, "frameset": true,
+		"object": true, "embed": true, "applet": true,
+	}
+	// 会被浏览器自动请求的属性；指向外部地址时视为外部请求。
+	sanitizeFetchAttrs = map[string]bool{
+		"src": true, "srcset": true, "poster": true, "background": true, "lowsrc": true, "data": true,
+	}
+	sanitizeURLAttrs = map[string]bool{
+		"href": true, "src": true, "action": true, "formaction": true, "poster": true,
+		"background": true, "lowsrc": true, "data": true, "cite": true,
+	}
+)
+
+type SanitizeReport struct {
+	Scripts        int `json:"scripts"`
+	EventHandlers  int `json:"eventHandlers"`
+	JavaScriptURLs int `json:"javascriptUrls"`
+	MetaRefresh    int `json:"metaRefresh"`
+	Embeds         int `json:"embeds"`
+	ExternalRefs   int `json:"externalRefs"`
+}
+
+type SanitizedItem struct {
+	ID     string         `json:"id"`
+	Title  string         `json:"title"`
+	Report SanitizeReport `json:"report"`
+}
+
+type SanitizeJobResult struct {
+	Checked int             `json:"checked"`
+	Updated int             `json:"updated"`
+	Failed  int             `json:"failed"`
+	Report  SanitizeReport  `json:"report"`
+	Items   []SanitizedItem `json:"items"`
+}
+
+func (r SanitizeReport) total() int {
+	return r.Scripts + r.EventHandlers + r.JavaScriptURLs + r.MetaRefresh + r.Embeds + r.ExternalRefs
+}
+
+func (r *SanitizeReport) add(other SanitizeReport) {
+	r.Scripts += other.Scripts
+	r.EventHandlers += other.EventHandlers
+	r.JavaScriptURLs += other.JavaScriptURLs
+	r.MetaRefresh += other.MetaRefresh
+	r.Embeds += other.Embeds
+	r.ExternalRefs += other.ExternalRefs
+}
+
+// SanitizeLibrary 用当前规则重新清理所有已保存的 HTML（包括回收站），只改写确有变化的文件。
+func (s *Service) SanitizeLibrary() (*SanitizeJobResult, error) {
+	rows, err := s.db.Query("SELECT id, title, file_path, thumb_path FROM bookmarks WHERE file_path != ''")
+	if err != nil {
+		return nil, err
+	}
+	type sanitizeRow struct {
+		id        string
+		title     string
+		filePath  string
+		thumbPath string
+	}
+	var items []sanitizeRow
+	for rows.Next() {
+		var item sanitizeRow
+		if err := rows.Scan(&item.id, &item.title, &item.filePath, &item.thumbPath); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		item.title = s.revealField(item.title)
+		items = append(items, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := &SanitizeJobResult{Items: []SanitizedItem{}}
+	for _, item := range items {
+		result.Checked++
+		data, err := s.readArtifact(item.filePath)
+		if errors.Is(err, ErrLibraryLocked) {
+			return nil, err
+		}
+		if err != nil {
+			result.Failed++
+			continue
+		}
+		cleaned, report, err := sanitizeHTML(string(data))
+		if err != nil {
+			result.Failed++
+			continue
+		}
+		if report.total() == 0 {
+			continue
+		}
+		if err := s.writeArtifact(getAbsoluteFilePath(s.dataDir, item.filePath), []byte(cleaned)); err != nil {
+			return nil, err
+		}
+		fp := computeFingerprint([]byte(cleaned), nil)
+		if _, err := s.db.Exec("UPDATE bookmarks SET file_size = ?, content_hash = ?, text_simhash = ? WHERE id = ?",
+			s.artifactSize(item.filePath, item.thumbPath), fp.ContentHash, fp.TextSimhash, item.id); err != nil {
+			return nil, err
+		}
+		result.Updated++
+		result.Report.add(report)
+		result.Items = append(result.Items, SanitizedItem{ID: item.id, Title: item.title, Report: report})
+	}
+	if err := s.setMeta(metaSanitizerVersion, strconv.Itoa(sanitizerVersion)); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// sanitizeOutdated 在清理规则升级后执行一次全库清理；资料库锁定时留到下次维护再试。
+func (s *Service) sanitizeOutdated() error {
+	value, _ := s.getMeta(metaSanitizerVersion)
+	if version, err := strconv.Atoi(value); err == nil && version >= sanitizerVersion {
+		return nil
+	}
+	_, err := s.SanitizeLibrary()
+	return err
+}
+
+// sanitizeHTML 移除脚本、事件属性、javascript: 链接、meta 跳转和会自动发起的外部请求。
+// 没有需要清理的内容时原样返回，避免无意义地改写文件。
+func sanitizeHTML(document string) (string, SanitizeReport, error) {
+	var report SanitizeReport
+	doc, err := html.Parse(strings.NewReader(document))
+	if err != nil {
+		return document, report, err
+	}
+
+	var removals []*html.Node
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if sanitizeNode(n, &report) {
+				removals = append(removals, n)
+				return
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+	if report.total() == 0 {
+		return document, report, nil
+	}
+	for _, n := range removals {
+		n.Parent.RemoveChild(n)
+	}
+
+	var out bytes.Buffer
+	if err := html.Render(&out, doc); err != nil {
+		return document, report, err
+	}
+	return out.String(), report, nil
+}
+
+// sanitizeNode 清理节点属性，返回 true 表示整个节点需要移除。
+func sanitizeNode(n *html.Node, report *SanitizeReport) bool {
+	switch n.Data {
+	case "script", "noscript":
+		report.Scripts++
+		return true
+	case "base":
+		report.ExternalRefs++
+		return true
+	case "meta":
+		if strings.EqualFold(htmlAttr(n, "http-equiv"), "refresh") {
+			report.MetaRefresh++
+			return true
+		}
+	case "link":
+		if isExternalURL(htmlAttr(n, "href")) && isFetchingLink(n) {
+			report.ExternalRefs++
+			return true
+		}
+	case "set", "animate":
+		name := strings.ToLower(htmlAttr(n, "attributeName"))
+		if name == "href" || name == "xlink:href" {
+			report.JavaScriptURLs++
+			return true
+		}
+	case "style":
+		if n.FirstChild != nil && n.FirstChild.Type == html.TextNode {
+			n.FirstChild.Data = stripExternalCSS(n.FirstChild.Data, report)
+		}
+	}
+	if sanitizeDropTags[n.Data] {
+		report.Embeds++
+		return true
+	}
+
+	attrs := n.Attr[:0]
+	for _, attr := range n.Attr {
+		key := strings.ToLower(attr.Key)
+		switch {
+		case strings.HasPrefix(key, "on"):
+			report.EventHandlers++
+			continue
+		case key == "ping":
+			report.ExternalRefs++
+			continue
+		case sanitizeURLAttrs[key] && isScriptURL(attr.Val):
+			report.JavaScriptURLs++
+			continue
+		case (sanitizeFetchAttrs[key] || (key == "href" && n.Namespace == "svg" && n.Data != "a")) && hasExternalCandidate(key, attr.Val):
+			report.ExternalRefs++
+			continue
+		case key == "style":
+			attr.Val = stripExternalCSS(attr.Val, report)
+		}
+		attrs = append(attrs, attr)
+	}
+	n.Attr = attrs
+	return false
+}
+
+// stripExternalCSS 去掉指向外部地址的 @import 和 url()，data URI 不受影响。
+func stripExternalCSS(css string, report *SanitizeReport) string {
+	css = cssImportRe.ReplaceAllStringFunc(css, func(statement string) string {
+		if isExternalURL(cssImportRe.FindStringSubmatch(statement)[1]) {
+			report.ExternalRefs++
+			return ""
+		}
+		return statement
+	})
+	return cssURLRe.ReplaceAllStringFunc(css, func(match string) string {
+		if isExternalURL(cssURLRef(cssURLRe.FindStringSubmatch(match))) {
+			report.ExternalRefs++
+			return "none"
+		}
+		return match
+	})
+}
+
+// isFetchingLink 判断浏览器是否会为该 <link> 自动发起请求；canonical、alternate 等只是元数据。
+func isFetchingLink(n *html.Node) bool {
+	for _, rel := range strings.Fields(strings.ToLower(htmlAttr(n, "rel"))) {
+		if rel == "stylesheet" || strings.HasSuffix(rel, "icon") || captureDropRels[rel] {
+			return true
+		}
+	}
+	return false
+}
+
+func hasExternalCandidate(key, value string) bool {
+	if key != "srcset" {
+		return isExternalURL(value)
+	}
+	for _, candidate := range strings.Split(value, ",") {
+		if fields := strings.Fields(candidate); len(fields) > 0 && isExternalURL(fields[0]) {
+			return true
+		}
+	}
+	return false
+}
+
+func isExternalURL(value string) bool {
+	value = strings.ToLower(strings.TrimSpace(value))
+	return strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://") || strings.HasPrefix(value, "//")
+}
+
+// isScriptURL 与浏览器一致，忽略协议名中的空白和控制字符后再判断。
+func isScriptURL(value string) bool {
+	var scheme strings.Builder
+	for _, r := range value {
+		if r <= ' ' {
+			continue
+		}
+		if r == ':' {
+			break
+		}
+		scheme.WriteRune(r)
+		if scheme.Len() > len("javascript") {
+			return false
+		}
+	}
+	name := strings.ToLower(scheme.String())
+	return (name == "javascript" || name == "vbscript") && strings.Contains(value, ":")
+}
@@ -0,0 +1,125 @@
+package app
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeHTML(t *testing.T) {
+	cases := []struct {
+		name    string
+		input   string
+		want    SanitizeReport
+		removed []string
+		kept    []string
+	}{
+		{
+			name:    "scripts and handlers",
+			input:   `<p onclick="x()" title="t">a</p><script>alert(1)</script><noscript>n</noscript>`,
+			want:    SanitizeReport{Scripts: 2, EventHandlers: 1},
+			removed: []string{"onclick", "alert", "<noscript>"},
+			kept:    []string{`title="t"`},
+		},
+		{
+			name:    "javascript urls ignore whitespace and case",
+			input:   `<a href=" Java	Script:alert(1)">x</a><form action="vbscript:x"></form><a href="/page">ok</a>`,
+			want:    SanitizeReport{JavaScriptURLs: 2},
+			removed: []string{"alert", "vbscript"},
+			kept:    []string{`href="/page"`},
+		},
+		{
+			name:    "meta refresh and embeds",
+			input:   `<meta http-equiv="Refresh" content="0;url=https://x.example"><meta charset="utf-8"><iframe src="a"></iframe><object data="b"></object>`,
+			want:    SanitizeReport{MetaRefresh: 1, Embeds: 2},
+			removed: []string{"Refresh", "<iframe", "<object"},
+			kept:    []string{`charset="utf-8"`},
+		},
+		{
+			name: "external requests",
+			input: `<base href="https://x.example/"><link rel="stylesheet" href="https://x.example/a.css"><link rel="canonical" href="https://x.example/">` +
+				`<img src="//x.example/a.png" srcset="data:image/png;base64,AA 1x, https://x.example/b.png 2x" alt="a"><img src="data:image/png;base64,AA" ping="https://x.example/p">`,
+			want:    SanitizeReport{ExternalRefs: 5},
+			removed: []string{"<base", "a.css", "a.png", "b.png", "ping"},
+			kept:    []string{`rel="canonical"`, `alt="a"`, `src="data:image/png;base64,AA"`},
+		},
+		{
+			name:    "external css",
+			input:   `<style>@import url("https://x.example/a.css"); body { background: url(https://x.example/b.png) }</style><p style="background:url('data:image/png;base64,AA')">x</p>`,
+			want:    SanitizeReport{ExternalRefs: 2},
+			removed: []string{"@import", "b.png"},
+			kept:    []string{"background: none", "data:image/png;base64,AA"},
+		},
+		{
+			name:    "svg href animation",
+			input:   `<svg><a><animate attributeName="href" to="javascript:alert(1)"/></a><image href="https://x.example/a.png"/></svg>`,
+			want:    SanitizeReport{JavaScriptURLs: 1, ExternalRefs: 1},
+			removed: []string{"animate", "a.png"},
+		},
+	}
+	for _, c := range cases {
+		out, report, err := sanitizeHTML(c.input)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if report != c.want {
+			t.Errorf("%s: report = %+v, want %+v", c.name, report, c.want)
+		}
+		for _, s := range c.removed {
+			if strings.Contains(out, s) {
+				t.Errorf("%s: %q left in %s", c.name, s, out)
+			}
+		}
+		for _, s := range c.kept {
+			if !strings.Contains(out, s) {
+				t.Errorf("%s: %q missing from %s", c.name, s, out)
+			}
+		}
+	}
+
+	clean := `<p class="x">nothing to do</p>`
+	if out, report, err := sanitizeHTML(clean); err != nil || out != clean || report.total() != 0 {
+		t.Fatalf("clean document rewritten: %q, %+v, %v", out, report, err)
+	}
+}
+
+func TestSanitizeLibrary(t *testing.T) {
+	s := newTestService(t)
+	bm, err := s.SaveBookmark(SaveInput{URL: "https://example.com/a", Title: "A", HTML: `<p onclick="x()">a</p>`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bm.Sanitized == nil || bm.Sanitized.EventHandlers != 1 {
+		t.Fatalf("save report = %+v", bm.Sanitized)
+	}
+	clean := saveTestBookmark(t, s, "https://example.com/b", "B", "<p>b</p>")
+
+	// 模拟旧版本保存的、未经清理的页面。
+	dirty := `<html><body><p>a</p><script>alert(1)</script></body></html>`
+	if err := os.WriteFile(getAbsoluteFilePath(s.dataDir, bm.FilePath), []byte(dirty), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cleanInfo, err := os.Stat(getAbsoluteFilePath(s.dataDir, clean.FilePath))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := s.SanitizeLibrary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Checked != 2 || result.Updated != 1 || result.Report.Scripts != 1 || result.Items[0].ID != bm.ID {
+		t.Fatalf("result = %+v", result)
+	}
+	data, err := s.readArtifact(bm.FilePath)
+	if err != nil || strings.Contains(string(data), "alert") {
+		t.Fatalf("page after sanitize = %q, %v", data, err)
+	}
+	if info, err := os.Stat(getAbsoluteFilePath(s.dataDir, clean.FilePath)); err != nil || !info.ModTime().Equal(cleanInfo.ModTime()) {
+		t.Fatal("clean page rewritten")
+	}
+	if version, _ := s.getMeta(metaSanitizerVersion); version != strconv.Itoa(sanitizerVersion) {
+		t.Fatalf("sanitizer version = %q", version)
+	}
+}
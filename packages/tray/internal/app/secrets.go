@@ -0,0 +1,105 @@
+package app
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// secretKeyFile 是旧版本用来混淆凭据的本机密钥，只用于读取和迁移旧数据。
+const secretKeyFile = "secret.key"
+
+// saveSecret 把凭据交给系统保管：Windows 上用 DPAPI 按当前用户加密，macOS 上存入钥匙串。
+// 数据库里只保存 DPAPI 密文或钥匙串条目的引用，空字符串表示清除。
+func (s *Service) saveSecret(metaKey, secret string) error {
+	account, err := s.secretAccount(metaKey)
+	if err != nil {
+		return err
+	}
+	previous, err := s.getMeta(metaKey)
+	if err != nil {
+		return err
+	}
+	if secret == "" {
+		if previous != "" && !isLegacySecret(previous) {
+			if err := keystoreDelete(account, previous); err != nil {
+				return err
+			}
+		}
+		return s.setMeta(metaKey, "")
+	}
+	ref, err := keystoreSave(account, secret)
+	if err != nil {
+		return err
+	}
+	return s.setMeta(metaKey, ref)
+}
+
+// loadSecret 读取保存的凭据；旧版本用 secret.key 混淆的值读出后立即迁移到系统保管。
+func (s *Service) loadSecret(metaKey string) (string, error) {
+	stored, err := s.getMeta(metaKey)
+	if err != nil || stored == "" {
+		return "", err
+	}
+	if !isLegacySecret(stored) {
+		account, err := s.secretAccount(metaKey)
+		if err != nil {
+			return "", err
+		}
+		return keystoreLoad(account, stored)
+	}
+	secret, err := s.openLegacySecret(stored)
+	if err != nil {
+		return "", err
+	}
+	if err := s.saveSecret(metaKey, secret); err != nil {
+		return "", err
+	}
+	s.removeLegacySecretKey()
+	return secret, nil
+}
+
+// removeLegacySecretKey 在所有旧格式的凭据都迁移完后删除 secret.key。
+func (s *Service) removeLegacySecretKey() {
+	for _, key := range []string{metaWebDAVPassword, metaS3Secret} {
+		if stored, err := s.getMeta(key); err != nil || (stored != "" && isLegacySecret(stored)) {
+			return
+		}
+	}
+	_ = os.Remove(filepath.Join(filepath.Dir(s.dataDir), secretKeyFile))
+}
+
+// secretAccount 用设备 ID 区分同一用户下的多个资料库。
+func (s *Service) secretAccount(metaKey string) (string, error) {
+	device, err := s.syncDeviceID()
+	if err != nil {
+		return "", err
+	}
+	return metaKey + "." + device, nil
+}
+
+// isLegacySecret 判断是否为旧版本保存的值：新格式都带有 "<方式>:" 前缀，而 base64 不含冒号。
+func isLegacySecret(stored string) bool {
+	return !strings.Contains(stored, ":")
+}
+
+func (s *Service) openLegacySecret(stored string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(stored)
+	if err != nil {
+		return "", err
+	}
+	key, err := os.ReadFile(filepath.Join(filepath.Dir(s.dataDir), secretKeyFile))
+	if err != nil {
+		return "", err
+	}
+	if len(key) != 32 {
+		return "", errors.New("secret.key 已损坏")
+	}
+	plaintext, err := openWithKey(key, data)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
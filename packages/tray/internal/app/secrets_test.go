@@ -0,0 +1,96 @@
+package app
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveAndLoadSecret(t *testing.T) {
+	s := newTestService(t)
+	if secret, err := s.loadSecret(metaWebDAVPassword); err != nil || secret != "" {
+		t.Fatalf("unset secret = %q, %v", secret, err)
+	}
+	if err := s.saveSecret(metaWebDAVPassword, "hunter2"); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := s.getMeta(metaWebDAVPassword)
+	if stored == "" || isLegacySecret(stored) {
+		t.Fatalf("stored reference = %q", stored)
+	}
+	if secret, err := s.loadSecret(metaWebDAVPassword); err != nil || secret != "hunter2" {
+		t.Fatalf("loaded secret = %q, %v", secret, err)
+	}
+
+	if err := s.saveSecret(metaWebDAVPassword, ""); err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := s.getMeta(metaWebDAVPassword); stored != "" {
+		t.Fatalf("cleared reference = %q", stored)
+	}
+	account, _ := s.secretAccount(metaWebDAVPassword)
+	if _, err := keystoreLoad(account, stored); err == nil {
+		t.Fatal("keystore entry left behind after clearing")
+	}
+}
+
+func TestLoadSecretMigratesLegacyValue(t *testing.T) {
+	s := newTestService(t)
+	key := randomBytes(32)
+	if err := os.WriteFile(filepath.Join(filepath.Dir(s.dataDir), secretKeyFile), key, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := sealWithKey(key, []byte("old password"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.setMeta(metaWebDAVPassword, base64.StdEncoding.EncodeToString(sealed)); err != nil {
+		t.Fatal(err)
+	}
+
+	if password, err := s.webdavPassword(); err != nil || password != "old password" {
+		t.Fatalf("legacy password = %q, %v", password, err)
+	}
+	stored, _ := s.getMeta(metaWebDAVPassword)
+	if isLegacySecret(stored) {
+		t.Fatalf("legacy value not migrated: %q", stored)
+	}
+	if password, err := s.webdavPassword(); err != nil || password != "old password" {
+		t.Fatalf("migrated password = %q, %v", password, err)
+	}
+}
+
+func TestLegacySecretKeyRemovedAfterMigration(t *testing.T) {
+	s := newTestService(t)
+	keyPath := filepath.Join(filepath.Dir(s.dataDir), secretKeyFile)
+	key := randomBytes(32)
+	if err := os.WriteFile(keyPath, key, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for metaKey, secret := range map[string]string{metaWebDAVPassword: "dav password", metaS3Secret: "s3 secret"} {
+		sealed, err := sealWithKey(key, []byte(secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.setMeta(metaKey, base64.StdEncoding.EncodeToString(sealed)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if secret, err := s.s3Secret(); err != nil || secret != "s3 secret" {
+		t.Fatalf("legacy S3 secret = %q, %v", secret, err)
+	}
+	if _, err := os.Stat(keyPath); err != nil {
+		t.Fatalf("secret.key removed while the WebDAV password still needs it: %v", err)
+	}
+	if password, err := s.webdavPassword(); err != nil || password != "dav password" {
+		t.Fatalf("legacy WebDAV password = %q, %v", password, err)
+	}
+	if _, err := os.Stat(keyPath); !os.IsNotExist(err) {
+		t.Fatalf("secret.key left behind after migration: %v", err)
+	}
+	if secret, err := s.s3Secret(); err != nil || secret != "s3 secret" {
+		t.Fatalf("migrated S3 secret = %q, %v", secret, err)
+	}
+}
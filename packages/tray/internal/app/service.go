@@ -2,6 +2,7 @@ package app
 
 import (
 	"context"
+	"crypto/ecdh"
 	"database/sql"
 	"encoding/base64"
 	"encoding/json"
@@ -20,15 +21,20 @@ import (
 	"time"
 	"unicode/utf8"
 
+	"chrome-collect-tray/internal/protocol"
 	"github.com/google/uuid"
 	_ "modernc.org/sqlite"
 )
 
 const (
-	trashRetentionMs  = 7 * 24 * 60 * 60 * 1000
-	metaLastExtension = "last_extension_ping"
-	githubRepo        = "Waasaabii/chrome-collect"
-	releasesPage      = "https://github.com/" + githubRepo + "/releases/latest"
+	dayMs                     = 24 * 60 * 60 * 1000
+	defaultTrashRetentionDays = 7
+	maxTrashRetentionDays     = 3650
+	metaLastExtension         = "last_extension_ping"
+	metaTrashRetention        = "trash_retention_days"
+	metaURLStripParams        = "url_strip_params"
+	githubRepo                = "Waasaabii/chrome-collect"
+	releasesPage              = "https://github.com/" + githubRepo + "/releases/latest"
 )
 
 type Service struct {
@@ -39,49 +45,83 @@ type Service struct {
 	versionCacheMu     sync.Mutex
 	versionCacheResult VersionInfo
 	versionCacheExpiry time.Time
+	source             string
+	vaultMu            sync.RWMutex
+	vaultKey           *ecdh.PrivateKey
+	linkCheckMu        sync.Mutex
+	syncMu             sync.Mutex
+	backupMu           sync.Mutex
 }
 
 type Bookmark struct {
-	ID          string `json:"id"`
-	URL         string `json:"url"`
-	Title       string `json:"title"`
-	Alias       string `json:"alias"`
-	Favicon     string `json:"favicon"`
-	FilePath    string `json:"file_path"`
-	ThumbPath   string `json:"thumb_path"`
-	ThumbData   string `json:"thumb_data_url,omitempty"`
-	FileSize    int64  `json:"file_size"`
-	CreatedAt   int64  `json:"created_at"`
-	DeletedAt   int64  `json:"deleted_at"`
-	Notes       string `json:"notes"`
-	Tags        string `json:"tags"`
-	BookmarkID  string `json:"bookmark_id"`
+	ID           string `json:"id"`
+	URL          string `json:"url"`
+	Title        string `json:"title"`
+	Alias        string `json:"alias"`
+	Favicon      string `json:"favicon"`
+	FaviconID    string `json:"favicon_id"`
+	FilePath     string `json:"file_path"`
+	ThumbPath    string `json:"thumb_path"`
+	ThumbData    string `json:"thumb_data_url,omitempty"`
+	FileSize     int64  `json:"file_size"`
+	CreatedAt    int64  `json:"created_at"`
+	DeletedAt    int64  `json:"deleted_at"`
+	Notes        string `json:"notes"`
+	NotesLocked  bool   `json:"notesLocked,omitempty"`
+	TitleLocked  bool   `json:"titleLocked,omitempty"`
+	Tags         string `json:"tags"`
+	BookmarkID   string `json:"bookmark_id"`
+	ReadStatus   string `json:"read_status"`
+	ReadAt       int64  `json:"read_status_at"`
+	Starred      bool   `json:"starred"`
+	StarredAt    int64  `json:"starred_at"`
+	ColorLabel   string `json:"color_label"`
+	Archived     bool   `json:"archived"`
+	ArchivedAt   int64  `json:"archived_at"`
+	Domain       string `json:"domain"`
+	LastOpenedAt int64  `json:"last_opened_at"`
+	// ExpiresInDays 仅在回收站列表中填充，nil 表示永不过期。
+	ExpiresInDays *int `json:"expires_in_days,omitempty"`
+	// Sanitized 只在保存时返回本次清理掉的内容，不写入数据库。
+	Sanitized *SanitizeReport `json:"sanitized,omitempty"`
 }
 
 type SaveInput struct {
 	URL        string `json:"url"`
 	Title      string `json:"title"`
 	Favicon    string `json:"favicon"`
+	FaviconID  string `json:"favicon_id"`
 	HTML       string `json:"html"`
 	Screenshot string `json:"screenshot"`
 	BookmarkID string `json:"bookmarkId"`
 }
 
 type BookmarksResult struct {
-	Items []Bookmark `json:"items"`
-	Total int        `json:"total"`
+	Items      []Bookmark `json:"items"`
+	Total      *int       `json:"total,omitempty"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+	Facets     *Facets    `json:"facets,omitempty"`
 }
 
 type Stats struct {
 	Total      int   `json:"total"`
 	TotalSize  int64 `json:"totalSize"`
 	TrashCount int   `json:"trashCount"`
+	Unread     int   `json:"unread"`
+	Reading    int   `json:"reading"`
+	Starred    int   `json:"starred"`
+	Archived   int   `json:"archived"`
 }
 
 type Settings struct {
-	Enabled            bool `json:"enabled"`
-	AutoStart          bool `json:"autoStart"`
-	ExtensionInstalled bool `json:"extensionInstalled"`
+	Enabled            bool                `json:"enabled"`
+	AutoStart          bool                `json:"autoStart"`
+	ExtensionInstalled bool                `json:"extensionInstalled"`
+	TrashRetentionDays int                 `json:"trashRetentionDays"`
+	URLStripParams     map[string][]string `json:"urlStripParams"`
+	RevisionLimit      int                 `json:"revisionLimit"`
+	LinkCheckInterval  int                 `json:"linkCheckIntervalHours"`
+	Feed               FeedConfig          `json:"feed"`
 }
 
 type VersionInfo struct {
@@ -132,13 +172,17 @@ func New(version string) (*Service, error) {
 		dataDir: dataDir,
 		db:      db,
 		version: version,
+		source:  SourceDesktop,
 	}
 
 	if err := svc.initSchema(); err != nil {
 		return nil, err
 	}
 	svc.migrateOldFiles()
-	svc.purgeExpiredTrash()
+	_ = svc.backfillNormalizedURLs()
+	_ = svc.backfillDomains()
+	_ = svc.migrateInlineFavicons()
+	_, _ = svc.PurgeExpiredTrash()
 	return svc, nil
 }
 
@@ -182,6 +226,110 @@ func (s *Service) initSchema() error {
 		)`,
 		`ALTER TABLE bookmarks ADD COLUMN deleted_at INTEGER DEFAULT 0`,
 		`ALTER TABLE bookmarks ADD COLUMN notes TEXT DEFAULT ''`,
+		`ALTER TABLE bookmarks ADD COLUMN normalized_url TEXT DEFAULT ''`,
+		`CREATE INDEX IF NOT EXISTS idx_bookmarks_normalized_url ON bookmarks(normalized_url)`,
+		`ALTER TABLE bookmarks ADD COLUMN content_hash TEXT DEFAULT ''`,
+		`ALTER TABLE bookmarks ADD COLUMN text_simhash TEXT DEFAULT ''`,
+		`ALTER TABLE bookmarks ADD COLUMN thumb_hash TEXT DEFAULT ''`,
+		`ALTER TABLE bookmarks ADD COLUMN favicon_id TEXT DEFAULT ''`,
+		`ALTER TABLE bookmarks ADD COLUMN read_status TEXT DEFAULT 'unread'`,
+		`ALTER TABLE bookmarks ADD COLUMN read_status_at INTEGER DEFAULT 0`,
+		`ALTER TABLE bookmarks ADD COLUMN starred INTEGER DEFAULT 0`,
+		`ALTER TABLE bookmarks ADD COLUMN starred_at INTEGER DEFAULT 0`,
+		`ALTER TABLE bookmarks ADD COLUMN color_label TEXT DEFAULT ''`,
+		`ALTER TABLE bookmarks ADD COLUMN archived INTEGER DEFAULT 0`,
+		`ALTER TABLE bookmarks ADD COLUMN archived_at INTEGER DEFAULT 0`,
+		`CREATE INDEX IF NOT EXISTS idx_bookmarks_state ON bookmarks(deleted_at, archived, read_status)`,
+		`ALTER TABLE bookmarks ADD COLUMN domain TEXT DEFAULT ''`,
+		`ALTER TABLE bookmarks ADD COLUMN last_opened_at INTEGER DEFAULT 0`,
+		`CREATE INDEX IF NOT EXISTS idx_bookmarks_domain ON bookmarks(domain)`,
+		`CREATE INDEX IF NOT EXISTS idx_bookmarks_created ON bookmarks(created_at)`,
+		`CREATE TABLE IF NOT EXISTS annotations (
+			id         TEXT PRIMARY KEY,
+			target_id  TEXT NOT NULL,
+			exact      TEXT NOT NULL,
+			prefix     TEXT DEFAULT '',
+			suffix     TEXT DEFAULT '',
+			start_pos  INTEGER DEFAULT -1,
+			end_pos    INTEGER DEFAULT -1,
+			comment    TEXT DEFAULT '',
+			color      TEXT DEFAULT '',
+			created_at INTEGER NOT NULL,
+			updated_at INTEGER NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_annotations_target ON annotations(target_id)`,
+		`CREATE TABLE IF NOT EXISTS favicons (
+			id         TEXT PRIMARY KEY,
+			mime       TEXT NOT NULL,
+			sizes      TEXT DEFAULT '',
+			created_at INTEGER NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS domain_favicons (
+			domain     TEXT PRIMARY KEY,
+			favicon_id TEXT NOT NULL,
+			updated_at INTEGER NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS domain_names (
+			domain       TEXT PRIMARY KEY,
+			display_name TEXT NOT NULL,
+			updated_at   INTEGER NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS activity_log (
+			id          INTEGER PRIMARY KEY AUTOINCREMENT,
+			created_at  INTEGER NOT NULL,
+			source      TEXT NOT NULL,
+			action      TEXT NOT NULL,
+			target_type TEXT NOT NULL,
+			target_id   TEXT NOT NULL,
+			before_json TEXT DEFAULT '',
+			after_json  TEXT DEFAULT '',
+			undo_of     INTEGER DEFAULT 0
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_activity_target ON activity_log(target_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_activity_undo ON activity_log(undo_of)`,
+		`CREATE TABLE IF NOT EXISTS note_revisions (
+			id          INTEGER PRIMARY KEY AUTOINCREMENT,
+			bookmark_id TEXT NOT NULL,
+			field       TEXT NOT NULL,
+			content     TEXT NOT NULL,
+			source      TEXT NOT NULL,
+			created_at  INTEGER NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_note_revisions_bookmark ON note_revisions(bookmark_id, field)`,
+		`CREATE TABLE IF NOT EXISTS link_checks (
+			bookmark_id TEXT PRIMARY KEY,
+			state       TEXT NOT NULL DEFAULT '',
+			status_code INTEGER NOT NULL DEFAULT 0,
+			final_url   TEXT NOT NULL DEFAULT '',
+			error       TEXT NOT NULL DEFAULT '',
+			baseline    TEXT NOT NULL DEFAULT '',
+			fingerprint TEXT NOT NULL DEFAULT '',
+			failures    INTEGER NOT NULL DEFAULT 0,
+			checked_at  INTEGER NOT NULL DEFAULT 0,
+			changed_at  INTEGER NOT NULL DEFAULT 0
+		)`,
+		`CREATE TABLE IF NOT EXISTS sync_state (
+			bookmark_id TEXT PRIMARY KEY,
+			snapshot    TEXT NOT NULL DEFAULT '',
+			hash        TEXT NOT NULL,
+			updated_at  INTEGER NOT NULL,
+			device      TEXT NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS sync_cursors (
+			device   TEXT PRIMARY KEY,
+			position INTEGER NOT NULL DEFAULT 0
+		)`,
+		`CREATE TABLE IF NOT EXISTS sync_conflicts (
+			id            INTEGER PRIMARY KEY AUTOINCREMENT,
+			bookmark_id   TEXT NOT NULL,
+			field         TEXT NOT NULL,
+			local_value   TEXT NOT NULL,
+			remote_value  TEXT NOT NULL,
+			remote_device TEXT NOT NULL,
+			winner        TEXT NOT NULL,
+			created_at    INTEGER NOT NULL,
+			resolved_at   INTEGER NOT NULL DEFAULT 0
+		)`,
 	}
 	for index, stmt := range stmts {
 		if index < 2 {
@@ -208,124 +356,229 @@ func (s *Service) SaveBookmark(input SaveInput) (*Bookmark, error) {
 		return nil, errors.New("缺少 url 或 html 字段")
 	}
 
+	sanitized, report, err := sanitizeHTML(input.HTML)
+	if err != nil {
+		return nil, fmt.Errorf("清理 HTML 失败: %w", err)
+	}
+	input.HTML = sanitized
+
 	id := uuid.New().String()
 	now := time.Now().UnixMilli()
 
-	domainDir := filepath.Join(s.dataDir, "pages", getDomain(input.URL))
+	domainDir, safeTitle := s.artifactLocation(id, input.URL, input.Title)
 	if err := os.MkdirAll(domainDir, 0o755); err != nil {
 		return nil, fmt.Errorf("创建目录失败: %w", err)
 	}
 
-	safeTitle := sanitizeFilename(input.Title, 80)
 	htmlPath := getUniqueFilePath(domainDir, safeTitle, ".html")
-	if err := os.WriteFile(htmlPath, []byte(input.HTML), 0o644); err != nil {
+	if err := s.writeArtifact(htmlPath, []byte(input.HTML)); err != nil {
 		return nil, fmt.Errorf("写 HTML 失败: %w", err)
 	}
 
 	thumbRelative := ""
+	var thumbData []byte
 	if strings.HasPrefix(input.Screenshot, "data:image/") {
 		parts := strings.SplitN(input.Screenshot, ",", 2)
 		if len(parts) == 2 {
 			if data, err := base64.StdEncoding.DecodeString(parts[1]); err == nil {
 				thumbPath := getUniqueFilePath(domainDir, safeTitle, ".png")
-				if err := os.WriteFile(thumbPath, data, 0o644); err == nil {
+				if err := s.writeArtifact(thumbPath, data); err == nil {
 					thumbRelative = toRelativePath(s.dataDir, thumbPath)
+					thumbData = data
 				}
 			}
 		}
 	}
+	fp := computeFingerprint([]byte(input.HTML), thumbData)
 
-	favicon := input.Favicon
-	if len(favicon) > 100000 {
-		favicon = ""
+	faviconID := s.storeFaviconDataURL(getDomain(input.URL), input.Favicon)
+	title, err := s.sealField(input.Title)
+	if err != nil {
+		return nil, err
 	}
 
-	_, err := s.db.Exec(`INSERT INTO bookmarks
-		(id, url, title, alias, favicon, file_path, thumb_path, file_size, created_at, bookmark_id, deleted_at, notes)
-		VALUES (?, ?, ?, '', ?, ?, ?, ?, ?, ?, 0, '')`,
-		id,
-		input.URL,
-		input.Title,
-		favicon,
-		toRelativePath(s.dataDir, htmlPath),
-		thumbRelative,
-		int64(len(input.HTML)),
-		now,
-		input.BookmarkID,
-	)
+	err = s.journaled(protocol.MethodBookmarkSave, "bookmark", id, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`INSERT INTO bookmarks
+		(id, url, normalized_url, domain, title, alias, favicon, favicon_id, file_path, thumb_path, file_size, created_at, bookmark_id, deleted_at, notes,
+		 content_hash, text_simhash, thumb_hash)
+		VALUES (?, ?, ?, ?, ?, '', '', ?, ?, ?, ?, ?, ?, 0, '', ?, ?, ?)`,
+			id,
+			input.URL,
+			s.normalizeURL(input.URL),
+			getDomain(input.URL),
+			title,
+			faviconID,
+			toRelativePath(s.dataDir, htmlPath),
+			thumbRelative,
+			s.artifactSize(toRelativePath(s.dataDir, htmlPath), thumbRelative),
+			now,
+			input.BookmarkID,
+			fp.ContentHash,
+			fp.TextSimhash,
+			fp.ThumbHash,
+		)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("写入数据库失败: %w", err)
 	}
 
-	return s.GetBookmark(id)
+	bm, err := s.GetBookmark(id)
+	if err != nil {
+		return nil, err
+	}
+	if report.total() > 0 {
+		bm.Sanitized = &report
+	}
+	return bm, nil
 }
 
 func (s *Service) ExistsByURL(rawURL string) (bool, error) {
-	items, _, err := s.listBookmarks("", rawURL, 1, 0)
-	return len(items) > 0, err
+	where, args := s.bookmarkWhere("deleted_at = 0", "", rawURL, BookmarkFilter{})
+	var exists int
+	err := s.db.QueryRow("SELECT 1 FROM bookmarks WHERE "+where+" LIMIT 1", args...).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *Service) ListBookmarks(query ListQuery) (*BookmarksResult, error) {
+	return s.listBookmarks(query, true)
 }
 
-func (s *Service) ListBookmarks(limit, offset int, q string) (*BookmarksResult, error) {
-	items, total, err := s.listBookmarks(q, "", limit, offset)
+// listBookmarks 的 withThumbs 为 false 时不读取缩略图，供存档服务等不展示缩略图的地方使用。
+func (s *Service) listBookmarks(query ListQuery, withThumbs bool) (*BookmarksResult, error) {
+	sort, err := query.sortColumn(s.EncryptionStatus().Enabled)
+	if err != nil {
+		return nil, err
+	}
+	if err := query.BookmarkFilter.validate(); err != nil {
+		return nil, err
+	}
+	if query.Cursor != "" && query.Offset > 0 {
+		return nil, fmt.Errorf("cursor 与 offset 不能同时使用")
+	}
+	limit := pageLimit(query.Limit)
+	where, args := s.bookmarkWhere("deleted_at = 0", query.Q, "", query.BookmarkFilter)
+
+	result := &BookmarksResult{}
+	if !query.SkipTotal {
+		var total int
+		if err := s.db.QueryRow("SELECT COUNT(*) FROM bookmarks WHERE "+where, args...).Scan(&total); err != nil {
+			return nil, err
+		}
+		result.Total = &total
+	}
+
+	if query.Cursor != "" {
+		cursor, err := decodeListCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		clause, cursorArgs, err := sort.after(cursor, query.Sort, query.descending())
+		if err != nil {
+			return nil, err
+		}
+		where += " AND " + clause
+		args = append(args, cursorArgs...)
+	}
+
+	items, err := s.selectBookmarks(where, args, sort.orderBy(query.descending()), limit+1, query.Offset)
 	if err != nil {
 		return nil, err
 	}
-	return &BookmarksResult{Items: items, Total: total}, nil
+	if len(items) > limit {
+		items = items[:limit]
+		last := items[limit-1]
+		result.NextCursor = encodeListCursor(listCursor{
+			Sort:      query.Sort,
+			Order:     strings.ToLower(query.Order),
+			Value:     sort.value(last),
+			CreatedAt: last.CreatedAt,
+			ID:        last.ID,
+		})
+	}
+	if withThumbs {
+		for i := range items {
+			s.attachThumbData(&items[i])
+		}
+	}
+	result.Items = items
+
+	if query.Facets {
+		if result.Facets, err = s.bookmarkFacets(query.Q, query.BookmarkFilter); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
 }
 
 func (s *Service) ListRecentBookmarks(limit int) ([]Bookmark, error) {
 	if limit <= 0 {
 		limit = 5
 	}
-	items, _, err := s.listBookmarks("", "", limit, 0)
-	return items, err
+	return s.queryBookmarks("deleted_at = 0", nil, defaultOrderBy, limit, 0)
 }
 
-func (s *Service) listBookmarks(q, urlParam string, limit, offset int) ([]Bookmark, int, error) {
-	if limit <= 0 {
-		limit = 50
-	}
-	where := "deleted_at = 0"
-	args := []any{}
-	if urlParam != "" {
-		where += " AND url = ?"
-		args = append(args, urlParam)
-	} else if q != "" {
-		where += " AND (title LIKE ? OR alias LIKE ? OR url LIKE ? OR notes LIKE ?)"
-		like := "%" + q + "%"
-		args = append(args, like, like, like, like)
-	}
-
-	var total int
-	row := s.db.QueryRow("SELECT COUNT(*) FROM bookmarks WHERE "+where, args...)
-	if err := row.Scan(&total); err != nil {
-		return nil, 0, err
+func (s *Service) queryBookmarks(where string, args []any, orderBy string, limit, offset int) ([]Bookmark, error) {
+	items, err := s.selectBookmarks(where, args, orderBy, limit, offset)
+	for i := range items {
+		s.attachThumbData(&items[i])
 	}
+	return items, err
+}
 
-	queryArgs := append(args, limit, offset)
-	rows, err := s.db.Query("SELECT * FROM bookmarks WHERE "+where+" ORDER BY created_at DESC LIMIT ? OFFSET ?", queryArgs...)
+// selectBookmarks 与 queryBookmarks 相同但不读取缩略图，供导出、订阅源等只需要字段的地方使用。
+func (s *Service) selectBookmarks(where string, args []any, orderBy string, limit, offset int) ([]Bookmark, error) {
+	queryArgs := append(append([]any{}, args...), limit, offset)
+	rows, err := s.db.Query("SELECT "+bookmarkColumns+" FROM bookmarks WHERE "+where+" ORDER BY "+orderBy+" LIMIT ? OFFSET ?", queryArgs...)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
 	defer rows.Close()
 
-	var items []Bookmark
+	items := []Bookmark{}
 	for rows.Next() {
 		bm, err := scanBookmark(rows)
 		if err != nil {
-			return nil, 0, err
+			return nil, err
 		}
-		s.attachThumbData(bm)
+		s.revealBookmark(bm)
 		items = append(items, *bm)
 	}
-	if items == nil {
-		items = []Bookmark{}
+	return items, rows.Err()
+}
+
+func (s *Service) bookmarkWhere(base, q, urlParam string, filter BookmarkFilter) (string, []any) {
+	where := base
+	args := []any{}
+	if urlParam != "" {
+		where += " AND normalized_url = ?"
+		args = append(args, s.normalizeURL(urlParam))
+	} else if q != "" {
+		// 已加密的标题、别名和备注无法在 SQL 中匹配，直接跳过，以免密文碰巧命中关键词。
+		where += " AND ((title LIKE ? AND title NOT LIKE ?) OR (alias LIKE ? AND alias NOT LIKE ?) OR url LIKE ?" +
+			" OR (notes LIKE ? AND notes NOT LIKE ?)" +
+			" OR id IN (SELECT target_id FROM annotations WHERE (exact LIKE ? AND exact NOT LIKE ?) OR (comment LIKE ? AND comment NOT LIKE ?)))"
+		like := "%" + q + "%"
+		sealed := sealedFieldPrefix + "%"
+		args = append(args, like, sealed, like, sealed, like, like, sealed, like, sealed, like, sealed)
 	}
-	return items, total, nil
+	return filter.apply(where, args)
 }
 
 func (s *Service) GetBookmark(id string) (*Bookmark, error) {
-	row := s.db.QueryRow("SELECT * FROM bookmarks WHERE id = ?", id)
+	bm, err := s.lookupBookmark(id)
+	if bm != nil {
+		s.attachThumbData(bm)
+	}
+	return bm, err
+}
+
+// lookupBookmark 与 GetBookmark 相同但不读取缩略图，供一次返回大量收藏的接口使用，缩略图由前端按需获取。
+func (s *Service) lookupBookmark(id string) (*Bookmark, error) {
+	row := s.db.QueryRow("SELECT "+bookmarkColumns+" FROM bookmarks WHERE id = ?", id)
 	bm, err := scanBookmark(row)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -333,10 +586,30 @@ func (s *Service) GetBookmark(id string) (*Bookmark, error) {
 	if err != nil {
 		return nil, err
 	}
-	s.attachThumbData(bm)
+	s.revealBookmark(bm)
 	return bm, nil
 }
 
+type ThumbnailResult struct {
+	ID      string `json:"id"`
+	DataURL string `json:"dataUrl"`
+}
+
+func (s *Service) GetThumbnail(id string) (*ThumbnailResult, error) {
+	var thumbPath string
+	if err := s.db.QueryRow("SELECT thumb_path FROM bookmarks WHERE id = ?", id).Scan(&thumbPath); err != nil {
+		return nil, err
+	}
+	if thumbPath == "" {
+		return nil, errors.New("该收藏没有缩略图")
+	}
+	data, err := s.readArtifact(thumbPath)
+	if err != nil {
+		return nil, err
+	}
+	return &ThumbnailResult{ID: id, DataURL: "data:image/png;base64," + base64.StdEncoding.EncodeToString(data)}, nil
+}
+
 func (s *Service) GetBookmarkHTML(id string) (*BookmarkContent, error) {
 	bm, err := s.GetBookmark(id)
 	if err != nil {
@@ -345,10 +618,12 @@ func (s *Service) GetBookmarkHTML(id string) (*BookmarkContent, error) {
 	if bm == nil {
 		return nil, sql.ErrNoRows
 	}
-	data, err := os.ReadFile(getAbsoluteFilePath(s.dataDir, bm.FilePath))
+	data, err := s.readArtifact(bm.FilePath)
 	if err != nil {
 		return nil, err
 	}
+	bm.LastOpenedAt = time.Now().UnixMilli()
+	_, _ = s.db.Exec("UPDATE bookmarks SET last_opened_at = ? WHERE id = ?", bm.LastOpenedAt, id)
 	return &BookmarkContent{
 		Bookmark: *bm,
 		HTML:     string(data),
@@ -356,75 +631,74 @@ func (s *Service) GetBookmarkHTML(id string) (*BookmarkContent, error) {
 }
 
 func (s *Service) UpdateAlias(id, alias string) error {
-	result, err := s.db.Exec("UPDATE bookmarks SET alias = ? WHERE id = ? AND deleted_at = 0", alias, id)
-	if err != nil {
-		return err
-	}
-	if affected, _ := result.RowsAffected(); affected == 0 {
-		return sql.ErrNoRows
-	}
-	return nil
+	return s.updateRevisioned(protocol.MethodBookmarkUpdateAlias, id, "alias", alias)
 }
 
 func (s *Service) UpdateNotes(id, notes string) error {
-	result, err := s.db.Exec("UPDATE bookmarks SET notes = ? WHERE id = ? AND deleted_at = 0", notes, id)
-	if err != nil {
-		return err
-	}
-	if affected, _ := result.RowsAffected(); affected == 0 {
-		return sql.ErrNoRows
-	}
-	return nil
+	return s.updateRevisioned(protocol.MethodBookmarkUpdateNotes, id, "notes", notes)
 }
 
 func (s *Service) DeleteBookmark(id string) error {
-	bm, err := s.GetBookmark(id)
-	if err != nil {
-		return err
-	}
-	if bm == nil || bm.DeletedAt > 0 {
-		return sql.ErrNoRows
-	}
-	_, err = s.db.Exec("UPDATE bookmarks SET deleted_at = ? WHERE id = ?", time.Now().UnixMilli(), id)
-	return err
+	return s.journaled(protocol.MethodBookmarkDelete, "bookmark", id, func(tx *sql.Tx) error {
+		return execAffected(tx, "UPDATE bookmarks SET deleted_at = ? WHERE id = ? AND deleted_at = 0", time.Now().UnixMilli(), id)
+	})
 }
 
-func (s *Service) ListTrash() ([]Bookmark, error) {
-	rows, err := s.db.Query("SELECT * FROM bookmarks WHERE deleted_at > 0 ORDER BY deleted_at DESC")
-	if err != nil {
-		return nil, err
+func (s *Service) ListTrash(query TrashQuery) (*BookmarksResult, error) {
+	limit := pageLimit(query.Limit)
+	where, args := "deleted_at > 0", []any{}
+
+	result := &BookmarksResult{}
+	if !query.SkipTotal {
+		var total int
+		if err := s.db.QueryRow("SELECT COUNT(*) FROM bookmarks WHERE " + where).Scan(&total); err != nil {
+			return nil, err
+		}
+		result.Total = &total
 	}
-	defer rows.Close()
 
-	var items []Bookmark
-	for rows.Next() {
-		bm, err := scanBookmark(rows)
+	if query.Cursor != "" {
+		cursor, err := decodeListCursor(query.Cursor)
 		if err != nil {
 			return nil, err
 		}
-		s.attachThumbData(bm)
-		items = append(items, *bm)
+		where += " AND (deleted_at < ? OR (deleted_at = ? AND id < ?))"
+		args = append(args, cursor.CreatedAt, cursor.CreatedAt, cursor.ID)
 	}
-	if items == nil {
-		items = []Bookmark{}
-	}
-	return items, nil
-}
 
-func (s *Service) RestoreBookmark(id string) error {
-	bm, err := s.GetBookmark(id)
+	items, err := s.queryBookmarks(where, args, "deleted_at DESC, id DESC", limit+1, 0)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if bm == nil || bm.DeletedAt == 0 {
-		return sql.ErrNoRows
+	if len(items) > limit {
+		items = items[:limit]
+		last := items[limit-1]
+		result.NextCursor = encodeListCursor(listCursor{CreatedAt: last.DeletedAt, ID: last.ID})
 	}
-	_, err = s.db.Exec("UPDATE bookmarks SET deleted_at = 0 WHERE id = ?", id)
-	return err
+
+	if retentionDays := s.trashRetentionDays(); retentionDays > 0 {
+		now := time.Now().UnixMilli()
+		for i := range items {
+			days := expiresInDays(items[i].DeletedAt, retentionDays, now)
+			items[i].ExpiresInDays = &days
+		}
+	}
+	result.Items = items
+	return result, nil
+}
+
+func (s *Service) RestoreBookmark(id string) error {
+	return s.journaled(protocol.MethodTrashRestore, "bookmark", id, func(tx *sql.Tx) error {
+		return execAffected(tx, "UPDATE bookmarks SET deleted_at = 0 WHERE id = ? AND deleted_at > 0", id)
+	})
 }
 
 func (s *Service) PermanentDelete(id string) error {
-	row := s.db.QueryRow("SELECT * FROM bookmarks WHERE id = ?", id)
+	return s.permanentDelete(protocol.MethodTrashDelete, id)
+}
+
+func (s *Service) permanentDelete(action, id string) error {
+	row := s.db.QueryRow("SELECT "+bookmarkColumns+" FROM bookmarks WHERE id = ?", id)
 	bm, err := scanBookmark(row)
 	if err == sql.ErrNoRows {
 		return sql.ErrNoRows
@@ -433,44 +707,78 @@ func (s *Service) PermanentDelete(id string) error {
 		return err
 	}
 
-	if bm.FilePath != "" {
-		htmlFile := getAbsoluteFilePath(s.dataDir, bm.FilePath)
+	err = s.journaled(action, "bookmark", id, func(tx *sql.Tx) error {
+		if err := deleteBookmarkRecords(tx, id); err != nil {
+			return err
+		}
+		_, err := tx.Exec("DELETE FROM bookmarks WHERE id = ?", id)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	s.removeBookmarkFiles(bm.FilePath, bm.ThumbPath)
+	return nil
+}
+
+// deleteBookmarkRecords 删除依附于收藏的批注、版本和链接检查记录，收藏本身由调用方删除。
+func deleteBookmarkRecords(tx *sql.Tx, id string) error {
+	for _, stmt := range []string{
+		"DELETE FROM annotations WHERE target_id = ?",
+		"DELETE FROM note_revisions WHERE bookmark_id = ?",
+		"DELETE FROM link_checks WHERE bookmark_id = ?",
+	} {
+		if _, err := tx.Exec(stmt, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Service) removeBookmarkFiles(filePath, thumbPath string) {
+	if filePath != "" {
+		htmlFile := getAbsoluteFilePath(s.dataDir, filePath)
 		_ = os.Remove(htmlFile)
 		dir := filepath.Dir(htmlFile)
 		if entries, err := os.ReadDir(dir); err == nil && len(entries) == 0 {
 			_ = os.Remove(dir)
 		}
 	}
-	if bm.ThumbPath != "" {
-		_ = os.Remove(getAbsoluteFilePath(s.dataDir, bm.ThumbPath))
+	if thumbPath != "" {
+		_ = os.Remove(getAbsoluteFilePath(s.dataDir, thumbPath))
 	}
-	_, err = s.db.Exec("DELETE FROM bookmarks WHERE id = ?", id)
-	return err
 }
 
 func (s *Service) EmptyTrash() (*EmptyTrashResult, error) {
-	items, err := s.ListTrash()
+	ids, err := s.trashIDs(0)
 	if err != nil {
 		return nil, err
 	}
 	count := 0
-	for _, item := range items {
-		if err := s.PermanentDelete(item.ID); err == nil {
+	for _, id := range ids {
+		if err := s.permanentDelete(protocol.MethodTrashEmpty, id); err == nil {
 			count++
 		}
 	}
 	return &EmptyTrashResult{Deleted: count}, nil
 }
 
-func (s *Service) GetStats() Stats {
-	var total int
-	var totalSize int64
-	_ = s.db.QueryRow("SELECT COUNT(*), COALESCE(SUM(file_size), 0) FROM bookmarks WHERE deleted_at = 0").Scan(&total, &totalSize)
-	return Stats{
-		Total:      total,
-		TotalSize:  totalSize,
-		TrashCount: s.getTrashCount(),
+func (s *Service) GetStats() (Stats, error) {
+	var stats Stats
+	if err := s.db.QueryRow(`SELECT COUNT(*), COALESCE(SUM(file_size), 0),
+		COALESCE(SUM(read_status = 'unread' AND archived = 0), 0),
+		COALESCE(SUM(read_status = 'reading' AND archived = 0), 0),
+		COALESCE(SUM(starred), 0),
+		COALESCE(SUM(archived), 0)
+		FROM bookmarks WHERE deleted_at = 0`).Scan(&stats.Total, &stats.TotalSize, &stats.Unread, &stats.Reading, &stats.Starred, &stats.Archived); err != nil {
+		return Stats{}, err
+	}
+	trashCount, err := s.getTrashCount()
+	if err != nil {
+		return Stats{}, err
 	}
+	stats.TrashCount = trashCount
+	return stats, nil
 }
 
 func (s *Service) GetSettings() Settings {
@@ -479,6 +787,11 @@ func (s *Service) GetSettings() Settings {
 		Enabled:            autoStart,
 		AutoStart:          autoStart,
 		ExtensionInstalled: s.IsExtensionInstalled(),
+		TrashRetentionDays: s.trashRetentionDays(),
+		URLStripParams:     s.urlStripParams(),
+		RevisionLimit:      s.revisionLimit(),
+		LinkCheckInterval:  s.linkCheckInterval(),
+		Feed:               s.feedConfig(),
 	}
 }
 
@@ -489,6 +802,19 @@ func (s *Service) SetAutoStart(enabled bool) (Settings, error) {
 	return s.GetSettings(), nil
 }
 
+func (s *Service) SetTrashRetention(days int) (Settings, error) {
+	if days < 0 || days > maxTrashRetentionDays {
+		return Settings{}, fmt.Errorf("保留天数需在 0 到 %d 之间", maxTrashRetentionDays)
+	}
+	if err := s.setSetting(protocol.MethodSettingsSetTrash, metaTrashRetention, strconv.Itoa(days)); err != nil {
+		return Settings{}, err
+	}
+	if _, err := s.PurgeExpiredTrash(); err != nil {
+		return Settings{}, err
+	}
+	return s.GetSettings(), nil
+}
+
 func (s *Service) PingExtension() error {
 	return s.setMeta(metaLastExtension, fmt.Sprintf("%d", time.Now().UnixMilli()))
 }
@@ -614,23 +940,25 @@ func (s *Service) OpenExternal(rawURL string) error {
 	return openExternal(rawURL)
 }
 
-func (s *Service) getTrashCount() int {
+func (s *Service) getTrashCount() (int, error) {
 	var count int
-	_ = s.db.QueryRow("SELECT COUNT(*) FROM bookmarks WHERE deleted_at > 0").Scan(&count)
-	return count
+	err := s.db.QueryRow("SELECT COUNT(*) FROM bookmarks WHERE deleted_at > 0").Scan(&count)
+	return count, err
 }
 
 func (s *Service) attachThumbData(bm *Bookmark) {
 	if bm == nil || bm.ThumbPath == "" {
 		return
 	}
-	data, err := os.ReadFile(getAbsoluteFilePath(s.dataDir, bm.ThumbPath))
+	data, err := s.readArtifact(bm.ThumbPath)
 	if err != nil {
 		return
 	}
 	bm.ThumbData = "data:image/png;base64," + base64.StdEncoding.EncodeToString(data)
 }
 
+const bookmarkColumns = "id, url, title, alias, favicon, favicon_id, file_path, thumb_path, file_size, created_at, tags, bookmark_id, deleted_at, notes, read_status, read_status_at, starred, starred_at, color_label, archived, archived_at, domain, last_opened_at"
+
 func scanBookmark(row interface{ Scan(dest ...any) error }) (*Bookmark, error) {
 	bm := &Bookmark{}
 	err := row.Scan(
@@ -639,6 +967,7 @@ func scanBookmark(row interface{ Scan(dest ...any) error }) (*Bookmark, error) {
 		&bm.Title,
 		&bm.Alias,
 		&bm.Favicon,
+		&bm.FaviconID,
 		&bm.FilePath,
 		&bm.ThumbPath,
 		&bm.FileSize,
@@ -647,6 +976,15 @@ func scanBookmark(row interface{ Scan(dest ...any) error }) (*Bookmark, error) {
 		&bm.BookmarkID,
 		&bm.DeletedAt,
 		&bm.Notes,
+		&bm.ReadStatus,
+		&bm.ReadAt,
+		&bm.Starred,
+		&bm.StarredAt,
+		&bm.ColorLabel,
+		&bm.Archived,
+		&bm.ArchivedAt,
+		&bm.Domain,
+		&bm.LastOpenedAt,
 	)
 	return bm, err
 }
@@ -657,6 +995,15 @@ func (s *Service) setMeta(key, value string) error {
 	return err
 }
 
+// setSetting 与 setMeta 相同，但会写入操作记录，用于用户可见的设置项。
+func (s *Service) setSetting(action, key, value string) error {
+	return s.journaled(action, "setting", key, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`INSERT INTO app_meta (key, value) VALUES (?, ?)
+			ON CONFLICT(key) DO UPDATE SET value = excluded.value`, key, value)
+		return err
+	})
+}
+
 func (s *Service) getMeta(key string) (string, error) {
 	var value string
 	err := s.db.QueryRow("SELECT value FROM app_meta WHERE key = ?", key).Scan(&value)
@@ -666,28 +1013,73 @@ func (s *Service) getMeta(key string) (string, error) {
 	return value, err
 }
 
-func (s *Service) purgeExpiredTrash() {
-	cutoff := time.Now().UnixMilli() - trashRetentionMs
-	rows, err := s.db.Query("SELECT id FROM bookmarks WHERE deleted_at > 0 AND deleted_at < ?", cutoff)
+func (s *Service) trashRetentionDays() int {
+	value, err := s.getMeta(metaTrashRetention)
+	if err != nil || value == "" {
+		return defaultTrashRetentionDays
+	}
+	days, convErr := strconv.Atoi(value)
+	if convErr != nil || days < 0 {
+		return defaultTrashRetentionDays
+	}
+	return days
+}
+
+func expiresInDays(deletedAt int64, retentionDays int, now int64) int {
+	remaining := deletedAt + int64(retentionDays)*dayMs - now
+	if remaining <= 0 {
+		return 0
+	}
+	return int((remaining + dayMs - 1) / dayMs)
+}
+
+func (s *Service) PurgeExpiredTrash() (int, error) {
+	retentionDays := s.trashRetentionDays()
+	if retentionDays == 0 {
+		return 0, nil
+	}
+	ids, err := s.trashIDs(time.Now().UnixMilli() - int64(retentionDays)*dayMs)
 	if err != nil {
-		return
+		return 0, err
+	}
+
+	count := 0
+	for _, id := range ids {
+		if err := s.permanentDelete(activityTrashExpire, id); err == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// trashIDs 返回删除时间早于 before 的回收站条目，before 为 0 时返回全部。
+func (s *Service) trashIDs(before int64) ([]string, error) {
+	query := "SELECT id FROM bookmarks WHERE deleted_at > 0"
+	args := []any{}
+	if before > 0 {
+		query += " AND deleted_at < ?"
+		args = append(args, before)
+	}
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
 	}
 	defer rows.Close()
+
 	var ids []string
 	for rows.Next() {
 		var id string
-		if err := rows.Scan(&id); err == nil {
-			ids = append(ids, id)
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
 		}
+		ids = append(ids, id)
 	}
-	for _, id := range ids {
-		_ = s.PermanentDelete(id)
-	}
+	return ids, rows.Err()
 }
 
 func (s *Service) migrateOldFiles() {
 	uuidPattern := regexp.MustCompile(`^pages/[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\.html$`)
-	rows, err := s.db.Query("SELECT * FROM bookmarks")
+	rows, err := s.db.Query("SELECT " + bookmarkColumns + " FROM bookmarks")
 	if err != nil {
 		return
 	}
@@ -737,7 +1129,16 @@ func getDomain(rawURL string) string {
 	if err != nil || parsed.Hostname() == "" {
 		return "unknown"
 	}
-	return parsed.Hostname()
+	return strings.ToLower(parsed.Hostname())
+}
+
+// artifactLocation 返回新页面和截图所在的目录及不含扩展名的文件名。启用加密后统一放在 vault 目录并以收藏 ID 命名，
+// 目录和文件名不再透露域名和标题。
+func (s *Service) artifactLocation(id, rawURL, title string) (string, string) {
+	if cfg, _ := s.encryptionConfig(); cfg != nil {
+		return filepath.Join(s.dataDir, sealedPagesDir), id
+	}
+	return filepath.Join(s.dataDir, "pages", getDomain(rawURL)), sanitizeFilename(title, 80)
 }
 
 func sanitizeFilename(name string, maxLen int) string {
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestService 在临时目录中创建一个独立的资料库。
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AppData", t.TempDir())
+	s, err := New("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+const testScreenshot = "data:image/png;base64,iVBORw0KGgo="
+
+func saveTestBookmark(t *testing.T, s *Service, url, title, body string) *Bookmark {
+	t.Helper()
+	bm, err := s.SaveBookmark(SaveInput{URL: url, Title: title, HTML: "<html><body>" + body + "</body></html>", Screenshot: testScreenshot})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bm
+}
+
+func trashExpiry(t *testing.T, s *Service) map[string]*int {
+	t.Helper()
+	result, err := s.ListTrash(TrashQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiry := map[string]*int{}
+	for _, item := range result.Items {
+		expiry[item.ID] = item.ExpiresInDays
+	}
+	return expiry
+}
+
+func TestTrashRetention(t *testing.T) {
+	s := newTestService(t)
+	if got := s.GetSettings().TrashRetentionDays; got != defaultTrashRetentionDays {
+		t.Fatalf("default retention = %d", got)
+	}
+	for _, days := range []int{-1, maxTrashRetentionDays + 1} {
+		if _, err := s.SetTrashRetention(days); err == nil {
+			t.Fatalf("retention %d accepted", days)
+		}
+	}
+
+	now := time.Now().UnixMilli()
+	var ids []string
+	for i, age := range []int64{10 * dayMs, 2 * dayMs, 6*dayMs + dayMs/2} {
+		bm := saveTestBookmark(t, s, "https://example.com/"+string(rune('a'+i)), "T", "<p>t</p>")
+		if _, err := s.RunBulk(BulkRequest{IDs: []string{bm.ID}, Action: bulkActionTrash}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.db.Exec("UPDATE bookmarks SET deleted_at = ? WHERE id = ?", now-age, bm.ID); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, bm.ID)
+	}
+	old, recent, halfDay := ids[0], ids[1], ids[2]
+
+	// 剩余时间向上取整：还剩半天显示为 1 天。
+	expiry := trashExpiry(t, s)
+	if *expiry[old] != 0 || *expiry[recent] != 5 || *expiry[halfDay] != 1 {
+		t.Fatalf("expiry = old %d, recent %d, half day %d", *expiry[old], *expiry[recent], *expiry[halfDay])
+	}
+	if purged, err := s.PurgeExpiredTrash(); err != nil || purged != 1 {
+		t.Fatalf("purge = %d, %v", purged, err)
+	}
+	if got, _ := s.GetBookmark(old); got != nil {
+		t.Fatal("expired bookmark still present")
+	}
+
+	if _, err := s.SetTrashRetention(0); err != nil {
+		t.Fatal(err)
+	}
+	for id, days := range trashExpiry(t, s) {
+		if days != nil {
+			t.Fatalf("%s expires in %d days although retention is off", id, *days)
+		}
+	}
+	if purged, err := s.PurgeExpiredTrash(); err != nil || purged != 0 {
+		t.Fatalf("purge with retention off = %d, %v", purged, err)
+	}
+
+	// 缩短保留期时立即清理已超期的条目。
+	if _, err := s.SetTrashRetention(5); err != nil {
+		t.Fatal(err)
+	}
+	expiry = trashExpiry(t, s)
+	if len(expiry) != 1 || *expiry[recent] != 3 {
+		t.Fatalf("trash after shortening retention = %v", expiry)
+	}
+}
@@ -0,0 +1,128 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"chrome-collect-tray/internal/protocol"
+)
+
+const (
+	readStatusUnread  = "unread"
+	readStatusReading = "reading"
+	readStatusRead    = "read"
+)
+
+var colorLabels = map[string]bool{
+	"":       true,
+	"red":    true,
+	"orange": true,
+	"yellow": true,
+	"green":  true,
+	"blue":   true,
+	"purple": true,
+	"gray":   true,
+}
+
+type StateUpdate struct {
+	ReadStatus *string `json:"readStatus"`
+	Starred    *bool   `json:"starred"`
+	ColorLabel *string `json:"colorLabel"`
+	Archived   *bool   `json:"archived"`
+}
+
+type StateUpdateResult struct {
+	Updated int `json:"updated"`
+}
+
+func validReadStatus(status string) bool {
+	switch status {
+	case readStatusUnread, readStatusReading, readStatusRead:
+		return true
+	}
+	return false
+}
+
+func (u StateUpdate) validate() error {
+	if u.ReadStatus == nil && u.Starred == nil && u.ColorLabel == nil && u.Archived == nil {
+		return errors.New("缺少要更新的状态")
+	}
+	if u.ReadStatus != nil && !validReadStatus(*u.ReadStatus) {
+		return fmt.Errorf("无效的阅读状态: %s", *u.ReadStatus)
+	}
+	if u.ColorLabel != nil && !colorLabels[*u.ColorLabel] {
+		return fmt.Errorf("无效的颜色标签: %s", *u.ColorLabel)
+	}
+	return nil
+}
+
+func (s *Service) SetBookmarkState(ids []string, update StateUpdate) (*StateUpdateResult, error) {
+	if len(ids) == 0 {
+		return nil, errors.New("缺少收藏 ID")
+	}
+	if err := update.validate(); err != nil {
+		return nil, err
+	}
+
+	sets, args := update.assignments(time.Now().UnixMilli())
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := "UPDATE bookmarks SET " + sets + " WHERE id = ? AND deleted_at = 0"
+	result := &StateUpdateResult{}
+	for _, id := range ids {
+		err := s.journal(tx, protocol.MethodBookmarkSetState, "bookmark", id, func() error {
+			return execAffected(tx, query, append(args, id)...)
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		result.Updated++
+	}
+	if result.Updated == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return result, tx.Commit()
+}
+
+// assignments 生成更新语句：状态不变时保留原时间戳，进入新状态时记为 now，回到未读、取消星标或取消归档时清零。
+func (u StateUpdate) assignments(now int64) (string, []any) {
+	var sets []string
+	var args []any
+	if u.ReadStatus != nil {
+		sets = append(sets, "read_status_at = CASE WHEN ? = ? THEN 0 WHEN read_status = ? THEN read_status_at ELSE ? END", "read_status = ?")
+		args = append(args, *u.ReadStatus, readStatusUnread, *u.ReadStatus, now, *u.ReadStatus)
+	}
+	if u.Starred != nil {
+		value := boolToInt(*u.Starred)
+		sets = append(sets, "starred_at = CASE WHEN ? = 0 THEN 0 WHEN starred = 1 THEN starred_at ELSE ? END", "starred = ?")
+		args = append(args, value, now, value)
+	}
+	if u.ColorLabel != nil {
+		sets = append(sets, "color_label = ?")
+		args = append(args, *u.ColorLabel)
+	}
+	if u.Archived != nil {
+		value := boolToInt(*u.Archived)
+		sets = append(sets, "archived_at = CASE WHEN ? = 0 THEN 0 WHEN archived = 1 THEN archived_at ELSE ? END", "archived = ?")
+		args = append(args, value, now, value)
+	}
+	return strings.Join(sets, ", "), args
+}
+
+func boolToInt(value bool) int {
+	if value {
+		return 1
+	}
+	return 0
+}
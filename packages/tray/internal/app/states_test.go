@@ -0,0 +1,137 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func setState(t *testing.T, s *Service, ids []string, update StateUpdate) *Bookmark {
+	t.Helper()
+	if _, err := s.SetBookmarkState(ids, update); err != nil {
+		t.Fatal(err)
+	}
+	bm, err := s.lookupBookmark(ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bm
+}
+
+func TestSetBookmarkStateTimestamps(t *testing.T) {
+	s := newTestService(t)
+	id := saveTestBookmark(t, s, "https://example.com/a", "A", "<p>a</p>").ID
+	ids := []string{id}
+	yes, no := true, false
+	reading, read, unread := readStatusReading, readStatusRead, readStatusUnread
+	recent := func(at int64) bool { return at > 0 && time.Since(time.UnixMilli(at)) < time.Minute }
+
+	bm := setState(t, s, ids, StateUpdate{ReadStatus: &reading, Starred: &yes, Archived: &yes})
+	if bm.ReadStatus != readStatusReading || !recent(bm.ReadAt) || !bm.Starred || !recent(bm.StarredAt) || !bm.Archived || !recent(bm.ArchivedAt) {
+		t.Fatalf("after setting states: %+v", bm)
+	}
+
+	// 重复设置相同状态不改变时间戳；切换到其他阅读状态时更新。
+	if _, err := s.db.Exec("UPDATE bookmarks SET read_status_at = 1, starred_at = 1, archived_at = 1 WHERE id = ?", id); err != nil {
+		t.Fatal(err)
+	}
+	bm = setState(t, s, ids, StateUpdate{ReadStatus: &reading, Starred: &yes, Archived: &yes})
+	if bm.ReadAt != 1 || bm.StarredAt != 1 || bm.ArchivedAt != 1 {
+		t.Fatalf("unchanged states touched timestamps: %+v", bm)
+	}
+	if bm = setState(t, s, ids, StateUpdate{ReadStatus: &read}); !recent(bm.ReadAt) {
+		t.Fatalf("read_status_at after reading -> read = %d", bm.ReadAt)
+	}
+
+	bm = setState(t, s, ids, StateUpdate{ReadStatus: &unread, Starred: &no, Archived: &no})
+	if bm.ReadStatus != readStatusUnread || bm.ReadAt != 0 || bm.Starred || bm.StarredAt != 0 || bm.Archived || bm.ArchivedAt != 0 {
+		t.Fatalf("after clearing states: %+v", bm)
+	}
+
+	red, none := "red", ""
+	if bm = setState(t, s, ids, StateUpdate{ColorLabel: &red}); bm.ColorLabel != "red" {
+		t.Fatalf("color = %q", bm.ColorLabel)
+	}
+	if bm = setState(t, s, ids, StateUpdate{ColorLabel: &none}); bm.ColorLabel != "" {
+		t.Fatalf("cleared color = %q", bm.ColorLabel)
+	}
+}
+
+func TestSetBookmarkStateValidation(t *testing.T) {
+	s := newTestService(t)
+	live := saveTestBookmark(t, s, "https://example.com/a", "A", "<p>a</p>").ID
+	trashed := saveTestBookmark(t, s, "https://example.com/b", "B", "<p>b</p>").ID
+	if _, err := s.RunBulk(BulkRequest{IDs: []string{trashed}, Action: bulkActionTrash}); err != nil {
+		t.Fatal(err)
+	}
+	yes := true
+	skimmed, pink := "skimmed", "pink"
+	for _, c := range []struct {
+		ids    []string
+		update StateUpdate
+	}{
+		{nil, StateUpdate{Starred: &yes}},
+		{[]string{live}, StateUpdate{}},
+		{[]string{live}, StateUpdate{ReadStatus: &skimmed}},
+		{[]string{live}, StateUpdate{ColorLabel: &pink}},
+	} {
+		if _, err := s.SetBookmarkState(c.ids, c.update); err == nil {
+			t.Errorf("invalid update accepted: %v %+v", c.ids, c.update)
+		}
+	}
+	if _, err := s.SetBookmarkState([]string{trashed, "missing"}, StateUpdate{Starred: &yes}); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("update of trashed/missing ids = %v", err)
+	}
+	result, err := s.SetBookmarkState([]string{live, trashed}, StateUpdate{Starred: &yes})
+	if err != nil || result.Updated != 1 {
+		t.Fatalf("mixed update = %+v, %v", result, err)
+	}
+}
+
+func TestBookmarkStateFiltersAndStats(t *testing.T) {
+	s := newTestService(t)
+	var ids []string
+	for _, path := range []string{"a", "b", "c", "d"} {
+		ids = append(ids, saveTestBookmark(t, s, "https://example.com/"+path, path, "<p>"+path+"</p>").ID)
+	}
+	yes, no := true, false
+	reading, read, blue := readStatusReading, readStatusRead, "blue"
+	setState(t, s, ids[0:1], StateUpdate{ReadStatus: &reading, Starred: &yes})
+	setState(t, s, ids[1:3], StateUpdate{ReadStatus: &read, ColorLabel: &blue})
+	setState(t, s, ids[2:3], StateUpdate{Archived: &yes})
+
+	sorted := func(values ...string) []string {
+		values = slices.Clone(values)
+		slices.Sort(values)
+		return values
+	}
+	for _, c := range []struct {
+		name   string
+		filter BookmarkFilter
+		want   []string
+	}{
+		{"reading", BookmarkFilter{ReadStatus: readStatusReading}, ids[0:1]},
+		{"read", BookmarkFilter{ReadStatus: readStatusRead}, ids[1:3]},
+		{"unread", BookmarkFilter{ReadStatus: readStatusUnread}, ids[3:4]},
+		{"starred", BookmarkFilter{Starred: &yes}, ids[0:1]},
+		{"not starred", BookmarkFilter{Starred: &no}, ids[1:4]},
+		{"archived", BookmarkFilter{Archived: &yes}, ids[2:3]},
+		{"color", BookmarkFilter{ColorLabel: blue}, ids[1:3]},
+		{"read, not archived", BookmarkFilter{ReadStatus: readStatusRead, Archived: &no}, ids[1:2]},
+	} {
+		got := listIDs(t, s, ListQuery{BookmarkFilter: c.filter})
+		if !slices.Equal(sorted(got...), sorted(c.want...)) {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+
+	stats, err := s.GetStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Unread != 1 || stats.Reading != 1 || stats.Starred != 1 || stats.Archived != 1 {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
@@ -0,0 +1,490 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type StaticSiteResult struct {
+	Path   string `json:"path"`
+	Count  int    `json:"count"`
+	Failed int    `json:"failed"`
+}
+
+type siteEntry struct {
+	ID          string
+	Name        string
+	URL         string
+	Domain      string
+	DomainName  string
+	DomainHref  string
+	Tags        []siteLink
+	Notes       string
+	Locked      bool
+	Starred     bool
+	CreatedAt   int64
+	HasThumb    bool
+	collections []string
+}
+
+type siteLink struct {
+	Name  string
+	Href  string
+	Count int
+}
+
+type siteGroup struct {
+	Heading string
+	Href    string
+	Items   []*siteEntry
+}
+
+type sitePage struct {
+	Root     string
+	Title    string
+	Subtitle string
+	Links    []siteLink
+	Groups   []siteGroup
+	Entry    *siteEntry
+}
+
+type siteSearchItem struct {
+	Title     string   `json:"title"`
+	URL       string   `json:"url"`
+	Domain    string   `json:"domain"`
+	Tags      []string `json:"tags"`
+	Notes     string   `json:"notes"`
+	CreatedAt int64    `json:"createdAt"`
+	Page      string   `json:"page"`
+}
+
+type bookmarkCollection struct {
+	slug  string
+	name  string
+	match func(*Bookmark) bool
+}
+
+// bookmarkCollections 是按收藏状态划分的合集，静态站点和订阅源共用，顺序即索引页中的展示顺序。
+var bookmarkCollections = []bookmarkCollection{
+	{"starred", "星标", func(bm *Bookmark) bool { return bm.Starred }},
+	{"unread", "未读", func(bm *Bookmark) bool { return bm.ReadStatus == readStatusUnread }},
+	{"reading", "在读", func(bm *Bookmark) bool { return bm.ReadStatus == readStatusReading }},
+	{"read", "已读", func(bm *Bookmark) bool { return bm.ReadStatus == readStatusRead }},
+	{"archived", "已归档", func(bm *Bookmark) bool { return bm.Archived }},
+	{"color-red", "红色标签", func(bm *Bookmark) bool { return bm.ColorLabel == "red" }},
+	{"color-orange", "橙色标签", func(bm *Bookmark) bool { return bm.ColorLabel == "orange" }},
+	{"color-yellow", "黄色标签", func(bm *Bookmark) bool { return bm.ColorLabel == "yellow" }},
+	{"color-green", "绿色标签", func(bm *Bookmark) bool { return bm.ColorLabel == "green" }},
+	{"color-blue", "蓝色标签", func(bm *Bookmark) bool { return bm.ColorLabel == "blue" }},
+	{"color-purple", "紫色标签", func(bm *Bookmark) bool { return bm.ColorLabel == "purple" }},
+	{"color-gray", "灰色标签", func(bm *Bookmark) bool { return bm.ColorLabel == "gray" }},
+}
+
+func findCollection(slug string) (bookmarkCollection, bool) {
+	for _, collection := range bookmarkCollections {
+		if collection.slug == slug {
+			return collection, true
+		}
+	}
+	return bookmarkCollection{}, false
+}
+
+// ExportStaticSite 把整个资料库（不含回收站）导出为可直接双击 index.html 浏览的静态站点，
+// 包含按日期、域名、标签和合集划分的索引页、每条收藏的页面与缩略图以及离线搜索索引。
+// 收藏列表只查询字段，缩略图在写出每条收藏时逐个读取，不会一次性载入内存。
+func (s *Service) ExportStaticSite() (*StaticSiteResult, error) {
+	items, err := s.selectBookmarks("deleted_at = 0", nil, defaultOrderBy, -1, 0)
+	if err != nil {
+		return nil, err
+	}
+	domains, err := s.ListDomains()
+	if err != nil {
+		return nil, err
+	}
+	displayNames := map[string]string{}
+	for _, domain := range domains {
+		if domain.DisplayName != "" {
+			displayNames[domain.Domain] = domain.DisplayName
+		}
+	}
+
+	baseDir, err := downloadsDir()
+	if err != nil {
+		return nil, err
+	}
+	root := getUniqueFilePath(baseDir, "chrome-collect-site-"+time.Now().Format("20060102"), "")
+	for _, dir := range []string{"assets", "archive", "thumbs", "pages", "dates", "domains", "tags", "collections"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &StaticSiteResult{Path: filepath.Clean(root)}
+	domainSlugs := siteSlugger{}
+	tagSlugs := siteSlugger{}
+	var entries []*siteEntry
+	for _, item := range items {
+		data, err := s.readArtifact(item.FilePath)
+		if errors.Is(err, ErrLibraryLocked) {
+			return nil, err
+		}
+		if err != nil {
+			result.Failed++
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(root, "archive", item.ID+".html"), data, 0o644); err != nil {
+			return nil, err
+		}
+		entry := &siteEntry{
+			ID:         item.ID,
+			Name:       bookmarkDisplayName(item),
+			URL:        item.URL,
+			Domain:     item.Domain,
+			DomainName: item.Domain,
+			DomainHref: "domains/" + domainSlugs.slug(item.Domain) + ".html",
+			Notes:      item.Notes,
+			Locked:     item.NotesLocked,
+			Starred:    item.Starred,
+			CreatedAt:  item.CreatedAt,
+		}
+		for _, collection := range bookmarkCollections {
+			if collection.match(&item) {
+				entry.collections = append(entry.collections, collection.slug)
+			}
+		}
+		if name := displayNames[item.Domain]; name != "" {
+			entry.DomainName = name
+		}
+		for _, tag := range parseTags(item.Tags) {
+			entry.Tags = append(entry.Tags, siteLink{Name: tag, Href: "tags/" + tagSlugs.slug(tag) + ".html"})
+		}
+		if item.ThumbPath != "" {
+			if thumb, err := s.readArtifact(item.ThumbPath); err == nil {
+				if err := os.WriteFile(filepath.Join(root, "thumbs", item.ID+".png"), thumb, 0o644); err != nil {
+					return nil, err
+				}
+				entry.HasThumb = true
+			}
+		}
+		entries = append(entries, entry)
+	}
+	result.Count = len(entries)
+
+	write := func(relative, name string, page sitePage) error {
+		file, err := os.Create(filepath.Join(root, filepath.FromSlash(relative)))
+		if err != nil {
+			return err
+		}
+		if err := siteTemplates.ExecuteTemplate(file, name, page); err != nil {
+			file.Close()
+			return err
+		}
+		return file.Close()
+	}
+
+	for _, entry := range entries {
+		page := sitePage{Root: "../", Title: entry.Name, Entry: entry}
+		if err := write("pages/"+entry.ID+".html", "bookmark", page); err != nil {
+			return nil, err
+		}
+	}
+
+	// 日期：首页按月分组，每个月另有独立页面。
+	months := groupSiteEntries(entries, func(e *siteEntry) []string {
+		return []string{time.UnixMilli(e.CreatedAt).Format("2006-01")}
+	})
+	var monthGroups []siteGroup
+	var monthLinks []siteLink
+	for _, month := range months {
+		href := "dates/" + month.Heading + ".html"
+		monthGroups = append(monthGroups, siteGroup{Heading: month.Heading, Href: href, Items: month.Items})
+		monthLinks = append(monthLinks, siteLink{Name: month.Heading, Href: href, Count: len(month.Items)})
+		if err := write(href, "list", sitePage{Root: "../", Title: month.Heading, Groups: []siteGroup{{Items: month.Items}}}); err != nil {
+			return nil, err
+		}
+	}
+	subtitle := fmt.Sprintf("共 %d 条收藏 · 导出于 %s", len(entries), time.Now().Format("2006-01-02 15:04"))
+	if err := write("index.html", "list", sitePage{Title: "全部收藏", Subtitle: subtitle, Groups: monthGroups}); err != nil {
+		return nil, err
+	}
+	if err := write("dates/index.html", "list", sitePage{Root: "../", Title: "按日期", Links: monthLinks}); err != nil {
+		return nil, err
+	}
+
+	// 域名、标签按条目数量排序，与设置中的域名列表一致。
+	groupPages := func(dir, title string, groups []siteGroup, name func(string) string) error {
+		sort.SliceStable(groups, func(i, j int) bool { return len(groups[i].Items) > len(groups[j].Items) })
+		var links []siteLink
+		for _, group := range groups {
+			links = append(links, siteLink{Name: name(group.Heading), Href: group.Href, Count: len(group.Items)})
+			if err := write(group.Href, "list", sitePage{Root: "../", Title: name(group.Heading), Groups: []siteGroup{{Items: group.Items}}}); err != nil {
+				return err
+			}
+		}
+		return write(dir+"/index.html", "list", sitePage{Root: "../", Title: title, Links: links})
+	}
+	domainGroups := groupSiteEntries(entries, func(e *siteEntry) []string { return []string{e.Domain} })
+	for i := range domainGroups {
+		domainGroups[i].Href = "domains/" + domainSlugs.slug(domainGroups[i].Heading) + ".html"
+	}
+	domainName := func(domain string) string {
+		if name := displayNames[domain]; name != "" {
+			return name
+		}
+		return domain
+	}
+	if err := groupPages("domains", "按域名", domainGroups, domainName); err != nil {
+		return nil, err
+	}
+	tagGroups := groupSiteEntries(entries, func(e *siteEntry) []string {
+		tags := make([]string, 0, len(e.Tags))
+		for _, tag := range e.Tags {
+			tags = append(tags, tag.Name)
+		}
+		return tags
+	})
+	for i := range tagGroups {
+		tagGroups[i].Href = "tags/" + tagSlugs.slug(tagGroups[i].Heading) + ".html"
+	}
+	if err := groupPages("tags", "按标签", tagGroups, func(tag string) string { return "#" + tag }); err != nil {
+		return nil, err
+	}
+
+	var collectionLinks []siteLink
+	for _, collection := range bookmarkCollections {
+		var matched []*siteEntry
+		for _, entry := range entries {
+			if slices.Contains(entry.collections, collection.slug) {
+				matched = append(matched, entry)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+		href := "collections/" + collection.slug + ".html"
+		collectionLinks = append(collectionLinks, siteLink{Name: collection.name, Href: href, Count: len(matched)})
+		if err := write(href, "list", sitePage{Root: "../", Title: collection.name, Groups: []siteGroup{{Items: matched}}}); err != nil {
+			return nil, err
+		}
+	}
+	if err := write("collections/index.html", "list", sitePage{Root: "../", Title: "合集", Links: collectionLinks}); err != nil {
+		return nil, err
+	}
+
+	if err := writeSiteSearchIndex(root, entries); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(root, "assets", "style.css"), []byte(siteStyle), 0o644); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(root, "assets", "search.js"), []byte(siteSearchScript), 0o644); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// writeSiteSearchIndex 同时写出 JSON 和脚本两种形式：file:// 下无法 fetch JSON，页面通过 <script> 加载后者。
+func writeSiteSearchIndex(root string, entries []*siteEntry) error {
+	index := make([]siteSearchItem, 0, len(entries))
+	for _, entry := range entries {
+		item := siteSearchItem{
+			Title:     entry.Name,
+			URL:       entry.URL,
+			Domain:    entry.Domain,
+			Tags:      []string{},
+			Notes:     entry.Notes,
+			CreatedAt: entry.CreatedAt,
+			Page:      "pages/" + entry.ID + ".html",
+		}
+		for _, tag := range entry.Tags {
+			item.Tags = append(item.Tags, tag.Name)
+		}
+		index = append(index, item)
+	}
+	raw, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(root, "search-index.json"), raw, 0o644); err != nil {
+		return err
+	}
+	script := append([]byte("window.CHROME_COLLECT_SEARCH = "), raw...)
+	return os.WriteFile(filepath.Join(root, "search-index.js"), append(script, ";\n"...), 0o644)
+}
+
+// groupSiteEntries 按 keys 返回的键分组，保持条目原有顺序；一个条目可以属于多个分组。
+func groupSiteEntries(entries []*siteEntry, keys func(*siteEntry) []string) []siteGroup {
+	var groups []siteGroup
+	positions := map[string]int{}
+	for _, entry := range entries {
+		for _, key := range keys(entry) {
+			pos, ok := positions[key]
+			if !ok {
+				pos = len(groups)
+				positions[key] = pos
+				groups = append(groups, siteGroup{Heading: key})
+			}
+			groups[pos].Items = append(groups[pos].Items, entry)
+		}
+	}
+	return groups
+}
+
+func bookmarkDisplayName(bm Bookmark) string {
+	switch {
+	case bm.Alias != "":
+		return bm.Alias
+	case bm.Title != "":
+		return bm.Title
+	default:
+		return bm.URL
+	}
+}
+
+// siteSlugger 为域名和标签生成文件名，只保留字母、数字和点号，冲突时追加序号。
+type siteSlugger struct {
+	names map[string]string
+	taken map[string]bool
+}
+
+func (s *siteSlugger) slug(name string) string {
+	if slug, ok := s.names[name]; ok {
+		return slug
+	}
+	if s.names == nil {
+		s.names = map[string]string{}
+		s.taken = map[string]bool{"index": true}
+	}
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.':
+			b.WriteRune(r)
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "-"):
+			b.WriteByte('-')
+		}
+	}
+	base := sanitizeFilename(strings.Trim(b.String(), "-."), 60)
+	slug := base
+	for i := 2; s.taken[slug]; i++ {
+		slug = fmt.Sprintf("%s-%d", base, i)
+	}
+	s.names[name] = slug
+	s.taken[slug] = true
+	return slug
+}
+
+var siteTemplates = template.Must(template.New("site").Funcs(template.FuncMap{
+	"date": func(ms int64) string { return time.UnixMilli(ms).Format("2006-01-02 15:04") },
+}).Parse(`{{define "head"}}<!DOCTYPE html>
+<html lang="zh-CN"><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}} · Chrome Collect</title><link rel="stylesheet" href="{{.Root}}assets/style.css"></head>
+<body>
+<header><a class="brand" href="{{.Root}}index.html">Chrome Collect</a>
+<nav><a href="{{.Root}}dates/index.html">日期</a><a href="{{.Root}}domains/index.html">域名</a><a href="{{.Root}}tags/index.html">标签</a><a href="{{.Root}}collections/index.html">合集</a></nav></header>
+{{end}}
+
+{{define "list"}}{{template "head" .}}<main>
+<h1>{{.Title}}</h1>
+{{if .Subtitle}}<p class="meta">{{.Subtitle}}</p>{{end}}
+<input id="search" type="search" placeholder="搜索标题、网址、标签、备注" autocomplete="off">
+<ul id="search-results" class="cards" hidden></ul>
+<div id="content">
+{{if .Links}}<ul class="links">{{range .Links}}<li><a href="{{$.Root}}{{.Href}}">{{.Name}}</a><span class="meta">{{.Count}}</span></li>{{end}}</ul>{{end}}
+{{range .Groups}}{{if .Heading}}<h2><a href="{{$.Root}}{{.Href}}">{{.Heading}}</a></h2>{{end}}
+<ul class="cards">{{range .Items}}
+<li class="card">{{if .HasThumb}}<a href="{{$.Root}}pages/{{.ID}}.html"><img src="{{$.Root}}thumbs/{{.ID}}.png" alt="" loading="lazy"></a>{{end}}
+<div><a class="title" href="{{$.Root}}pages/{{.ID}}.html">{{.Name}}</a>
+<div class="meta"><a href="{{$.Root}}{{.DomainHref}}">{{.DomainName}}</a> · {{date .CreatedAt}}{{if .Starred}} · ★{{end}}</div>
+{{if .Tags}}<div class="tags">{{range .Tags}}<a href="{{$.Root}}{{.Href}}">#{{.Name}}</a>{{end}}</div>{{end}}</div></li>{{end}}
+</ul>{{end}}
+{{if not (or .Links .Groups)}}<p class="meta">没有内容</p>{{end}}
+</div></main>
+<script src="{{.Root}}search-index.js"></script><script src="{{.Root}}assets/search.js"></script>
+</body></html>
+{{end}}
+
+{{define "bookmark"}}{{template "head" .}}<main>
+{{with .Entry}}<h1>{{.Name}}</h1>
+<p class="meta"><a href="{{$.Root}}{{.DomainHref}}">{{.DomainName}}</a> · {{date .CreatedAt}} · <a href="{{.URL}}" rel="noopener noreferrer">原网址</a> · <a href="{{$.Root}}archive/{{.ID}}.html">单独打开</a></p>
+{{if .Tags}}<div class="tags">{{range .Tags}}<a href="{{$.Root}}{{.Href}}">#{{.Name}}</a>{{end}}</div>{{end}}
+{{if .Notes}}<section class="notes">{{.Notes}}</section>{{else if .Locked}}<section class="notes meta">备注已加密，解锁资料库后重新导出即可包含备注</section>{{end}}
+<iframe src="{{$.Root}}archive/{{.ID}}.html" sandbox="" title="{{.Name}}"></iframe>{{end}}
+</main></body></html>
+{{end}}`))
+
+const siteStyle = `*{box-sizing:border-box}
+body{margin:0;font:14px/1.6 system-ui,-apple-system,"Segoe UI",sans-serif;color:#1f2328;background:#f6f8fa}
+a{color:#0969da;text-decoration:none}a:hover{text-decoration:underline}
+header{display:flex;align-items:center;gap:24px;padding:12px 24px;background:#fff;border-bottom:1px solid #d0d7de}
+header .brand{font-weight:600;color:#1f2328}nav{display:flex;gap:16px}
+main{max-width:1080px;margin:0 auto;padding:24px}
+h1{font-size:22px;margin:0 0 4px}h2{font-size:16px;margin:24px 0 8px}
+.meta{color:#656d76;font-size:12px}
+#search{width:100%;margin:16px 0;padding:8px 12px;border:1px solid #d0d7de;border-radius:6px;font-size:14px}
+.links{list-style:none;padding:0;columns:3 220px}.links li{display:flex;justify-content:space-between;gap:8px;padding:4px 0;break-inside:avoid}
+.cards{list-style:none;padding:0;margin:0;display:grid;gap:12px;grid-template-columns:repeat(auto-fill,minmax(300px,1fr))}
+.card{display:flex;gap:12px;padding:12px;background:#fff;border:1px solid #d0d7de;border-radius:8px;min-width:0}
+.card img{width:96px;height:64px;object-fit:cover;border-radius:4px;background:#eaeef2}
+.card>div{min-width:0}.card .title{display:block;font-weight:500;overflow:hidden;text-overflow:ellipsis;white-space:nowrap}
+.tags{display:flex;flex-wrap:wrap;gap:6px;font-size:12px;margin-top:4px}
+.notes{white-space:pre-wrap;background:#fff;border:1px solid #d0d7de;border-radius:8px;padding:12px 16px;margin:16px 0}
+iframe{width:100%;height:80vh;margin-top:16px;border:1px solid #d0d7de;border-radius:8px;background:#fff}
+`
+
+const siteSearchScript = `(function () {
+  var input = document.getElementById('search');
+  var results = document.getElementById('search-results');
+  var content = document.getElementById('content');
+  if (!input || !results || !content) return;
+  var root = (document.querySelector('link[rel=stylesheet]').getAttribute('href') || '').replace(/assets\/style\.css$/, '');
+  var index = (window.CHROME_COLLECT_SEARCH || []).map(function (item) {
+    item.text = [item.title, item.url, item.domain, item.tags.join(' '), item.notes].join('\n').toLowerCase();
+    return item;
+  });
+
+  function render(item) {
+    var li = document.createElement('li');
+    li.className = 'card';
+    var body = document.createElement('div');
+    var link = document.createElement('a');
+    link.className = 'title';
+    link.href = root + item.page;
+    link.textContent = item.title;
+    var meta = document.createElement('div');
+    meta.className = 'meta';
+    meta.textContent = item.domain + ' · ' + new Date(item.createdAt).toLocaleString();
+    body.appendChild(link);
+    body.appendChild(meta);
+    li.appendChild(body);
+    return li;
+  }
+
+  input.addEventListener('input', function () {
+    var terms = input.value.toLowerCase().split(/\s+/).filter(Boolean);
+    results.textContent = '';
+    results.hidden = terms.length === 0;
+    content.hidden = terms.length > 0;
+    if (!terms.length) return;
+    var matches = index.filter(function (item) {
+      return terms.every(function (term) { return item.text.indexOf(term) !== -1; });
+    });
+    matches.slice(0, 200).forEach(function (item) { results.appendChild(render(item)); });
+    if (!matches.length) {
+      var empty = document.createElement('li');
+      empty.className = 'meta';
+      empty.textContent = '没有匹配的收藏';
+      results.appendChild(empty);
+    }
+  });
+})();
+`
@@ -0,0 +1,100 @@
+package app
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportStaticSite(t *testing.T) {
+	s := newTestService(t)
+	a := saveTestBookmark(t, s, "https://a.example/1", "First", "<p>first</p>")
+	b := saveTestBookmark(t, s, "https://b.example/1", "Second", "<p>second</p>")
+	missing := saveTestBookmark(t, s, "https://a.example/2", "Missing", "<p>missing</p>")
+	trashed := saveTestBookmark(t, s, "https://a.example/3", "Trashed", "<p>trashed</p>")
+	yes := true
+	for _, req := range []BulkRequest{
+		{IDs: []string{a.ID, b.ID}, Action: bulkActionTag, Tags: []string{"Go Lang", "go-lang"}},
+		{IDs: []string{b.ID}, Action: bulkActionSetState, State: &StateUpdate{Starred: &yes}},
+		{IDs: []string{trashed.ID}, Action: bulkActionTrash},
+	} {
+		if _, err := s.RunBulk(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.SetDomainName("a.example", "Site A"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(getAbsoluteFilePath(s.dataDir, missing.FilePath)); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := s.ExportStaticSite()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Count != 2 || result.Failed != 1 {
+		t.Fatalf("result = %+v", result)
+	}
+	read := func(relative string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(result.Path, filepath.FromSlash(relative)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	for _, relative := range []string{
+		"index.html", "dates/index.html", "domains/index.html", "tags/index.html", "collections/index.html",
+		"assets/style.css", "assets/search.js", "search-index.js", "thumbs/" + a.ID + ".png",
+		"domains/a.example.html", "domains/b.example.html", "collections/starred.html",
+	} {
+		read(relative)
+	}
+	if !strings.Contains(read("archive/"+a.ID+".html"), "<p>first</p>") {
+		t.Fatal("archived page content missing")
+	}
+	page := read("pages/" + a.ID + ".html")
+	if !strings.Contains(page, `sandbox=""`) || !strings.Contains(page, "Site A") || !strings.Contains(page, `href="../domains/a.example.html"`) {
+		t.Fatalf("bookmark page = %s", page)
+	}
+	// 两个标签的文件名冲突时追加序号。
+	tags := read("tags/index.html")
+	if !strings.Contains(tags, `href="../tags/go-lang.html"`) || !strings.Contains(tags, `href="../tags/go-lang-2.html"`) {
+		t.Fatalf("tag index = %s", tags)
+	}
+	if starred := read("collections/starred.html"); !strings.Contains(starred, "Second") || strings.Contains(starred, "First") {
+		t.Fatalf("starred collection = %s", starred)
+	}
+	if strings.Contains(read("index.html"), "Trashed") {
+		t.Fatal("trashed bookmark exported")
+	}
+
+	var index []siteSearchItem
+	if err := json.Unmarshal([]byte(read("search-index.json")), &index); err != nil {
+		t.Fatal(err)
+	}
+	if len(index) != 2 || index[0].Title != "Second" || len(index[0].Tags) != 2 || index[1].Page != "pages/"+a.ID+".html" {
+		t.Fatalf("search index = %+v", index)
+	}
+}
+
+func TestSiteSlugger(t *testing.T) {
+	var slugger siteSlugger
+	cases := []struct{ name, want string }{
+		{"Example.COM", "example.com"},
+		{"C++ / Go", "c-go"},
+		{"c go", "c-go-2"},
+		{"index", "index-2"},
+		{"中文 标签", "中文-标签"},
+		{"Example.COM", "example.com"},
+	}
+	for _, c := range cases {
+		if got := slugger.slug(c.name); got != c.want {
+			t.Errorf("slug(%q) = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package app
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultStatsDays    = 30
+	defaultStatsWeeks   = 26
+	defaultStatsLargest = 10
+)
+
+type DetailedStats struct {
+	Total       int              `json:"total"`
+	TotalSize   int64            `json:"totalSize"`
+	TrashCount  int              `json:"trashCount"`
+	TrashSize   int64            `json:"trashSize"`
+	Disk        DiskUsage        `json:"disk"`
+	Domains     []DomainStat     `json:"domains"`
+	Tags        []TagStat        `json:"tags"`
+	Collections []CollectionStat `json:"collections"`
+	Daily       []TimeBucket     `json:"daily"`
+	Weekly      []TimeBucket     `json:"weekly"`
+	Monthly     []TimeBucket     `json:"monthly"`
+	Largest     []LargestItem    `json:"largest"`
+}
+
+type DiskUsage struct {
+	HTMLBytes    int64 `json:"htmlBytes"`
+	ThumbBytes   int64 `json:"thumbBytes"`
+	MissingFiles int   `json:"missingFiles"`
+}
+
+type DomainStat struct {
+	Domain string `json:"domain"`
+	Count  int    `json:"count"`
+	Size   int64  `json:"size"`
+}
+
+type TagStat struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// CollectionStat 对应 bookmarkCollections 中的合集，一条收藏可以同时计入多个合集。
+type CollectionStat struct {
+	Slug  string `json:"slug"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+	Size  int64  `json:"size"`
+}
+
+type TimeBucket struct {
+	Period string `json:"period"`
+	Count  int    `json:"count"`
+	Size   int64  `json:"size"`
+}
+
+type LargestItem struct {
+	ID        string `json:"id"`
+	URL       string `json:"url"`
+	Title     string `json:"title"`
+	Alias     string `json:"alias"`
+	FileSize  int64  `json:"file_size"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+func (s *Service) GetDetailedStats(days, weeks, largest int) (*DetailedStats, error) {
+	if days <= 0 {
+		days = defaultStatsDays
+	}
+	if weeks <= 0 {
+		weeks = defaultStatsWeeks
+	}
+	if largest <= 0 {
+		largest = defaultStatsLargest
+	}
+
+	stats := &DetailedStats{}
+	if err := s.db.QueryRow("SELECT COUNT(*), COALESCE(SUM(file_size), 0) FROM bookmarks WHERE deleted_at = 0").Scan(&stats.Total, &stats.TotalSize); err != nil {
+		return nil, err
+	}
+	if err := s.db.QueryRow("SELECT COUNT(*), COALESCE(SUM(file_size), 0) FROM bookmarks WHERE deleted_at > 0").Scan(&stats.TrashCount, &stats.TrashSize); err != nil {
+		return nil, err
+	}
+	if err := s.collectBreakdowns(stats); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if stats.Daily, err = s.timeBuckets("%Y-%m-%d", "-"+strconv.Itoa(days-1)+" days"); err != nil {
+		return nil, err
+	}
+	if stats.Weekly, err = s.timeBuckets("%Y-W%W", "-"+strconv.Itoa(weeks*7-1)+" days"); err != nil {
+		return nil, err
+	}
+	if stats.Monthly, err = s.timeBuckets("%Y-%m", ""); err != nil {
+		return nil, err
+	}
+	if stats.Largest, err = s.largestBookmarks(largest); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+func (s *Service) collectBreakdowns(stats *DetailedStats) error {
+	rows, err := s.db.Query(`SELECT domain, file_path, thumb_path, file_size, tags, read_status, starred, archived, color_label
+		FROM bookmarks WHERE deleted_at = 0`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	domains := map[string]*DomainStat{}
+	tags := map[string]int{}
+	collections := make([]CollectionStat, len(bookmarkCollections))
+	for i, collection := range bookmarkCollections {
+		collections[i] = CollectionStat{Slug: collection.slug, Name: collection.name}
+	}
+	for rows.Next() {
+		var domain, filePath, thumbPath, rawTags string
+		var size int64
+		var state Bookmark
+		if err := rows.Scan(&domain, &filePath, &thumbPath, &size, &rawTags, &state.ReadStatus, &state.Starred, &state.Archived, &state.ColorLabel); err != nil {
+			return err
+		}
+
+		entry, ok := domains[domain]
+		if !ok {
+			entry = &DomainStat{Domain: domain}
+			domains[domain] = entry
+		}
+		entry.Count++
+		entry.Size += size
+
+		for _, tag := range parseTags(rawTags) {
+			tags[tag]++
+		}
+		for i, collection := range bookmarkCollections {
+			if collection.match(&state) {
+				collections[i].Count++
+				collections[i].Size += size
+			}
+		}
+
+		for _, relative := range []string{filePath, thumbPath} {
+			if relative == "" {
+				continue
+			}
+			info, err := os.Stat(getAbsoluteFilePath(s.dataDir, relative))
+			if err != nil {
+				stats.Disk.MissingFiles++
+				continue
+			}
+			if relative == filePath {
+				stats.Disk.HTMLBytes += info.Size()
+			} else {
+				stats.Disk.ThumbBytes += info.Size()
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	stats.Domains = make([]DomainStat, 0, len(domains))
+	for _, entry := range domains {
+		stats.Domains = append(stats.Domains, *entry)
+	}
+	sort.Slice(stats.Domains, func(i, j int) bool {
+		if stats.Domains[i].Count != stats.Domains[j].Count {
+			return stats.Domains[i].Count > stats.Domains[j].Count
+		}
+		return stats.Domains[i].Domain < stats.Domains[j].Domain
+	})
+
+	stats.Tags = make([]TagStat, 0, len(tags))
+	for tag, count := range tags {
+		stats.Tags = append(stats.Tags, TagStat{Tag: tag, Count: count})
+	}
+	sort.Slice(stats.Tags, func(i, j int) bool {
+		if stats.Tags[i].Count != stats.Tags[j].Count {
+			return stats.Tags[i].Count > stats.Tags[j].Count
+		}
+		return stats.Tags[i].Tag < stats.Tags[j].Tag
+	})
+
+	// 合集保持 bookmarkCollections 的固定顺序，只列出非空的合集。
+	stats.Collections = []CollectionStat{}
+	for _, collection := range collections {
+		if collection.Count > 0 {
+			stats.Collections = append(stats.Collections, collection)
+		}
+	}
+	return nil
+}
+
+func (s *Service) timeBuckets(format, window string) ([]TimeBucket, error) {
+	period := "strftime('" + format + "', created_at / 1000, 'unixepoch', 'localtime')"
+	where := "deleted_at = 0"
+	args := []any{}
+	if window != "" {
+		where += " AND created_at >= CAST(strftime('%s', 'now', 'localtime', 'start of day', ?, 'utc') AS INTEGER) * 1000"
+		args = append(args, window)
+	}
+	rows, err := s.db.Query("SELECT "+period+" AS period, COUNT(*), COALESCE(SUM(file_size), 0) FROM bookmarks WHERE "+where+" GROUP BY period ORDER BY period", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := []TimeBucket{}
+	for rows.Next() {
+		var bucket TimeBucket
+		if err := rows.Scan(&bucket.Period, &bucket.Count, &bucket.Size); err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets, rows.Err()
+}
+
+func (s *Service) largestBookmarks(limit int) ([]LargestItem, error) {
+	rows, err := s.db.Query("SELECT id, url, title, alias, file_size, created_at FROM bookmarks WHERE deleted_at = 0 ORDER BY file_size DESC, created_at DESC LIMIT ?", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []LargestItem{}
+	for rows.Next() {
+		var item LargestItem
+		if err := rows.Scan(&item.ID, &item.URL, &item.Title, &item.Alias, &item.FileSize, &item.CreatedAt); err != nil {
+			return nil, err
+		}
+		item.Title, item.Alias = s.revealField(item.Title), s.revealField(item.Alias)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func parseTags(raw string) []string {
+	var tags []string
+	if raw == "" || json.Unmarshal([]byte(raw), &tags) != nil {
+		return nil
+	}
+	result := tags[:0]
+	for _, tag := range tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
@@ -0,0 +1,86 @@
+package app
+
+import (
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestGetDetailedStats(t *testing.T) {
+	s := newTestService(t)
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 12, 0, 0, 0, time.Local)
+	yesterday := today.AddDate(0, 0, -1)
+	old := time.Date(2023, 3, 15, 12, 0, 0, 0, time.Local)
+	ids := listingFixture(t, s, []listingRow{
+		{"https://a.example/1", "small", today.UnixMilli(), 100},
+		{"https://a.example/2", "large", yesterday.UnixMilli(), 500},
+		{"https://b.example/1", "old", old.UnixMilli(), 300},
+		{"https://c.example/1", "trashed", today.UnixMilli(), 50},
+	})
+	small, large, oldID, trashed := ids[0], ids[1], ids[2], ids[3]
+	yes := true
+	read := readStatusRead
+	for _, req := range []BulkRequest{
+		{IDs: []string{small, large}, Action: bulkActionTag, Tags: []string{"go"}},
+		{IDs: []string{oldID}, Action: bulkActionTag, Tags: []string{"go", "db"}},
+		{IDs: []string{large}, Action: bulkActionSetState, State: &StateUpdate{Starred: &yes, ReadStatus: &read}},
+		{IDs: []string{trashed}, Action: bulkActionTrash},
+	} {
+		if _, err := s.RunBulk(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bm, err := s.GetBookmark(oldID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(getAbsoluteFilePath(s.dataDir, bm.ThumbPath)); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := s.GetDetailedStats(7, 4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Total != 3 || stats.TotalSize != 900 || stats.TrashCount != 1 || stats.TrashSize != 50 {
+		t.Fatalf("totals = %+v", stats)
+	}
+	if stats.Disk.MissingFiles != 1 || stats.Disk.HTMLBytes == 0 || stats.Disk.ThumbBytes == 0 {
+		t.Fatalf("disk = %+v", stats.Disk)
+	}
+	wantDomains := []DomainStat{{"a.example", 2, 600}, {"b.example", 1, 300}}
+	if !slices.Equal(stats.Domains, wantDomains) {
+		t.Fatalf("domains = %+v", stats.Domains)
+	}
+	if wantTags := []TagStat{{"go", 3}, {"db", 1}}; !slices.Equal(stats.Tags, wantTags) {
+		t.Fatalf("tags = %+v", stats.Tags)
+	}
+	wantCollections := []CollectionStat{
+		{"starred", "星标", 1, 500},
+		{"unread", "未读", 2, 400},
+		{"read", "已读", 1, 500},
+	}
+	if !slices.Equal(stats.Collections, wantCollections) {
+		t.Fatalf("collections = %+v", stats.Collections)
+	}
+
+	wantDaily := []TimeBucket{{yesterday.Format("2006-01-02"), 1, 500}, {today.Format("2006-01-02"), 1, 100}}
+	if !slices.Equal(stats.Daily, wantDaily) {
+		t.Fatalf("daily = %+v", stats.Daily)
+	}
+	weekly := 0
+	for _, bucket := range stats.Weekly {
+		weekly += bucket.Count
+	}
+	if weekly != 2 {
+		t.Fatalf("weekly = %+v, want the 2023 bookmark excluded", stats.Weekly)
+	}
+	if len(stats.Monthly) == 0 || stats.Monthly[0] != (TimeBucket{"2023-03", 1, 300}) {
+		t.Fatalf("monthly = %+v", stats.Monthly)
+	}
+	if len(stats.Largest) != 2 || stats.Largest[0].ID != large || stats.Largest[1].ID != oldID {
+		t.Fatalf("largest = %+v", stats.Largest)
+	}
+}
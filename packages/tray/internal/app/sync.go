@@ -0,0 +1,853 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"chrome-collect-tray/internal/protocol"
+)
+
+const (
+	metaSyncFolder    = "sync_folder"
+	metaSyncDevice    = "sync_device_id"
+	metaSyncLastRun   = "sync_last_run"
+	metaSyncLastError = "sync_last_error"
+	syncActionApply   = "sync.apply"
+	syncKindBookmark  = "bookmark"
+	syncKindTombstone = "tombstone"
+	syncTombstoneHash = "tombstone"
+	syncChangesFile   = "changes.jsonl"
+	syncDeviceFile    = "device.json"
+	syncWinnerLocal   = "local"
+	syncWinnerRemote  = "remote"
+	syncTargetFolder  = "folder"
+	syncTargetWebDAV  = "webdav"
+)
+
+// syncSnapshot 是同步的最小单位：一条收藏的可同步字段以及页面文件、截图的内容哈希。
+type syncSnapshot struct {
+	URL        string `json:"url"`
+	Title      string `json:"title"`
+	Alias      string `json:"alias"`
+	Notes      string `json:"notes"`
+	Tags       string `json:"tags"`
+	CreatedAt  int64  `json:"createdAt"`
+	DeletedAt  int64  `json:"deletedAt"`
+	ReadStatus string `json:"readStatus"`
+	ReadAt     int64  `json:"readAt"`
+	Starred    bool   `json:"starred"`
+	StarredAt  int64  `json:"starredAt"`
+	ColorLabel string `json:"colorLabel"`
+	Archived   bool   `json:"archived"`
+	ArchivedAt int64  `json:"archivedAt"`
+	FileHash   string `json:"fileHash"`
+	ThumbHash  string `json:"thumbHash,omitempty"`
+}
+
+// syncRecord 是设备变更日志中的一行；每台设备只追加写自己的日志，避免同步工具产生冲突副本。
+type syncRecord struct {
+	Kind     string        `json:"kind"`
+	ID       string        `json:"id"`
+	Device   string        `json:"device"`
+	At       int64         `json:"at"`
+	Bookmark *syncSnapshot `json:"bookmark,omitempty"`
+}
+
+type syncState struct {
+	snapshot *syncSnapshot
+	hash     string
+	at       int64
+	device   string
+}
+
+type SyncResult struct {
+	Exported  int `json:"exported"`
+	Imported  int `json:"imported"`
+	Deleted   int `json:"deleted"`
+	Conflicts int `json:"conflicts"`
+	Pending   int `json:"pending"`
+}
+
+type SyncDevice struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	UpdatedAt int64  `json:"updatedAt"`
+}
+
+type SyncStatus struct {
+	Folder    string       `json:"folder"`
+	Target    string       `json:"target"`
+	DeviceID  string       `json:"deviceId"`
+	LastRunAt int64        `json:"lastRunAt"`
+	LastError string       `json:"lastError,omitempty"`
+	Devices   []SyncDevice `json:"devices"`
+	Conflicts int          `json:"conflicts"`
+}
+
+type SyncConflict struct {
+	ID           int64  `json:"id"`
+	BookmarkID   string `json:"bookmarkId"`
+	Title        string `json:"title"`
+	Field        string `json:"field"`
+	LocalValue   string `json:"localValue"`
+	RemoteValue  string `json:"remoteValue"`
+	RemoteDevice string `json:"remoteDevice"`
+	Winner       string `json:"winner"`
+	CreatedAt    int64  `json:"createdAt"`
+}
+
+// SetSyncFolder 设置共享文件夹；为空表示关闭同步。同步的是变更日志和页面文件，不会复制 collect.db。
+func (s *Service) SetSyncFolder(folder string) (*SyncStatus, error) {
+	if folder != "" {
+		if !filepath.IsAbs(folder) {
+			return nil, errors.New("同步文件夹需为绝对路径")
+		}
+		info, err := os.Stat(folder)
+		if err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("同步文件夹不存在: %s", folder)
+		}
+		if err := s.ensureSyncable(); err != nil {
+			return nil, err
+		}
+		if s.webdavConfig().Sync {
+			return nil, errors.New("请先关闭 WebDAV 同步")
+		}
+		folder = filepath.Clean(folder)
+	}
+	previous, _ := s.getMeta(metaSyncFolder)
+	if err := s.setSetting(protocol.MethodSettingsSync, metaSyncFolder, folder); err != nil {
+		return nil, err
+	}
+	if previous != folder {
+		if err := s.resetSyncProgress(); err != nil {
+			return nil, err
+		}
+	}
+	return s.SyncStatus()
+}
+
+func (s *Service) SyncStatus() (*SyncStatus, error) {
+	folder, _ := s.getMeta(metaSyncFolder)
+	device, err := s.syncDeviceID()
+	if err != nil {
+		return nil, err
+	}
+	lastRun, _ := s.getMeta(metaSyncLastRun)
+	lastError, _ := s.getMeta(metaSyncLastError)
+	status := &SyncStatus{Folder: folder, DeviceID: device, LastError: lastError, Devices: []SyncDevice{}}
+	status.LastRunAt, _ = strconv.ParseInt(lastRun, 10, 64)
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM sync_conflicts WHERE resolved_at = 0").Scan(&status.Conflicts); err != nil {
+		return nil, err
+	}
+	if store, target, err := s.syncTarget(); err == nil {
+		status.Target = target
+		names, _ := store.list("devices")
+		for _, name := range names {
+			info := SyncDevice{ID: name}
+			if raw, err := store.read("devices/" + name + "/" + syncDeviceFile); err == nil {
+				_ = json.Unmarshal(raw, &info)
+			}
+			status.Devices = append(status.Devices, info)
+		}
+	}
+	return status, nil
+}
+
+// syncTarget 返回当前的同步目标：共享文件夹优先，其次是开启了同步的 WebDAV。
+func (s *Service) syncTarget() (syncStore, string, error) {
+	if folder, _ := s.getMeta(metaSyncFolder); folder != "" {
+		return folderStore{root: folder}, syncTargetFolder, nil
+	}
+	if cfg := s.webdavConfig(); cfg.Sync && cfg.URL != "" {
+		client, err := s.newWebDAVClient(cfg)
+		if err != nil {
+			return nil, "", err
+		}
+		return &webdavStore{client: client}, syncTargetWebDAV, nil
+	}
+	return nil, "", errors.New("尚未设置同步目标")
+}
+
+// resetSyncProgress 在更换同步目标后清空同步进度，新目标会收到一份完整的导出。
+func (s *Service) resetSyncProgress() error {
+	for _, stmt := range []string{"DELETE FROM sync_state", "DELETE FROM sync_cursors"} {
+		if _, err := s.db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunSync 在托盘进程中定期同步并按需推送 WebDAV、S3 备份；未设置同步目标时只做空检查。
+func (s *Service) RunSync(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, _, err := s.syncTarget(); err == nil {
+			_, _ = s.SyncNow()
+		}
+		if s.webdavBackupDue() {
+			_, _ = s.BackupToWebDAV()
+		}
+		if s.s3BackupDue() {
+			_, _ = s.BackupToS3()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SyncNow 先导入其他设备的变更再导出本机变更，结果和错误记录在 app_meta 中供设置页展示。
+func (s *Service) SyncNow() (*SyncResult, error) {
+	if !s.syncMu.TryLock() {
+		return nil, errors.New("同步正在进行中")
+	}
+	defer s.syncMu.Unlock()
+
+	result, err := s.syncOnce()
+	message := ""
+	if err != nil {
+		message = err.Error()
+	}
+	_ = s.setMeta(metaSyncLastError, message)
+	_ = s.setMeta(metaSyncLastRun, strconv.FormatInt(time.Now().UnixMilli(), 10))
+	return result, err
+}
+
+func (s *Service) syncOnce() (*SyncResult, error) {
+	store, _, err := s.syncTarget()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ensureSyncable(); err != nil {
+		return nil, err
+	}
+	device, err := s.syncDeviceID()
+	if err != nil {
+		return nil, err
+	}
+	hostname, _ := os.Hostname()
+	info, _ := json.Marshal(SyncDevice{ID: device, Name: hostname, UpdatedAt: time.Now().UnixMilli()})
+	if err := store.write("devices/"+device+"/"+syncDeviceFile, info); err != nil {
+		return nil, err
+	}
+
+	result := &SyncResult{}
+	names, err := store.list("devices")
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if name == device {
+			continue
+		}
+		if err := s.importDeviceChanges(store, device, name, result); err != nil {
+			return result, err
+		}
+	}
+	if err := s.exportChanges(store, device, result); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// ensureSyncable 拒绝同步加密资料库：共享文件夹中的页面和备注是明文，会绕过本地加密。
+func (s *Service) ensureSyncable() error {
+	cfg, err := s.encryptionConfig()
+	if err != nil {
+		return err
+	}
+	if cfg != nil {
+		return errors.New("已加密的资料库暂不支持文件夹同步")
+	}
+	return nil
+}
+
+func (s *Service) syncDeviceID() (string, error) {
+	device, err := s.getMeta(metaSyncDevice)
+	if err != nil || device != "" {
+		return device, err
+	}
+	device = hex.EncodeToString(randomBytes(8))
+	return device, s.setMeta(metaSyncDevice, device)
+}
+
+// exportChanges 把自上次同步以来本机发生变化的收藏写入本设备的日志，已永久删除的收藏写入墓碑。
+func (s *Service) exportChanges(store syncStore, device string, result *SyncResult) error {
+	rows, err := s.db.Query("SELECT id FROM bookmarks")
+	if err != nil {
+		return err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var records []syncRecord
+	var states []syncState
+	for _, id := range ids {
+		snapshot, err := s.syncSnapshot(id)
+		if err != nil {
+			return err
+		}
+		hash := snapshot.hash()
+		state, err := s.syncState(id)
+		if err != nil {
+			return err
+		}
+		if state != nil && state.hash == hash {
+			continue
+		}
+		at := s.localModifiedAt(id, state)
+		if err := s.exportBlobs(store, id, snapshot); err != nil {
+			return err
+		}
+		records = append(records, syncRecord{Kind: syncKindBookmark, ID: id, Device: device, At: at, Bookmark: snapshot})
+		states = append(states, syncState{snapshot: snapshot, hash: hash, at: at, device: device})
+	}
+
+	rows, err = s.db.Query("SELECT bookmark_id, updated_at FROM sync_state WHERE hash != ? AND bookmark_id NOT IN (SELECT id FROM bookmarks)", syncTombstoneHash)
+	if err != nil {
+		return err
+	}
+	type removed struct {
+		id string
+		at int64
+	}
+	var removedItems []removed
+	for rows.Next() {
+		var item removed
+		if err := rows.Scan(&item.id, &item.at); err != nil {
+			rows.Close()
+			return err
+		}
+		removedItems = append(removedItems, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, item := range removedItems {
+		at := s.localModifiedAt(item.id, &syncState{at: item.at})
+		records = append(records, syncRecord{Kind: syncKindTombstone, ID: item.id, Device: device, At: at})
+		states = append(states, syncState{hash: syncTombstoneHash, at: at, device: device})
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		buf.Write(append(line, '\n'))
+	}
+	if err := store.appendLog("devices/"+device+"/"+syncChangesFile, buf.Bytes()); err != nil {
+		return err
+	}
+	for i, record := range records {
+		if err := s.saveSyncState(record.ID, states[i]); err != nil {
+			return err
+		}
+	}
+	result.Exported += len(records)
+	return nil
+}
+
+// exportBlobs 以内容哈希为文件名写入页面和截图，已存在的直接跳过，中断后重新同步时只补传缺少的文件。
+func (s *Service) exportBlobs(store syncStore, id string, snapshot *syncSnapshot) error {
+	var filePath, thumbPath string
+	if err := s.db.QueryRow("SELECT file_path, thumb_path FROM bookmarks WHERE id = ?", id).Scan(&filePath, &thumbPath); err != nil {
+		return err
+	}
+	if filePath != "" && snapshot.FileHash != "" {
+		exists, err := store.exists(syncBlobName(snapshot.FileHash))
+		if err != nil {
+			return err
+		}
+		if !exists {
+			data, err := s.readArtifact(filePath)
+			if err != nil {
+				return err
+			}
+			if err := store.write(syncBlobName(snapshot.FileHash), data); err != nil {
+				return err
+			}
+		}
+	}
+	// 截图与页面文件分开判断：页面已在远端（例如重复收藏或只改了字段）时仍要带上截图哈希。
+	if thumbPath != "" {
+		data, err := s.readArtifact(thumbPath)
+		if err != nil {
+			return nil
+		}
+		snapshot.ThumbHash = sha256Hex(data)
+		return writeSyncBlob(store, snapshot.ThumbHash, data)
+	}
+	return nil
+}
+
+func writeSyncBlob(store syncStore, hash string, data []byte) error {
+	if exists, err := store.exists(syncBlobName(hash)); err != nil || exists {
+		return err
+	}
+	return store.write(syncBlobName(hash), data)
+}
+
+// readSyncBlob 在文件尚未同步到本机或内容不完整时返回 nil，调用方稍后重试。
+func readSyncBlob(store syncStore, hash string) []byte {
+	data, err := store.read(syncBlobName(hash))
+	if err != nil || sha256Hex(data) != hash {
+		return nil
+	}
+	return data
+}
+
+func syncBlobName(hash string) string {
+	return "blobs/" + hash
+}
+
+// importDeviceChanges 从上次读到的位置继续读取其他设备的日志；缺少页面文件时停在该行，下次再试。
+func (s *Service) importDeviceChanges(store syncStore, device, remote string, result *SyncResult) error {
+	var offset int64
+	if err := s.db.QueryRow("SELECT position FROM sync_cursors WHERE device = ?", remote).Scan(&offset); err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	data, err := store.readLog("devices/"+remote+"/"+syncChangesFile, offset)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for {
+		end := bytes.IndexByte(data, '\n')
+		if end < 0 {
+			// 不完整的最后一行可能还在传输中，留到下次读取。
+			break
+		}
+		line := data[:end+1]
+		data = data[end+1:]
+		var record syncRecord
+		if json.Unmarshal(line, &record) == nil && record.ID != "" && record.Device == remote {
+			applied, err := s.applySyncRecord(store, device, record, result)
+			if err != nil {
+				return err
+			}
+			if !applied {
+				result.Pending++
+				break
+			}
+		}
+		offset += int64(len(line))
+		if _, err := s.db.Exec(`INSERT INTO sync_cursors (device, position) VALUES (?, ?)
+			ON CONFLICT(device) DO UPDATE SET position = excluded.position`, remote, offset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applySyncRecord 按“最后写入者胜出”合并一条远端记录，返回 false 表示需要的页面文件尚未到达。
+func (s *Service) applySyncRecord(store syncStore, device string, record syncRecord, result *SyncResult) (bool, error) {
+	state, err := s.syncState(record.ID)
+	if err != nil {
+		return false, err
+	}
+	current, err := s.syncSnapshot(record.ID)
+	if err != nil && err != sql.ErrNoRows {
+		return false, err
+	}
+	exists := err == nil
+
+	localAt, localDevice := int64(0), ""
+	dirty := false
+	switch {
+	case state == nil && exists:
+		localAt, localDevice, dirty = s.localModifiedAt(record.ID, nil), device, true
+	case state != nil && exists && current.hash() != state.hash:
+		localAt, localDevice, dirty = s.localModifiedAt(record.ID, state), device, true
+	case state != nil && !exists && state.hash != syncTombstoneHash:
+		localAt, localDevice = s.localModifiedAt(record.ID, state), device
+	case state != nil:
+		localAt, localDevice = state.at, state.device
+	}
+	remoteWins := syncNewer(record.At, record.Device, localAt, localDevice)
+
+	// 备注在两端都改过且内容不同时，保留落败的一方供用户手动处理。
+	if record.Kind == syncKindBookmark && record.Bookmark != nil && dirty && current.Notes != record.Bookmark.Notes &&
+		(state == nil || state.snapshot == nil ||
+			(current.Notes != state.snapshot.Notes && record.Bookmark.Notes != state.snapshot.Notes)) {
+		winner := syncWinnerLocal
+		if remoteWins {
+			winner = syncWinnerRemote
+		}
+		if _, err := s.db.Exec(`INSERT INTO sync_conflicts (bookmark_id, field, local_value, remote_value, remote_device, winner, created_at)
+			VALUES (?, 'notes', ?, ?, ?, ?, ?)`, record.ID, current.Notes, record.Bookmark.Notes, record.Device, winner, time.Now().UnixMilli()); err != nil {
+			return false, err
+		}
+		result.Conflicts++
+	}
+	if !remoteWins {
+		return true, nil
+	}
+
+	if record.Kind == syncKindTombstone {
+		if exists {
+			if err := s.permanentDelete(syncActionApply, record.ID); err != nil {
+				return false, err
+			}
+			result.Deleted++
+		}
+		return true, s.saveSyncState(record.ID, syncState{hash: syncTombstoneHash, at: record.At, device: record.Device})
+	}
+	if record.Bookmark == nil {
+		return true, nil
+	}
+
+	applied, err := s.applySyncSnapshot(store, record.ID, record.Bookmark, current)
+	if err != nil || !applied {
+		return applied, err
+	}
+	snapshot, err := s.syncSnapshot(record.ID)
+	if err != nil {
+		return false, err
+	}
+	result.Imported++
+	return true, s.saveSyncState(record.ID, syncState{snapshot: snapshot, hash: snapshot.hash(), at: record.At, device: record.Device})
+}
+
+// syncNewer 判断版本 a 是否胜过版本 b：先比修改时间，时间相同时按设备 ID 决定，保证各设备合并出相同结果。
+// 时间取自各设备的本地时钟，设备间的时钟偏差会直接影响胜负，时钟偏快的设备更容易胜出；
+// 两端都改过的备注会另存到 sync_conflicts，不会因此丢失。
+func syncNewer(atA int64, deviceA string, atB int64, deviceB string) bool {
+	if atA != atB {
+		return atA > atB
+	}
+	return deviceA > deviceB
+}
+
+// applySyncSnapshot 写入远端的页面文件（内容变化时）和字段；current 为 nil 表示本机没有这条收藏。
+func (s *Service) applySyncSnapshot(store syncStore, id string, remote, current *syncSnapshot) (bool, error) {
+	var oldFile, oldThumb string
+	if current != nil {
+		if err := s.db.QueryRow("SELECT file_path, thumb_path FROM bookmarks WHERE id = ?", id).Scan(&oldFile, &oldThumb); err != nil {
+			return false, err
+		}
+	}
+	newFile, newThumb := oldFile, oldThumb
+	var pageData, thumbData []byte
+	if remote.FileHash != "" && (current == nil || current.FileHash != remote.FileHash) {
+		if pageData = readSyncBlob(store, remote.FileHash); pageData == nil {
+			return false, nil
+		}
+	}
+	if remote.ThumbHash != "" && oldThumb == "" {
+		thumbData = readSyncBlob(store, remote.ThumbHash)
+	}
+
+	domainDir, safeTitle := s.artifactLocation(id, remote.URL, remote.Title)
+	if pageData != nil || thumbData != nil {
+		if err := os.MkdirAll(domainDir, 0o755); err != nil {
+			return false, err
+		}
+	}
+	if pageData != nil {
+		path := getUniqueFilePath(domainDir, safeTitle, ".html")
+		if err := s.writeArtifact(path, pageData); err != nil {
+			return false, err
+		}
+		newFile = toRelativePath(s.dataDir, path)
+	}
+	if thumbData != nil {
+		path := getUniqueFilePath(domainDir, safeTitle, ".png")
+		if err := s.writeArtifact(path, thumbData); err == nil {
+			newThumb = toRelativePath(s.dataDir, path)
+		}
+	}
+
+	err := s.journaled(syncActionApply, "bookmark", id, func(tx *sql.Tx) error {
+		if current == nil {
+			if _, err := tx.Exec(`INSERT INTO bookmarks (id, url, normalized_url, domain, title, created_at, deleted_at)
+				VALUES (?, ?, ?, ?, ?, ?, 0)`, id, remote.URL, s.normalizeURL(remote.URL), getDomain(remote.URL), remote.Title, remote.CreatedAt); err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec(`UPDATE bookmarks SET url = ?, normalized_url = ?, domain = ?, title = ?, alias = ?, notes = ?, tags = ?,
+			deleted_at = ?, read_status = ?, read_status_at = ?, starred = ?, starred_at = ?, color_label = ?, archived = ?, archived_at = ?,
+			file_path = ?, thumb_path = ? WHERE id = ?`,
+			remote.URL, s.normalizeURL(remote.URL), getDomain(remote.URL), remote.Title, remote.Alias, remote.Notes, remote.Tags,
+			remote.DeletedAt, remote.ReadStatus, remote.ReadAt, remote.Starred, remote.StarredAt, remote.ColorLabel, remote.Archived, remote.ArchivedAt,
+			newFile, newThumb, id)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if pageData != nil || thumbData != nil {
+		if thumbData == nil && newThumb != "" {
+			thumbData, _ = s.readArtifact(newThumb)
+		}
+		if pageData == nil {
+			pageData, _ = s.readArtifact(newFile)
+		}
+		fp := computeFingerprint(pageData, thumbData)
+		if _, err := s.db.Exec("UPDATE bookmarks SET file_size = ?, content_hash = ?, text_simhash = ?, thumb_hash = ? WHERE id = ?",
+			s.artifactSize(newFile, newThumb), fp.ContentHash, fp.TextSimhash, fp.ThumbHash, id); err != nil {
+			return false, err
+		}
+		if oldFile != "" && oldFile != newFile {
+			s.removeBookmarkFiles(oldFile, "")
+		}
+	}
+	return true, nil
+}
+
+func (s *Service) syncSnapshot(id string) (*syncSnapshot, error) {
+	var snapshot syncSnapshot
+	var filePath string
+	err := s.db.QueryRow(`SELECT url, title, alias, notes, tags, created_at, deleted_at, read_status, read_status_at,
+		starred, starred_at, color_label, archived, archived_at, COALESCE(content_hash, ''), file_path FROM bookmarks WHERE id = ?`, id).Scan(
+		&snapshot.URL, &snapshot.Title, &snapshot.Alias, &snapshot.Notes, &snapshot.Tags, &snapshot.CreatedAt, &snapshot.DeletedAt,
+		&snapshot.ReadStatus, &snapshot.ReadAt, &snapshot.Starred, &snapshot.StarredAt, &snapshot.ColorLabel, &snapshot.Archived,
+		&snapshot.ArchivedAt, &snapshot.FileHash, &filePath)
+	if err != nil {
+		return nil, err
+	}
+	// 早期收藏可能没有内容哈希，按文件内容补算。
+	if snapshot.FileHash == "" && filePath != "" {
+		if data, err := s.readArtifact(filePath); err == nil {
+			snapshot.FileHash = sha256Hex(data)
+		}
+	}
+	return &snapshot, nil
+}
+
+// hash 不包含截图哈希：截图保存后不会再变，导出时才计算，避免每次同步都读取全部截图。
+func (snapshot syncSnapshot) hash() string {
+	snapshot.ThumbHash = ""
+	raw, _ := json.Marshal(snapshot)
+	return sha256Hex(raw)
+}
+
+func (s *Service) syncState(id string) (*syncState, error) {
+	var state syncState
+	var raw string
+	err := s.db.QueryRow("SELECT snapshot, hash, updated_at, device FROM sync_state WHERE bookmark_id = ?", id).Scan(&raw, &state.hash, &state.at, &state.device)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if raw != "" {
+		state.snapshot = &syncSnapshot{}
+		if err := json.Unmarshal([]byte(raw), state.snapshot); err != nil {
+			state.snapshot = nil
+		}
+	}
+	return &state, nil
+}
+
+func (s *Service) saveSyncState(id string, state syncState) error {
+	raw := ""
+	if state.snapshot != nil {
+		data, err := json.Marshal(state.snapshot)
+		if err != nil {
+			return err
+		}
+		raw = string(data)
+	}
+	_, err := s.db.Exec(`INSERT INTO sync_state (bookmark_id, snapshot, hash, updated_at, device) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(bookmark_id) DO UPDATE SET snapshot = excluded.snapshot, hash = excluded.hash,
+		updated_at = excluded.updated_at, device = excluded.device`, id, raw, state.hash, state.at, state.device)
+	return err
+}
+
+// localModifiedAt 取操作记录中该收藏最后一次变化的时间，并保证晚于上次同步的版本。
+func (s *Service) localModifiedAt(id string, state *syncState) int64 {
+	var at int64
+	_ = s.db.QueryRow(`SELECT COALESCE(MAX(created_at), 0) FROM activity_log WHERE target_type = 'bookmark' AND target_id = ?`, id).Scan(&at)
+	if at == 0 {
+		_ = s.db.QueryRow("SELECT created_at FROM bookmarks WHERE id = ?", id).Scan(&at)
+	}
+	if at == 0 {
+		at = time.Now().UnixMilli()
+	}
+	if state != nil && at <= state.at {
+		at = state.at + 1
+	}
+	return at
+}
+
+func (s *Service) ListSyncConflicts() ([]SyncConflict, error) {
+	rows, err := s.db.Query(`SELECT c.id, c.bookmark_id, COALESCE(NULLIF(b.alias, ''), b.title, ''), c.field, c.local_value, c.remote_value,
+		c.remote_device, c.winner, c.created_at
+		FROM sync_conflicts c LEFT JOIN bookmarks b ON b.id = c.bookmark_id
+		WHERE c.resolved_at = 0 ORDER BY c.id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []SyncConflict{}
+	for rows.Next() {
+		var item SyncConflict
+		if err := rows.Scan(&item.ID, &item.BookmarkID, &item.Title, &item.Field, &item.LocalValue, &item.RemoteValue,
+			&item.RemoteDevice, &item.Winner, &item.CreatedAt); err != nil {
+			return nil, err
+		}
+		item.Title = s.revealField(item.Title)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// ResolveSyncConflict 选定保留哪一方的备注；改动通过 UpdateNotes 写入，下次同步会传到其他设备。
+func (s *Service) ResolveSyncConflict(id int64, keep string) error {
+	var bookmarkID, localValue, remoteValue string
+	err := s.db.QueryRow("SELECT bookmark_id, local_value, remote_value FROM sync_conflicts WHERE id = ? AND resolved_at = 0", id).
+		Scan(&bookmarkID, &localValue, &remoteValue)
+	if err != nil {
+		return err
+	}
+	var value string
+	switch keep {
+	case syncWinnerLocal:
+		value = localValue
+	case syncWinnerRemote:
+		value = remoteValue
+	default:
+		return fmt.Errorf("无效的选项: %s", keep)
+	}
+	bm, err := s.GetBookmark(bookmarkID)
+	if err != nil {
+		return err
+	}
+	if bm != nil && bm.Notes != value {
+		if err := s.UpdateNotes(bookmarkID, value); err != nil {
+			return err
+		}
+	}
+	_, err = s.db.Exec("UPDATE sync_conflicts SET resolved_at = ? WHERE id = ?", time.Now().UnixMilli(), id)
+	return err
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// syncStore 抽象同步目标上的文件操作，路径以 / 分隔并相对于目标根目录；不存在的文件返回 os.ErrNotExist。
+// 变更日志只追加，单独用 readLog、appendLog 读写，便于不支持追加的目标分段存放。
+type syncStore interface {
+	read(name string) ([]byte, error)
+	write(name string, data []byte) error
+	exists(name string) (bool, error)
+	list(dir string) ([]string, error)
+	readLog(name string, offset int64) ([]byte, error)
+	appendLog(name string, data []byte) error
+}
+
+// folderStore 把同步数据放在本地的共享文件夹中，由 Syncthing、Dropbox 等工具负责传输。
+type folderStore struct {
+	root string
+}
+
+func (f folderStore) path(name string) string {
+	return filepath.Join(f.root, filepath.FromSlash(name))
+}
+
+func (f folderStore) read(name string) ([]byte, error) {
+	return os.ReadFile(f.path(name))
+}
+
+func (f folderStore) readLog(name string, offset int64) ([]byte, error) {
+	file, err := os.Open(f.path(name))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(file)
+}
+
+func (f folderStore) write(name string, data []byte) error {
+	path := f.path(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (f folderStore) appendLog(name string, data []byte) error {
+	path := f.path(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (f folderStore) exists(name string) (bool, error) {
+	_, err := os.Stat(f.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (f folderStore) list(dir string) ([]string, error) {
+	entries, err := os.ReadDir(f.path(dir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
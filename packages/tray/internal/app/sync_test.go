@@ -0,0 +1,178 @@
+package app
+
+import (
+	"strings"
+	"testing"
+)
+
+func syncTestPair(t *testing.T) (a, b *Service) {
+	t.Helper()
+	shared := t.TempDir()
+	a, b = newTestService(t), newTestService(t)
+	for _, s := range []*Service{a, b} {
+		if _, err := s.SetSyncFolder(shared); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return a, b
+}
+
+func mustSync(t *testing.T, s *Service) *SyncResult {
+	t.Helper()
+	result, err := s.SyncNow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestSyncFolderRoundTrip(t *testing.T) {
+	a, b := syncTestPair(t)
+	bm := saveTestBookmark(t, a, "https://example.com/a", "A", "<p>hello</p>")
+	if err := a.UpdateNotes(bm.ID, "note A"); err != nil {
+		t.Fatal(err)
+	}
+
+	if r := mustSync(t, a); r.Exported != 1 {
+		t.Fatalf("export = %+v", r)
+	}
+	if r := mustSync(t, b); r.Imported != 1 {
+		t.Fatalf("import = %+v", r)
+	}
+	got, err := b.GetBookmark(bm.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Notes != "note A" || got.ThumbPath == "" {
+		t.Fatalf("synced bookmark = %+v", got)
+	}
+	content, err := b.GetBookmarkHTML(bm.ID)
+	if err != nil || !strings.Contains(content.HTML, "hello") {
+		t.Fatalf("synced page = %v, %v", content, err)
+	}
+
+	// 双方都已是最新状态时不应再导出或导入。
+	if r := mustSync(t, b); r.Exported != 0 || r.Imported != 0 {
+		t.Fatalf("second sync on b = %+v", r)
+	}
+	if r := mustSync(t, a); r.Exported != 0 || r.Imported != 0 {
+		t.Fatalf("second sync on a = %+v", r)
+	}
+
+	if err := a.permanentDelete("trash.delete", bm.ID); err != nil {
+		t.Fatal(err)
+	}
+	mustSync(t, a)
+	if r := mustSync(t, b); r.Deleted != 1 {
+		t.Fatalf("tombstone = %+v", r)
+	}
+	if got, _ := b.GetBookmark(bm.ID); got != nil {
+		t.Fatalf("bookmark still present after tombstone: %+v", got)
+	}
+}
+
+func TestSyncExportsThumbnailWhenPageBlobExists(t *testing.T) {
+	a, b := syncTestPair(t)
+	bm := saveTestBookmark(t, a, "https://example.com/a", "A", "<p>hello</p>")
+	store, _, err := a.syncTarget()
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := a.syncSnapshot(bm.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 模拟上次同步只写完了页面文件。
+	html, err := a.readArtifact(bm.FilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.write(syncBlobName(snapshot.FileHash), html); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.exportBlobs(store, bm.ID, snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.ThumbHash == "" {
+		t.Fatal("thumbnail hash missing when the page blob already existed")
+	}
+	if exists, err := store.exists(syncBlobName(snapshot.ThumbHash)); err != nil || !exists {
+		t.Fatalf("thumbnail blob not written: %v", err)
+	}
+
+	mustSync(t, a)
+	mustSync(t, b)
+	if got, _ := b.GetBookmark(bm.ID); got == nil || got.ThumbPath == "" {
+		t.Fatalf("thumbnail not synced: %+v", got)
+	}
+}
+
+func TestSyncNotesConflict(t *testing.T) {
+	a, b := syncTestPair(t)
+	bm := saveTestBookmark(t, a, "https://example.com/a", "A", "<p>hello</p>")
+	mustSync(t, a)
+	mustSync(t, b)
+
+	if err := a.UpdateNotes(bm.ID, "edit on A"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.UpdateNotes(bm.ID, "edit on B"); err != nil {
+		t.Fatal(err)
+	}
+	// 让 B 的修改时间明显更晚，结果不依赖测试运行的先后。
+	if _, err := b.db.Exec("UPDATE activity_log SET created_at = created_at + 60000 WHERE target_id = ?", bm.ID); err != nil {
+		t.Fatal(err)
+	}
+	mustSync(t, a)
+	// B 的两端都改过备注，冲突记录在 B 上；A 已导出自己的修改，只需接受较新的版本。
+	if r := mustSync(t, b); r.Conflicts != 1 || r.Imported != 0 {
+		t.Fatalf("b sync = %+v", r)
+	}
+	if r := mustSync(t, a); r.Imported != 1 {
+		t.Fatalf("a sync = %+v", r)
+	}
+
+	for _, s := range []*Service{a, b} {
+		got, err := s.GetBookmark(bm.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Notes != "edit on B" {
+			t.Fatalf("notes = %q, want the later edit", got.Notes)
+		}
+	}
+	conflicts, err := b.ListSyncConflicts()
+	if err != nil || len(conflicts) != 1 {
+		t.Fatalf("conflicts = %+v, %v", conflicts, err)
+	}
+	if c := conflicts[0]; c.LocalValue != "edit on B" || c.RemoteValue != "edit on A" || c.Winner != syncWinnerLocal {
+		t.Fatalf("conflict = %+v", c)
+	}
+}
+
+func TestSyncNewer(t *testing.T) {
+	cases := []struct {
+		atA, atB         int64
+		deviceA, deviceB string
+		want             bool
+	}{
+		{2, 1, "a", "b", true},
+		{1, 2, "b", "a", false},
+		{5, 5, "b", "a", true},
+		{5, 5, "a", "b", false},
+		{5, 5, "a", "a", false},
+		{1, 0, "a", "", true},
+	}
+	for _, c := range cases {
+		if got := syncNewer(c.atA, c.deviceA, c.atB, c.deviceB); got != c.want {
+			t.Errorf("syncNewer(%d, %q, %d, %q) = %v", c.atA, c.deviceA, c.atB, c.deviceB, got)
+		}
+		// 同一对版本从另一端比较必须得出相反的结论，否则两台设备会各自保留自己的版本。
+		if c.atA != c.atB || c.deviceA != c.deviceB {
+			if syncNewer(c.atB, c.deviceB, c.atA, c.deviceA) == c.want {
+				t.Errorf("syncNewer not antisymmetric for %+v", c)
+			}
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+
+	"chrome-collect-tray/internal/protocol"
+)
+
+var defaultStripParams = []string{
+	"utm_*",
+	"fbclid",
+	"gclid",
+	"dclid",
+	"msclkid",
+	"yclid",
+	"igshid",
+	"mc_cid",
+	"mc_eid",
+	"_hsenc",
+	"_hsmi",
+	"spm",
+	"scm",
+}
+
+func (s *Service) normalizeURL(rawURL string) string {
+	return normalizeURL(rawURL, s.urlStripParams())
+}
+
+func normalizeURL(rawURL string, extra map[string][]string) string {
+	trimmed := strings.TrimSpace(rawURL)
+	parsed, err := url.Parse(trimmed)
+	if err != nil || parsed.Host == "" {
+		return trimmed
+	}
+
+	scheme := strings.ToLower(parsed.Scheme)
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	port := parsed.Port()
+	if (scheme == "http" && port == "80") || (scheme == "https" && port == "443") {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port != "" {
+		host += ":" + port
+	}
+
+	path := parsed.EscapedPath()
+	if path == "" {
+		path = "/"
+	} else if len(path) > 1 {
+		path = strings.TrimRight(path, "/")
+		if path == "" {
+			path = "/"
+		}
+	}
+
+	patterns := append([]string{}, defaultStripParams...)
+	for domain, params := range extra {
+		if domainMatches(parsed.Hostname(), domain) {
+			patterns = append(patterns, params...)
+		}
+	}
+
+	query := parsed.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		if !paramMatches(key, patterns) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, url.QueryEscape(key)+"="+url.QueryEscape(value))
+		}
+	}
+
+	normalized := scheme + "://" + host + path
+	if len(parts) > 0 {
+		normalized += "?" + strings.Join(parts, "&")
+	}
+	return normalized
+}
+
+func domainMatches(host, domain string) bool {
+	host = strings.ToLower(host)
+	domain = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "*.")
+	return domain != "" && (host == domain || strings.HasSuffix(host, "."+domain))
+}
+
+func paramMatches(key string, patterns []string) bool {
+	key = strings.ToLower(key)
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if prefix != "" && strings.HasPrefix(key, prefix) {
+				return true
+			}
+			continue
+		}
+		if key == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Service) urlStripParams() map[string][]string {
+	params := map[string][]string{}
+	value, err := s.getMeta(metaURLStripParams)
+	if err != nil || value == "" {
+		return params
+	}
+	_ = json.Unmarshal([]byte(value), &params)
+	return params
+}
+
+func (s *Service) SetURLStripParams(params map[string][]string) (Settings, error) {
+	cleaned := map[string][]string{}
+	for domain, names := range params {
+		domain = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "*.")
+		if domain == "" {
+			return Settings{}, errors.New("域名不能为空")
+		}
+		for _, name := range names {
+			if name = strings.TrimSpace(name); name != "" && name != "*" {
+				cleaned[domain] = append(cleaned[domain], name)
+			}
+		}
+	}
+	raw, err := json.Marshal(cleaned)
+	if err != nil {
+		return Settings{}, err
+	}
+	if err := s.setSetting(protocol.MethodSettingsURLParams, metaURLStripParams, string(raw)); err != nil {
+		return Settings{}, err
+	}
+	if err := s.renormalizeURLs(false); err != nil {
+		return Settings{}, err
+	}
+	return s.GetSettings(), nil
+}
+
+func (s *Service) backfillNormalizedURLs() error {
+	return s.renormalizeURLs(true)
+}
+
+func (s *Service) renormalizeURLs(onlyMissing bool) error {
+	query := "SELECT id, url FROM bookmarks"
+	if onlyMissing {
+		query += " WHERE normalized_url = '' OR normalized_url IS NULL"
+	}
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return err
+	}
+	type urlRow struct {
+		id  string
+		url string
+	}
+	var items []urlRow
+	for rows.Next() {
+		var item urlRow
+		if err := rows.Scan(&item.id, &item.url); err != nil {
+			rows.Close()
+			return err
+		}
+		items = append(items, item)
+	}
+	rows.Close()
+	if len(items) == 0 {
+		return rows.Err()
+	}
+
+	extra := s.urlStripParams()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, item := range items {
+		if _, err := tx.Exec("UPDATE bookmarks SET normalized_url = ? WHERE id = ?", normalizeURL(item.url, extra), item.id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
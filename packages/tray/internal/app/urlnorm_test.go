@@ -0,0 +1,69 @@
+package app
+
+import "testing"
+
+func TestNormalizeURL(t *testing.T) {
+	extra := map[string][]string{"shop.example": {"ref", "sid_*"}}
+	cases := []struct{ in, want string }{
+		{"https://x.com/a?utm_source=news&utm_medium=mail", "https://x.com/a"},
+		{"https://x.com/a?fbclid=123&spm=a.b&id=7", "https://x.com/a?id=7"},
+		{"https://x.com/a#section", "https://x.com/a"},
+		{"HTTPS://X.Com./a", "https://x.com/a"},
+		{"http://x.com:80/a", "http://x.com/a"},
+		{"https://x.com:443/a", "https://x.com/a"},
+		{"https://x.com:8443/a", "https://x.com:8443/a"},
+		{"http://x.com:443/a", "http://x.com:443/a"},
+		{"https://x.com/a/", "https://x.com/a"},
+		{"https://x.com", "https://x.com/"},
+		{"https://x.com///", "https://x.com/"},
+		{"https://x.com/a?b=2&a=1&b=1", "https://x.com/a?a=1&b=1&b=2"},
+		{"https://x.com/A", "https://x.com/A"},
+		{"http://[::1]:80/a", "http://[::1]/a"},
+		{"https://shop.example/p?ref=home&sid_x=1&id=2", "https://shop.example/p?id=2"},
+		{"https://www.shop.example/p?ref=home", "https://www.shop.example/p"},
+		{"https://other.example/p?ref=home", "https://other.example/p?ref=home"},
+		{"  not a url  ", "not a url"},
+	}
+	for _, c := range cases {
+		if got := normalizeURL(c.in, extra); got != c.want {
+			t.Errorf("normalizeURL(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestRenormalizeURLs(t *testing.T) {
+	s := newTestService(t)
+	bm := saveTestBookmark(t, s, "https://Shop.example/p?ref=home&utm_source=x", "P", "<p>p</p>")
+	if exists, err := s.ExistsByURL("https://shop.example/p/#top"); err != nil || exists {
+		t.Fatalf("exists before strip params = %v, %v", exists, err)
+	}
+	if exists, err := s.ExistsByURL("https://shop.example/p?utm_campaign=y&ref=home"); err != nil || !exists {
+		t.Fatalf("tracking params not ignored: %v, %v", exists, err)
+	}
+
+	// 新增按域名剔除的参数后，已保存的收藏随之重新规范化。
+	if _, err := s.SetURLStripParams(map[string][]string{" *.Shop.example ": {"ref", " ", "*"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.urlStripParams(); len(got["shop.example"]) != 1 || got["shop.example"][0] != "ref" {
+		t.Fatalf("stored params = %v", got)
+	}
+	if exists, err := s.ExistsByURL("https://shop.example/p/#top"); err != nil || !exists {
+		t.Fatalf("exists after strip params = %v, %v", exists, err)
+	}
+	if _, err := s.SetURLStripParams(map[string][]string{"": {"ref"}}); err == nil {
+		t.Fatal("empty domain accepted")
+	}
+
+	// 升级前保存的收藏没有规范化网址，启动时补齐。
+	if _, err := s.db.Exec("UPDATE bookmarks SET normalized_url = '' WHERE id = ?", bm.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.backfillNormalizedURLs(); err != nil {
+		t.Fatal(err)
+	}
+	var normalized string
+	if err := s.db.QueryRow("SELECT normalized_url FROM bookmarks WHERE id = ?", bm.ID).Scan(&normalized); err != nil || normalized != "https://shop.example/p" {
+		t.Fatalf("backfilled = %q, %v", normalized, err)
+	}
+}
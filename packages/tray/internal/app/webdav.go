@@ -0,0 +1,570 @@
+package app
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"chrome-collect-tray/internal/protocol"
+)
+
+const (
+	metaWebDAV              = "webdav"
+	metaWebDAVPassword      = "webdav_password"
+	metaWebDAVBackupPending = "webdav_backup_pending"
+	metaWebDAVLastBackup    = "webdav_last_backup"
+	metaWebDAVBackupError   = "webdav_backup_error"
+	webdavRequestTimeout    = 5 * time.Minute
+	webdavSegmentSize       = 512 << 10
+	webdavSnapshotFile      = "webdav-backup.db"
+)
+
+type WebDAVConfig struct {
+	URL        string `json:"url"`
+	Username   string `json:"username"`
+	Sync       bool   `json:"sync"`
+	Backup     bool   `json:"backup"`
+	BackupKeep int    `json:"backupKeep"`
+}
+
+// WebDAVInput 中的 Password 为 nil 时保留已保存的密码。
+type WebDAVInput struct {
+	WebDAVConfig
+	Password *string `json:"password"`
+}
+
+type WebDAVStatus struct {
+	WebDAVConfig
+	HasPassword     bool   `json:"hasPassword"`
+	LastBackupAt    int64  `json:"lastBackupAt"`
+	LastBackupError string `json:"lastBackupError"`
+	PendingBackup   string `json:"pendingBackup"`
+}
+
+func (s *Service) webdavConfig() WebDAVConfig {
+	cfg := WebDAVConfig{}
+	if value, err := s.getMeta(metaWebDAV); err == nil && value != "" {
+		_ = json.Unmarshal([]byte(value), &cfg)
+	}
+	if cfg.BackupKeep <= 0 {
+		cfg.BackupKeep = defaultBackupKeep
+	}
+	return cfg
+}
+
+func (s *Service) WebDAVStatus() (*WebDAVStatus, error) {
+	status := &WebDAVStatus{WebDAVConfig: s.webdavConfig()}
+	stored, err := s.getMeta(metaWebDAVPassword)
+	if err != nil {
+		return nil, err
+	}
+	status.HasPassword = stored != ""
+	if value, _ := s.getMeta(metaWebDAVLastBackup); value != "" {
+		status.LastBackupAt, _ = strconv.ParseInt(value, 10, 64)
+	}
+	status.LastBackupError, _ = s.getMeta(metaWebDAVBackupError)
+	status.PendingBackup, _ = s.getMeta(metaWebDAVBackupPending)
+	return status, nil
+}
+
+// SetWebDAV 保存 WebDAV 目标并立即测试连接；地址为空即关闭同步和备份。
+func (s *Service) SetWebDAV(input WebDAVInput) (*WebDAVStatus, error) {
+	cfg := input.WebDAVConfig
+	cfg.URL = strings.TrimSpace(cfg.URL)
+	cfg.Username = strings.TrimSpace(cfg.Username)
+	if cfg.BackupKeep == 0 {
+		cfg.BackupKeep = defaultBackupKeep
+	}
+	if cfg.BackupKeep < 1 || cfg.BackupKeep > maxBackupKeep {
+		return nil, fmt.Errorf("保留的备份数量需在 1 到 %d 之间", maxBackupKeep)
+	}
+	previous := s.webdavConfig()
+
+	password := ""
+	if input.Password != nil {
+		password = *input.Password
+	} else if cfg.URL != "" {
+		var err error
+		if password, err = s.webdavPassword(); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.URL == "" {
+		cfg = WebDAVConfig{BackupKeep: cfg.BackupKeep}
+		password = ""
+	} else {
+		parsed, err := url.Parse(cfg.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, fmt.Errorf("无效的 WebDAV 地址: %s", cfg.URL)
+		}
+		if !strings.HasSuffix(cfg.URL, "/") {
+			cfg.URL += "/"
+		}
+		if cfg.Sync {
+			if folder, _ := s.getMeta(metaSyncFolder); folder != "" {
+				return nil, errors.New("请先清除同步文件夹")
+			}
+			if err := s.ensureSyncable(); err != nil {
+				return nil, err
+			}
+		}
+		client, err := newWebDAVClientWith(cfg, password)
+		if err != nil {
+			return nil, err
+		}
+		if err := client.ensureRoot(); err != nil {
+			return nil, err
+		}
+	}
+
+	raw, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.setSetting(protocol.MethodSettingsWebDAV, metaWebDAV, string(raw)); err != nil {
+		return nil, err
+	}
+	// 密码不进入操作记录。
+	if err := s.saveSecret(metaWebDAVPassword, password); err != nil {
+		return nil, err
+	}
+	if (previous.Sync || cfg.Sync) && (previous.URL != cfg.URL || previous.Sync != cfg.Sync) {
+		if err := s.resetSyncProgress(); err != nil {
+			return nil, err
+		}
+	}
+	if previous.URL != cfg.URL {
+		if err := s.discardPendingBackup(s.webdavBackupTarget(nil, cfg)); err != nil {
+			return nil, err
+		}
+	}
+	return s.WebDAVStatus()
+}
+
+func (s *Service) webdavPassword() (string, error) {
+	password, err := s.loadSecret(metaWebDAVPassword)
+	if err != nil {
+		return "", errors.New("无法读取保存的 WebDAV 密码，请重新输入")
+	}
+	return password, nil
+}
+
+func (s *Service) newWebDAVClient(cfg WebDAVConfig) (*webdavClient, error) {
+	password, err := s.webdavPassword()
+	if err != nil {
+		return nil, err
+	}
+	return newWebDAVClientWith(cfg, password)
+}
+
+func (s *Service) webdavBackupDue() bool {
+	cfg := s.webdavConfig()
+	if cfg.URL == "" || !cfg.Backup {
+		return false
+	}
+	return s.backupDue(metaWebDAVBackupPending, metaWebDAVLastBackup)
+}
+
+// BackupToWebDAV 把资料库推送到 WebDAV 的 backups/ 目录。不支持断点续传：中断后再次调用只会跳过
+// 服务器上已完整存在的页面文件和数据库分块，其余文件从头上传。
+func (s *Service) BackupToWebDAV() (*BackupResult, error) {
+	return s.runBackup(metaWebDAVLastBackup, metaWebDAVBackupError, s.backupToWebDAV)
+}
+
+func (s *Service) backupToWebDAV() (*BackupResult, error) {
+	cfg := s.webdavConfig()
+	if cfg.URL == "" {
+		return nil, errors.New("尚未设置 WebDAV 地址")
+	}
+	client, err := s.newWebDAVClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return s.pushBackup(s.webdavBackupTarget(client, cfg))
+}
+
+func (s *Service) webdavBackupTarget(client *webdavClient, cfg WebDAVConfig) backupTarget {
+	return backupTarget{
+		store:      &webdavStore{client: client},
+		keep:       cfg.BackupKeep,
+		pendingKey: metaWebDAVBackupPending,
+		snapshot:   webdavSnapshotFile,
+	}
+}
+
+// webdavClient 是同步和备份用到的最小 WebDAV 客户端，路径都相对于配置的根地址。
+type webdavClient struct {
+	base     *url.URL
+	username string
+	password string
+	http     *http.Client
+	dirs     map[string]bool
+}
+
+type webdavMultistatus struct {
+	Responses []struct {
+		Href     string `xml:"DAV: href"`
+		Propstat []struct {
+			Status string `xml:"DAV: status"`
+			Prop   struct {
+				Length       int64  `xml:"DAV: getcontentlength"`
+				Modified     string `xml:"DAV: getlastmodified"`
+				ResourceType struct {
+					Collection *struct{} `xml:"DAV: collection"`
+				} `xml:"DAV: resourcetype"`
+			} `xml:"DAV: prop"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+const webdavPropfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<propfind xmlns="DAV:"><prop><resourcetype/><getcontentlength/><getlastmodified/></prop></propfind>`
+
+func newWebDAVClientWith(cfg WebDAVConfig, password string) (*webdavClient, error) {
+	base, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+	return &webdavClient{
+		base:     base,
+		username: cfg.Username,
+		password: password,
+		http:     &http.Client{Timeout: webdavRequestTimeout},
+		dirs:     map[string]bool{"": true},
+	}, nil
+}
+
+func (c *webdavClient) url(name string) string {
+	u := *c.base
+	u.Path = c.base.Path + strings.TrimPrefix(name, "/")
+	u.RawPath = ""
+	return u.String()
+}
+
+func (c *webdavClient) do(method, name string, body []byte, header http.Header) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, c.url(name), reader)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if c.username != "" || c.password != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		resp.Body.Close()
+		return nil, errors.New("WebDAV 认证失败，请检查用户名和密码")
+	}
+	return resp, nil
+}
+
+// expect 丢弃响应内容，状态码不在 ok 中时返回错误。
+func (c *webdavClient) expect(resp *http.Response, method, name string, ok ...int) error {
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if slices.Contains(ok, resp.StatusCode) {
+		return nil
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("WebDAV %s %s: %w", method, name, os.ErrNotExist)
+	}
+	return fmt.Errorf("WebDAV %s %s 失败: %s", method, name, resp.Status)
+}
+
+// ensureRoot 确认根地址可以访问，不存在时尝试创建。
+func (c *webdavClient) ensureRoot() error {
+	_, err := c.propfind("", 0)
+	if errors.Is(err, os.ErrNotExist) {
+		resp, err := c.do("MKCOL", "", nil, nil)
+		if err != nil {
+			return err
+		}
+		return c.expect(resp, "MKCOL", c.base.Path, http.StatusCreated)
+	}
+	return err
+}
+
+// propfind 列出目录（depth 为 1）或查询单个资源（depth 为 0），结果不含目录自身。
+func (c *webdavClient) propfind(name string, depth int) ([]remoteEntry, error) {
+	header := http.Header{"Depth": {strconv.Itoa(depth)}, "Content-Type": {"application/xml; charset=utf-8"}}
+	resp, err := c.do("PROPFIND", name, []byte(webdavPropfindBody), header)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, c.expect(resp, "PROPFIND", name)
+	}
+	defer resp.Body.Close()
+	var status webdavMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, fmt.Errorf("无法解析 WebDAV 响应: %w", err)
+	}
+	self := strings.TrimSuffix(c.base.Path+strings.TrimPrefix(name, "/"), "/")
+	var entries []remoteEntry
+	for _, response := range status.Responses {
+		href, err := url.Parse(response.Href)
+		if err != nil {
+			continue
+		}
+		hrefPath := strings.TrimSuffix(href.Path, "/")
+		if depth > 0 && hrefPath == self {
+			continue
+		}
+		entry := remoteEntry{name: path.Base(hrefPath)}
+		for _, propstat := range response.Propstat {
+			if !strings.Contains(propstat.Status, " 200") {
+				continue
+			}
+			entry.size = propstat.Prop.Length
+			entry.collection = propstat.Prop.ResourceType.Collection != nil
+			if modified, err := http.ParseTime(propstat.Prop.Modified); err == nil {
+				entry.modified = modified
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// get 从 offset 开始读取文件；服务器忽略 Range 时在本地截取。
+func (c *webdavClient) get(name string, offset int64) ([]byte, error) {
+	header := http.Header{}
+	if offset > 0 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := c.do(http.MethodGet, name, nil, header)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusRequestedRangeNotSatisfiable:
+		return nil, c.expect(resp, http.MethodGet, name, resp.StatusCode)
+	case http.StatusOK, http.StatusPartialContent:
+	default:
+		return nil, c.expect(resp, http.MethodGet, name)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK && offset > 0 {
+		if offset >= int64(len(data)) {
+			return nil, nil
+		}
+		data = data[offset:]
+	}
+	return data, nil
+}
+
+func (c *webdavClient) exists(name string) (bool, error) {
+	resp, err := c.do(http.MethodHead, name, nil, nil)
+	if err != nil {
+		return false, err
+	}
+	err = c.expect(resp, http.MethodHead, name, http.StatusOK)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// put 先上传到临时名称再 MOVE 到目标位置，读取方不会看到写了一半的文件；每次都整体上传。
+func (c *webdavClient) put(name string, data []byte) error {
+	if err := c.mkdirAll(path.Dir(name)); err != nil {
+		return err
+	}
+	tmp := name + ".upload-" + hex.EncodeToString(randomBytes(4))
+	resp, err := c.do(http.MethodPut, tmp, data, nil)
+	if err == nil && resp.StatusCode == http.StatusConflict {
+		// 409 表示上级目录不存在，多半是被其他设备删除了；清掉目录缓存，重建后再试一次。
+		_ = c.expect(resp, http.MethodPut, name, http.StatusConflict)
+		for dir := path.Dir(name); dir != "." && dir != "/"; dir = path.Dir(dir) {
+			delete(c.dirs, dir)
+		}
+		if err := c.mkdirAll(path.Dir(name)); err != nil {
+			return err
+		}
+		resp, err = c.do(http.MethodPut, tmp, data, nil)
+	}
+	if err != nil {
+		return err
+	}
+	if err := c.expect(resp, http.MethodPut, name, http.StatusOK, http.StatusCreated, http.StatusNoContent); err != nil {
+		return err
+	}
+	resp, err = c.do("MOVE", tmp, nil, http.Header{"Destination": {c.url(name)}, "Overwrite": {"T"}})
+	if err != nil {
+		return err
+	}
+	if err := c.expect(resp, "MOVE", name, http.StatusCreated, http.StatusNoContent); err != nil {
+		_ = c.delete(tmp)
+		return err
+	}
+	return nil
+}
+
+// mkdirAll 逐级创建目录；已存在的目录返回 405，同样视为成功。
+func (c *webdavClient) mkdirAll(dir string) error {
+	if dir == "." || dir == "/" || c.dirs[dir] {
+		return nil
+	}
+	if err := c.mkdirAll(path.Dir(dir)); err != nil {
+		return err
+	}
+	resp, err := c.do("MKCOL", dir+"/", nil, nil)
+	if err != nil {
+		return err
+	}
+	if err := c.expect(resp, "MKCOL", dir, http.StatusCreated, http.StatusMethodNotAllowed); err != nil {
+		return err
+	}
+	c.dirs[dir] = true
+	return nil
+}
+
+func (c *webdavClient) delete(name string) error {
+	resp, err := c.do(http.MethodDelete, name, nil, nil)
+	if err != nil {
+		return err
+	}
+	err = c.expect(resp, http.MethodDelete, name, http.StatusOK, http.StatusNoContent)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// webdavStore 把同步数据放在 WebDAV 上。WebDAV 不支持追加写入，变更日志拆成 <name>.d/ 下的分段文件：
+// 最后一段未满时整段重写，否则新建一段，读取时按各段长度换算偏移量。
+type webdavStore struct {
+	client *webdavClient
+}
+
+func (w *webdavStore) read(name string) ([]byte, error) {
+	return w.client.get(name, 0)
+}
+
+func (w *webdavStore) write(name string, data []byte) error {
+	return w.client.put(name, data)
+}
+
+func (w *webdavStore) exists(name string) (bool, error) {
+	return w.client.exists(name)
+}
+
+func (w *webdavStore) entries(dir string) ([]remoteEntry, error) {
+	entries, err := w.client.propfind(dir, 1)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return entries, err
+}
+
+func (w *webdavStore) remove(dir string) error {
+	return w.client.delete(dir)
+}
+
+func (w *webdavStore) list(dir string) ([]string, error) {
+	entries, err := w.entries(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.collection {
+			names = append(names, entry.name)
+		}
+	}
+	return names, nil
+}
+
+func (w *webdavStore) segments(name string) ([]remoteEntry, error) {
+	entries, err := w.client.propfind(name+".d", 1)
+	if err != nil {
+		return nil, err
+	}
+	var segments []remoteEntry
+	for _, entry := range entries {
+		if !entry.collection && !strings.Contains(entry.name, ".upload-") {
+			segments = append(segments, entry)
+		}
+	}
+	slices.SortFunc(segments, func(a, b remoteEntry) int { return strings.Compare(a.name, b.name) })
+	return segments, nil
+}
+
+func (w *webdavStore) readLog(name string, offset int64) ([]byte, error) {
+	segments, err := w.segments(name)
+	if err != nil {
+		return nil, err
+	}
+	var out []byte
+	var start int64
+	for _, segment := range segments {
+		// 只有最后一段会增长，之前的段长度可以直接用来跳过。
+		if out == nil && start+segment.size <= offset {
+			start += segment.size
+			continue
+		}
+		data, err := w.client.get(name+".d/"+segment.name, 0)
+		if err != nil {
+			return nil, err
+		}
+		if out == nil {
+			if skip := offset - start; skip > 0 {
+				if skip > int64(len(data)) {
+					skip = int64(len(data))
+				}
+				data = data[skip:]
+			}
+			out = []byte{}
+		}
+		out = append(out, data...)
+		start += int64(len(data))
+	}
+	return out, nil
+}
+
+func (w *webdavStore) appendLog(name string, data []byte) error {
+	segments, err := w.segments(name)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if len(segments) > 0 {
+		last := segments[len(segments)-1]
+		if last.size+int64(len(data)) <= webdavSegmentSize {
+			existing, err := w.client.get(name+".d/"+last.name, 0)
+			if err != nil {
+				return err
+			}
+			return w.client.put(name+".d/"+last.name, append(existing, data...))
+		}
+	}
+	return w.client.put(fmt.Sprintf("%s.d/%06d", name, len(segments)+1), data)
+}
@@ -0,0 +1,289 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/webdav"
+)
+
+// webdavTestServer 是带基本认证的内存 WebDAV 服务器，fail 返回 true 的请求直接以 500 失败。
+type webdavTestServer struct {
+	*httptest.Server
+	fs      webdav.FileSystem
+	mu      sync.Mutex
+	methods map[string]int
+	fail    func(r *http.Request) bool
+}
+
+func newWebDAVTestServer(t *testing.T) *webdavTestServer {
+	t.Helper()
+	srv := &webdavTestServer{fs: webdav.NewMemFS(), methods: map[string]int{}}
+	handler := &webdav.Handler{FileSystem: srv.fs, LockSystem: webdav.NewMemLS()}
+	srv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "me" || pass != "pw" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		srv.mu.Lock()
+		srv.methods[r.Method]++
+		fail := srv.fail != nil && srv.fail(r)
+		srv.mu.Unlock()
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func (srv *webdavTestServer) count(method string) int {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return srv.methods[method]
+}
+
+func (srv *webdavTestServer) setFail(fail func(r *http.Request) bool) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.fail = fail
+}
+
+func (srv *webdavTestServer) client(t *testing.T, root string) *webdavClient {
+	t.Helper()
+	c, err := newWebDAVClientWith(WebDAVConfig{URL: srv.URL + root, Username: "me"}, "pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestWebDAVClientOperations(t *testing.T) {
+	srv := newWebDAVTestServer(t)
+	c := srv.client(t, "/library")
+	if err := c.ensureRoot(); err != nil {
+		t.Fatal(err)
+	}
+	if srv.count("MKCOL") != 1 {
+		t.Fatalf("MKCOL count = %d", srv.count("MKCOL"))
+	}
+
+	if err := c.put("a/b/file.txt", []byte("0123456789")); err != nil {
+		t.Fatal(err)
+	}
+	if srv.count("MOVE") != 1 {
+		t.Fatal("upload did not go through a temporary name")
+	}
+	data, err := c.get("a/b/file.txt", 4)
+	if err != nil || string(data) != "456789" {
+		t.Fatalf("ranged get = %q, %v", data, err)
+	}
+	if ok, err := c.exists("a/b/file.txt"); err != nil || !ok {
+		t.Fatalf("exists = %v, %v", ok, err)
+	}
+	if ok, err := c.exists("a/b/missing.txt"); err != nil || ok {
+		t.Fatalf("missing exists = %v, %v", ok, err)
+	}
+	if _, err := c.get("a/b/missing.txt", 0); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("missing get error = %v", err)
+	}
+
+	entries, err := c.propfind("a/b", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].name != "file.txt" || entries[0].size != 10 || entries[0].collection {
+		t.Fatalf("propfind = %+v", entries)
+	}
+	entries, err = c.propfind("a", 1)
+	if err != nil || len(entries) != 1 || !entries[0].collection {
+		t.Fatalf("propfind dir = %+v, %v", entries, err)
+	}
+
+	// 其他客户端删掉目录后，缓存的目录已失效，PUT 会得到 409，应重建目录后成功。
+	if err := srv.fs.RemoveAll(context.Background(), "/library/a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.put("a/b/again.txt", []byte("x")); err != nil {
+		t.Fatalf("put after remote delete: %v", err)
+	}
+	if ok, _ := c.exists("a/b/again.txt"); !ok {
+		t.Fatal("file missing after 409 retry")
+	}
+
+	if err := c.delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.delete("a"); err != nil {
+		t.Fatalf("deleting a missing path should succeed: %v", err)
+	}
+
+	bad, _ := newWebDAVClientWith(WebDAVConfig{URL: srv.URL + "/library", Username: "me"}, "wrong")
+	if err := bad.ensureRoot(); err == nil || !strings.Contains(err.Error(), "认证失败") {
+		t.Fatalf("bad credentials error = %v", err)
+	}
+}
+
+func TestWebDAVStoreLogSegments(t *testing.T) {
+	srv := newWebDAVTestServer(t)
+	store := &webdavStore{client: srv.client(t, "/")}
+	var all []byte
+	for i := range 12 {
+		line := []byte(strings.Repeat(string(rune('a'+i)), 100<<10) + "\n")
+		if err := store.appendLog("log", line); err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, line...)
+	}
+	segments, err := store.segments("log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) < 2 {
+		t.Fatalf("log not split into segments: %+v", segments)
+	}
+	for _, offset := range []int64{0, 1, webdavSegmentSize - 1, webdavSegmentSize, 700001, int64(len(all)) - 5, int64(len(all))} {
+		got, err := store.readLog("log", offset)
+		if err != nil || string(got) != string(all[offset:]) {
+			t.Fatalf("offset %d: len=%d err=%v", offset, len(got), err)
+		}
+	}
+	if _, err := store.readLog("missing", 0); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("missing log error = %v", err)
+	}
+}
+
+func TestWebDAVSyncAndSettings(t *testing.T) {
+	srv := newWebDAVTestServer(t)
+	a, b := newTestService(t), newTestService(t)
+	password := "pw"
+	input := WebDAVInput{WebDAVConfig: WebDAVConfig{URL: srv.URL + "/cc", Username: "me", Sync: true}, Password: &password}
+
+	wrong := "nope"
+	if _, err := a.SetWebDAV(WebDAVInput{WebDAVConfig: input.WebDAVConfig, Password: &wrong}); err == nil {
+		t.Fatal("wrong password accepted")
+	}
+	if _, err := a.SetWebDAV(input); err != nil {
+		t.Fatal(err)
+	}
+	if raw, _ := a.getMeta(metaWebDAVPassword); raw == "" || strings.Contains(raw, password) {
+		t.Fatalf("stored password = %q", raw)
+	}
+	if _, err := b.SetWebDAV(input); err != nil {
+		t.Fatal(err)
+	}
+	// Password 为 nil 时沿用已保存的密码。
+	if _, err := b.SetWebDAV(WebDAVInput{WebDAVConfig: input.WebDAVConfig}); err != nil {
+		t.Fatalf("keeping saved password: %v", err)
+	}
+	if _, err := a.SetSyncFolder(t.TempDir()); err == nil {
+		t.Fatal("folder sync accepted while WebDAV sync is on")
+	}
+
+	bm := saveTestBookmark(t, a, "https://example.com/a", "A", "<p>over webdav</p>")
+	mustSync(t, a)
+	if r := mustSync(t, b); r.Imported != 1 {
+		t.Fatalf("import = %+v", r)
+	}
+	content, err := b.GetBookmarkHTML(bm.ID)
+	if err != nil || !strings.Contains(content.HTML, "over webdav") {
+		t.Fatalf("synced page = %v, %v", content, err)
+	}
+
+	if err := a.UpdateNotes(bm.ID, "from A"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.UpdateNotes(bm.ID, "from B"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.db.Exec("UPDATE activity_log SET created_at = created_at + 60000 WHERE target_id = ?", bm.ID); err != nil {
+		t.Fatal(err)
+	}
+	mustSync(t, a)
+	if r := mustSync(t, b); r.Conflicts != 1 {
+		t.Fatalf("conflict sync = %+v", r)
+	}
+	mustSync(t, a)
+	if got, _ := a.GetBookmark(bm.ID); got.Notes != "from B" {
+		t.Fatalf("notes on A = %q", got.Notes)
+	}
+}
+
+func TestWebDAVBackupSkipsUploadedFiles(t *testing.T) {
+	srv := newWebDAVTestServer(t)
+	s := newTestService(t)
+	password := "pw"
+	if _, err := s.SetWebDAV(WebDAVInput{WebDAVConfig: WebDAVConfig{URL: srv.URL + "/cc", Username: "me", Backup: true}, Password: &password}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		saveTestBookmark(t, s, "https://example.com/"+name, name, "<p>"+name+"</p>")
+	}
+
+	srv.setFail(func(r *http.Request) bool {
+		return r.Method == http.MethodPut && strings.Contains(r.URL.Path, "manifest.json")
+	})
+	if _, err := s.BackupToWebDAV(); err == nil {
+		t.Fatal("backup succeeded despite failing manifest upload")
+	}
+	if status, _ := s.WebDAVStatus(); status.LastBackupError == "" || status.PendingBackup == "" {
+		t.Fatalf("status after failure = %+v", status)
+	}
+
+	srv.setFail(nil)
+	result, err := s.BackupToWebDAV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Files != 6 || result.Uploaded != 0 {
+		t.Fatalf("retry result = %+v, want all 6 files already on the server", result)
+	}
+	backups, err := listBackups(&webdavStore{client: srv.client(t, "/cc")})
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("backups = %+v, %v", backups, err)
+	}
+}
+
+func TestWebDAVSweepKeepsSyncedBlobs(t *testing.T) {
+	srv := newWebDAVTestServer(t)
+	s := newTestService(t)
+	password := "pw"
+	if _, err := s.SetWebDAV(WebDAVInput{WebDAVConfig: WebDAVConfig{URL: srv.URL + "/cc", Username: "me", Sync: true, Backup: true}, Password: &password}); err != nil {
+		t.Fatal(err)
+	}
+	bm := saveTestBookmark(t, s, "https://example.com/a", "A", "<p>synced</p>")
+	mustSync(t, s)
+	snapshot, err := s.syncSnapshot(bm.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &webdavStore{client: srv.client(t, "/cc")}
+	for _, name := range []string{syncBlobName("orphan"), "backups/20000101-000000-other/db.part-00001"} {
+		if err := store.write(name, []byte("x")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if swept, err := sweepBlobs(store, time.Now()); err != nil || swept != 0 {
+		t.Fatalf("sweep within the grace period = %d, %v", swept, err)
+	}
+	swept, err := sweepBlobs(store, time.Now().Add(blobGracePeriod+time.Minute))
+	if err != nil || swept != 1 {
+		t.Fatalf("swept = %d, %v", swept, err)
+	}
+	if ok, _ := store.exists(syncBlobName("orphan")); ok {
+		t.Fatal("unreferenced blob kept")
+	}
+	if ok, _ := store.exists(syncBlobName(snapshot.FileHash)); !ok {
+		t.Fatal("blob referenced only by the sync log removed")
+	}
+}
@@ -12,23 +12,82 @@ const (
 	MethodAppOpenManager      = "ui.openManager"
 	MethodShellOpenExternal   = "shell.openExternal"
 	MethodBookmarkSave        = "bookmark.save"
+	MethodCaptureURL          = "capture.url"
+	MethodCaptureURLs         = "capture.urls"
 	MethodBookmarkExistsByURL = "bookmark.existsByUrl"
 	MethodBookmarkList        = "bookmark.list"
 	MethodBookmarkListRecent  = "bookmark.listRecent"
 	MethodBookmarkGet         = "bookmark.get"
 	MethodBookmarkGetHTML     = "bookmark.getHtml"
+	MethodBookmarkThumbnail   = "bookmark.getThumbnail"
+	MethodBookmarkPreviewURL  = "bookmark.previewUrl"
 	MethodBookmarkDelete      = "bookmark.delete"
 	MethodBookmarkUpdateAlias = "bookmark.updateAlias"
 	MethodBookmarkUpdateNotes = "bookmark.updateNotes"
 	MethodBookmarkDownload    = "bookmark.downloadHtml"
 	MethodBookmarkOpenFolder  = "bookmark.openFolder"
+	MethodBookmarkDuplicates  = "bookmark.findDuplicates"
+	MethodBookmarkMerge       = "bookmark.merge"
+	MethodBookmarkSetState    = "bookmark.setState"
+	MethodBookmarkBulk        = "bookmark.bulk"
+	MethodBookmarkNotesHist   = "bookmark.notesHistory"
+	MethodBookmarkNotesDiff   = "bookmark.notesDiff"
+	MethodBookmarkRestoreRev  = "bookmark.restoreNotesRevision"
+	MethodAnnotationList      = "annotation.list"
+	MethodAnnotationCreate    = "annotation.create"
+	MethodAnnotationUpdate    = "annotation.update"
+	MethodAnnotationDelete    = "annotation.delete"
+	MethodAnnotationSearch    = "annotation.search"
+	MethodAnnotationExport    = "annotation.export"
+	MethodFaviconGet          = "favicon.get"
+	MethodFaviconRefresh      = "favicon.refresh"
+	MethodDomainList          = "domain.list"
+	MethodDomainSetName       = "domain.setDisplayName"
+	MethodDomainTrash         = "domain.trash"
+	MethodDomainExport        = "domain.export"
+	MethodActivityList        = "activity.list"
+	MethodActivityUndo        = "activity.undo"
+	MethodLibraryStatus       = "library.status"
+	MethodLibraryEncrypt      = "library.enableEncryption"
+	MethodLibraryDecrypt      = "library.disableEncryption"
+	MethodLibraryUnlock       = "library.unlock"
+	MethodLibraryLock         = "library.lock"
+	MethodLibraryPassphrase   = "library.changePassphrase"
+	MethodLibraryExportSite   = "library.exportSite"
+	MethodLibraryExportFeeds  = "library.exportFeeds"
+	MethodLinkCheckReport     = "linkcheck.report"
+	MethodLinkCheckRun        = "linkcheck.run"
+	MethodSyncStatus          = "sync.status"
+	MethodSyncRun             = "sync.run"
+	MethodSyncConflicts       = "sync.conflicts"
+	MethodSyncResolve         = "sync.resolveConflict"
+	MethodWebDAVStatus        = "webdav.status"
+	MethodWebDAVBackup        = "webdav.backup"
+	MethodS3Status            = "s3.status"
+	MethodS3Backup            = "s3.backup"
+	MethodS3Backups           = "s3.backups"
+	MethodS3Restore           = "s3.restore"
+	MethodArchiveStatus       = "archiveServer.status"
+	MethodArchiveConfigure    = "archiveServer.configure"
+	MethodArchiveResetToken   = "archiveServer.resetToken"
 	MethodTrashList           = "trash.list"
 	MethodTrashRestore        = "trash.restore"
 	MethodTrashDelete         = "trash.delete"
 	MethodTrashEmpty          = "trash.empty"
 	MethodStatsGet            = "stats.get"
+	MethodStatsDetailed       = "stats.detailed"
+	MethodMaintenanceSizes    = "maintenance.recalculateSizes"
+	MethodMaintenanceSanitize = "maintenance.resanitize"
 	MethodSettingsGet         = "settings.get"
 	MethodSettingsSetAuto     = "settings.setAutoStart"
+	MethodSettingsSetTrash    = "settings.setTrashRetention"
+	MethodSettingsURLParams   = "settings.setUrlStripParams"
+	MethodSettingsRevisions   = "settings.setRevisionLimit"
+	MethodSettingsLinkCheck   = "settings.setLinkCheckInterval"
+	MethodSettingsFeed        = "settings.setFeedExport"
+	MethodSettingsSync        = "settings.setSyncFolder"
+	MethodSettingsWebDAV      = "settings.setWebDAV"
+	MethodSettingsS3          = "settings.setS3Backup"
 	MethodVersionGet          = "version.get"
 	MethodUpdateStart         = "update.start"
 	MethodExtensionPing       = "extension.ping"
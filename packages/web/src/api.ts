@@ -8,6 +8,7 @@ export interface Bookmark {
   title: string
   alias: string
   favicon: string
+  favicon_id: string
   file_path: string
   thumb_path: string
   thumb_data_url?: string
@@ -15,14 +16,101 @@ export interface Bookmark {
   created_at: number
   deleted_at: number
   notes: string
+  notesLocked?: boolean
+  /** 资料库已加密且未解锁时为 true，此时 title 和 alias 为空 */
+  titleLocked?: boolean
   tags: string
   bookmark_id: string
+  read_status: ReadStatus
+  read_status_at: number
+  starred: boolean
+  starred_at: number
+  color_label: string
+  archived: boolean
+  archived_at: number
+  domain: string
+  last_opened_at: number
+  expires_in_days?: number
+  sanitized?: SanitizeReport
+}
+
+export interface SanitizeReport {
+  scripts: number
+  eventHandlers: number
+  javascriptUrls: number
+  metaRefresh: number
+  embeds: number
+  externalRefs: number
+}
+
+export type ReadStatus = 'unread' | 'reading' | 'read'
+
+export interface BookmarkFilter {
+  readStatus?: ReadStatus
+  starred?: boolean
+  archived?: boolean
+  colorLabel?: string
+  domain?: string
+  dateFrom?: number
+  dateTo?: number
+  sizeMin?: number
+  sizeMax?: number
+  hasNotes?: boolean
+  hasThumbnail?: boolean
+}
+
+export interface BookmarkPage {
+  items: Bookmark[]
+  total?: number
+  nextCursor?: string
+}
+
+export type BookmarkSort = 'created' | 'title' | 'alias' | 'size' | 'domain' | 'lastOpened'
+
+export interface FacetCount {
+  value: string
+  count: number
+}
+
+export interface BookmarkFacets {
+  domains: FacetCount[]
+  months: FacetCount[]
+}
+
+export interface StateUpdate {
+  readStatus?: ReadStatus
+  starred?: boolean
+  colorLabel?: string
+  archived?: boolean
 }
 
 export interface Stats {
   total: number
   totalSize: number
   trashCount: number
+  unread: number
+  reading: number
+  starred: number
+  archived: number
+}
+
+export interface TimeBucket {
+  period: string
+  count: number
+  size: number
+}
+
+export interface DetailedStats extends Stats {
+  trashSize: number
+  disk: { htmlBytes: number; thumbBytes: number; missingFiles: number }
+  domains: { domain: string; count: number; size: number }[]
+  tags: { tag: string; count: number }[]
+  /** 按星标、阅读状态、归档和颜色标签划分的合集，只含非空项 */
+  collections: { slug: string; name: string; count: number; size: number }[]
+  daily: TimeBucket[]
+  weekly: TimeBucket[]
+  monthly: TimeBucket[]
+  largest: Pick<Bookmark, 'id' | 'url' | 'title' | 'alias' | 'file_size' | 'created_at'>[]
 }
 
 export interface VersionInfo {
@@ -39,15 +127,44 @@ function invoke<T = any>(method: string, payload?: any) {
 
 // ── 收藏 API ──────────────────────────────────────────────────
 export async function fetchBookmarks(
-  opts?: { limit?: number; q?: string },
-): Promise<{ items: Bookmark[]; total: number }> {
+  opts?: {
+    limit?: number
+    offset?: number
+    cursor?: string
+    skipTotal?: boolean
+    q?: string
+    sort?: BookmarkSort
+    order?: 'asc' | 'desc'
+    facets?: boolean
+  } & BookmarkFilter,
+): Promise<BookmarkPage & { facets?: BookmarkFacets }> {
   return invoke('bookmark.list', opts)
 }
 
+export interface CaptureResult {
+  saved: Bookmark[]
+  skipped: string[]
+  failed: { url: string; error: string }[]
+}
+
+export async function captureUrl(url: string): Promise<Bookmark> {
+  return invoke('capture.url', { url })
+}
+
+export async function captureUrls(urls: string[], skipExisting = false): Promise<CaptureResult> {
+  return invoke('capture.urls', { urls, skipExisting })
+}
+
 export async function fetchStats(): Promise<Stats> {
   return invoke('stats.get')
 }
 
+export async function fetchDetailedStats(
+  opts?: { days?: number; weeks?: number; largest?: number },
+): Promise<DetailedStats> {
+  return invoke('stats.detailed', opts)
+}
+
 export async function fetchVersion(): Promise<VersionInfo> {
   return invoke('version.get')
 }
@@ -80,17 +197,515 @@ export async function updateNotes(id: string, notes: string): Promise<void> {
   await invoke('bookmark.updateNotes', { id, notes })
 }
 
+export async function setBookmarkState(ids: string[], update: StateUpdate): Promise<{ updated: number }> {
+  return invoke('bookmark.setState', { ids, ...update })
+}
+
+export type BulkAction = 'trash' | 'restore' | 'purge' | 'tag' | 'untag' | 'move' | 'setState'
+
+export interface BulkRequest {
+  action: BulkAction
+  ids?: string[]
+  query?: { q?: string; trashed?: boolean } & BookmarkFilter
+  tags?: string[]
+  /** 仅用于 move：把收藏从这些标签移到 tags 中的标签；不含任何原标签的收藏报告 not_tagged */
+  from?: string[]
+  state?: StateUpdate
+}
+
+export interface BulkResult {
+  action: BulkAction
+  total: number
+  succeeded: number
+  failed: number
+  items: { id: string; ok: boolean; error?: string }[]
+}
+
+export async function runBulk(req: BulkRequest): Promise<BulkResult> {
+  return invoke('bookmark.bulk', req)
+}
+
 export async function fetchBookmark(id: string): Promise<Bookmark | null> {
   return invoke('bookmark.get', { id })
 }
 
+const thumbnailCache = new Map<string, Promise<string>>()
+
+/** 按需获取缩略图；没有缩略图或读取失败时返回空字符串 */
+export function fetchThumbnail(id: string): Promise<string> {
+  let cached = thumbnailCache.get(id)
+  if (!cached) {
+    cached = invoke<{ dataUrl: string }>('bookmark.getThumbnail', { id })
+      .then(r => r.dataUrl)
+      .catch(() => '')
+    thumbnailCache.set(id, cached)
+  }
+  return cached
+}
+
 export async function fetchBookmarkHtml(id: string): Promise<{ html: string; title?: string }> {
   return invoke('bookmark.getHtml', { id })
 }
 
+/** 桌面窗口中返回独立来源的预览地址；不支持时（如扩展环境）返回 null */
+export async function fetchPreviewUrl(id: string): Promise<string | null> {
+  try {
+    const res = await invoke<{ url: string }>('bookmark.previewUrl', { id })
+    return res.url
+  } catch {
+    return null
+  }
+}
+
+export async function recalculateSizes(): Promise<{ checked: number; updated: number; missing: number; total: number; delta: number }> {
+  return invoke('maintenance.recalculateSizes')
+}
+
+export async function resanitizeLibrary(): Promise<{
+  checked: number
+  updated: number
+  failed: number
+  report: SanitizeReport
+  items: { id: string; title: string; report: SanitizeReport }[]
+}> {
+  return invoke('maintenance.resanitize')
+}
+
+export interface DuplicateGroup {
+  reason: 'url' | 'content' | 'text' | 'image'
+  /** 不含 thumb_data_url，缩略图用 fetchThumbnail 按需加载 */
+  items: Bookmark[]
+}
+
+export async function findDuplicates(
+  opts?: { textDistance?: number; imageDistance?: number },
+): Promise<{ groups: DuplicateGroup[] }> {
+  return invoke('bookmark.findDuplicates', opts)
+}
+
+export async function mergeBookmarks(primaryId: string, ids: string[]): Promise<Bookmark> {
+  return invoke('bookmark.merge', { primaryId, ids })
+}
+
+// ── 标注 API ──────────────────────────────────────────────────
+export interface Annotation {
+  id: string
+  bookmarkId: string
+  exact: string
+  prefix: string
+  suffix: string
+  start: number
+  end: number
+  comment: string
+  color: string
+  created_at: number
+  updated_at: number
+  /** 资料库已加密且未解锁时为 true，此时 exact、prefix、suffix 和 comment 为空 */
+  locked?: boolean
+}
+
+export interface AnnotationInput {
+  bookmarkId: string
+  exact: string
+  prefix?: string
+  suffix?: string
+  start?: number
+  end?: number
+  comment?: string
+  color?: string
+}
+
+export async function fetchAnnotations(bookmarkId: string): Promise<Annotation[]> {
+  const res = await invoke<{ items: Annotation[] }>('annotation.list', { bookmarkId })
+  return res.items
+}
+
+export async function createAnnotation(input: AnnotationInput): Promise<Annotation> {
+  return invoke('annotation.create', input)
+}
+
+export async function updateAnnotation(id: string, comment: string, color = ''): Promise<Annotation> {
+  return invoke('annotation.update', { id, comment, color })
+}
+
+export async function deleteAnnotation(id: string): Promise<void> {
+  await invoke('annotation.delete', { id })
+}
+
+export async function searchAnnotations(
+  q: string,
+  limit?: number,
+): Promise<(Annotation & { bookmarkTitle: string; bookmarkUrl: string })[]> {
+  const res = await invoke<{ items: (Annotation & { bookmarkTitle: string; bookmarkUrl: string })[] }>('annotation.search', { q, limit })
+  return res.items
+}
+
+export async function exportAnnotations(
+  bookmarkId: string,
+  format: 'markdown' | 'jsonld' = 'markdown',
+): Promise<{ format: string; mime: string; content: string }> {
+  return invoke('annotation.export', { bookmarkId, format })
+}
+
+// ── Favicon API ──────────────────────────────────────────────
+const faviconCache = new Map<string, Promise<string>>()
+
+export function fetchFavicon(id: string, size = 32): Promise<string> {
+  const key = `${id}:${size}`
+  let cached = faviconCache.get(key)
+  if (!cached) {
+    cached = invoke<{ dataUrl: string }>('favicon.get', { id, size })
+      .then(r => r.dataUrl)
+      .catch(() => '')
+    faviconCache.set(key, cached)
+  }
+  return cached
+}
+
+export async function refreshFavicon(domain: string): Promise<{ domain: string; faviconId: string; updated: number }> {
+  faviconCache.clear()
+  return invoke('favicon.refresh', { domain })
+}
+
+// ── 域名 API ─────────────────────────────────────────────────
+export interface DomainInfo {
+  domain: string
+  displayName: string
+  count: number
+  size: number
+  latestAt: number
+  faviconId: string
+}
+
+export async function fetchDomains(): Promise<DomainInfo[]> {
+  const res = await invoke<{ items: DomainInfo[] }>('domain.list')
+  return res.items
+}
+
+export async function setDomainDisplayName(domain: string, displayName: string): Promise<void> {
+  await invoke('domain.setDisplayName', { domain, displayName })
+}
+
+export async function trashDomain(domain: string): Promise<{ domain: string; affected: number }> {
+  return invoke('domain.trash', { domain })
+}
+
+export async function exportDomain(domain: string): Promise<{ path: string; count: number }> {
+  return invoke('domain.export', { domain })
+}
+
+export async function exportStaticSite(): Promise<{ path: string; count: number; failed: number }> {
+  return invoke('library.exportSite')
+}
+
+export async function exportFeeds(): Promise<FeedExportResult> {
+  return invoke('library.exportFeeds')
+}
+
+// ── 备注版本 API ─────────────────────────────────────────────
+export interface NoteRevision {
+  id: number
+  bookmarkId: string
+  field: 'notes' | 'alias'
+  content: string
+  source: string
+  createdAt: number
+}
+
+export interface NoteDiff {
+  fromId: number
+  toId: number
+  field: 'notes' | 'alias'
+  lines: { op: 'equal' | 'insert' | 'delete'; text: string }[]
+}
+
+export async function fetchNotesHistory(id: string, field?: 'notes' | 'alias'): Promise<NoteRevision[]> {
+  const res = await invoke<{ items: NoteRevision[] }>('bookmark.notesHistory', { id, field })
+  return res.items
+}
+
+export async function diffNoteRevisions(fromId: number, toId = 0): Promise<NoteDiff> {
+  return invoke('bookmark.notesDiff', { fromId, toId })
+}
+
+export async function restoreNoteRevision(revisionId: number): Promise<Bookmark> {
+  return invoke('bookmark.restoreNotesRevision', { revisionId })
+}
+
+// ── 操作记录 API ─────────────────────────────────────────────
+export interface Activity {
+  id: number
+  at: number
+  source: 'desktop' | 'extension'
+  action: string
+  targetType: 'bookmark' | 'annotation' | 'domain' | 'setting'
+  targetId: string
+  before: Record<string, unknown> | null
+  after: Record<string, unknown> | null
+  undoOf?: number
+  undoneBy?: number
+  undoable: boolean
+}
+
+export async function fetchActivity(
+  opts?: { limit?: number; beforeId?: number; targetId?: string; action?: string },
+): Promise<Activity[]> {
+  const res = await invoke<{ items: Activity[] }>('activity.list', opts)
+  return res.items
+}
+
+export async function undoActivity(id: number): Promise<Activity> {
+  return invoke('activity.undo', { id })
+}
+
+// ── 资料库加密 API ───────────────────────────────────────────
+export interface EncryptionStatus {
+  enabled: boolean
+  unlocked: boolean
+  /** 启用加密时转换中断，解锁后会继续 */
+  pending?: boolean
+}
+
+export async function fetchLibraryStatus(): Promise<EncryptionStatus> {
+  return invoke('library.status')
+}
+
+export async function unlockLibrary(passphrase: string): Promise<EncryptionStatus> {
+  return invoke('library.unlock', { passphrase })
+}
+
+export async function lockLibrary(): Promise<EncryptionStatus> {
+  return invoke('library.lock')
+}
+
+/**
+ * 加密页面、截图、网站图标和备注，文件改用与内容无关的名字；
+ * 标题、别名、网址、标签和标注仍为明文，加密后的备注不再参与搜索。
+ */
+export async function enableEncryption(passphrase: string): Promise<EncryptionStatus> {
+  return invoke('library.enableEncryption', { passphrase })
+}
+
+export async function disableEncryption(passphrase: string): Promise<EncryptionStatus> {
+  return invoke('library.disableEncryption', { passphrase })
+}
+
+export async function changePassphrase(oldPassphrase: string, newPassphrase: string): Promise<EncryptionStatus> {
+  return invoke('library.changePassphrase', { oldPassphrase, newPassphrase })
+}
+
+// ── 链接检查 API ─────────────────────────────────────────────
+export interface LinkStatus {
+  bookmarkId: string
+  title: string
+  url: string
+  state: 'ok' | 'dead' | 'redirected' | 'changed' | 'error'
+  statusCode: number
+  finalUrl?: string
+  error?: string
+  failures: number
+  checkedAt: number
+  changedAt?: number
+}
+
+export interface LinkCheckReport {
+  dead: LinkStatus[]
+  redirected: LinkStatus[]
+  changed: LinkStatus[]
+  checked: number
+  unchecked: number
+  failing: number
+  intervalHours: number
+}
+
+export async function fetchLinkCheckReport(): Promise<LinkCheckReport> {
+  return invoke('linkcheck.report')
+}
+
+export async function runLinkCheck(ids?: string[]): Promise<{ checked: number; items: LinkStatus[] }> {
+  return invoke('linkcheck.run', { ids })
+}
+
+// ── 文件夹同步 API ───────────────────────────────────────────
+export interface SyncStatus {
+  folder: string
+  target?: 'folder' | 'webdav'
+  deviceId: string
+  lastRunAt: number
+  lastError?: string
+  devices: { id: string; name: string; updatedAt: number }[]
+  conflicts: number
+}
+
+export interface SyncResult {
+  exported: number
+  imported: number
+  deleted: number
+  conflicts: number
+  pending: number
+}
+
+export interface SyncConflict {
+  id: number
+  bookmarkId: string
+  title: string
+  field: string
+  localValue: string
+  remoteValue: string
+  remoteDevice: string
+  winner: 'local' | 'remote'
+  createdAt: number
+}
+
+export async function fetchSyncStatus(): Promise<SyncStatus> {
+  return invoke('sync.status')
+}
+
+export async function setSyncFolder(folder: string): Promise<SyncStatus> {
+  return invoke('settings.setSyncFolder', { folder })
+}
+
+export async function runSync(): Promise<SyncResult> {
+  return invoke('sync.run')
+}
+
+export async function fetchSyncConflicts(): Promise<SyncConflict[]> {
+  return invoke('sync.conflicts')
+}
+
+export async function resolveSyncConflict(id: number, keep: 'local' | 'remote'): Promise<void> {
+  await invoke('sync.resolveConflict', { id, keep })
+}
+
+// ── 远程备份 API ─────────────────────────────────────────────
+export interface BackupResult {
+  name: string
+  files: number
+  uploaded: number
+  parts: number
+  removed: number
+  /** 清理掉的不再被任何备份或同步日志引用的页面文件数 */
+  swept: number
+}
+
+export interface BackupInfo {
+  name: string
+  createdAt: number
+  device: string
+  encrypted: boolean
+  size: number
+  files: number
+}
+
+export interface RestoreResult {
+  name: string
+  bookmarks: number
+  files: number
+  savedTo: string
+}
+
+// ── WebDAV API ───────────────────────────────────────────────
+export interface WebDAVConfig {
+  url: string
+  username: string
+  sync: boolean
+  backup: boolean
+  backupKeep: number
+}
+
+export interface WebDAVStatus extends WebDAVConfig {
+  hasPassword: boolean
+  lastBackupAt: number
+  lastBackupError?: string
+  pendingBackup?: string
+}
+
+
+export async function fetchWebDAVStatus(): Promise<WebDAVStatus> {
+  return invoke('webdav.status')
+}
+
+/** password 省略时保留已保存的密码；密码由系统保管（Windows DPAPI / macOS 钥匙串），不写入数据库明文 */
+export async function setWebDAV(config: WebDAVConfig, password?: string): Promise<WebDAVStatus> {
+  return invoke('settings.setWebDAV', { ...config, password })
+}
+
+export async function backupToWebDAV(): Promise<BackupResult> {
+  return invoke('webdav.backup')
+}
+
+// ── S3 备份 API ──────────────────────────────────────────────
+export interface S3Config {
+  endpoint: string
+  region: string
+  bucket: string
+  prefix: string
+  accessKeyId: string
+  pathStyle: boolean
+  encryption: '' | 'AES256' | 'aws:kms'
+  kmsKeyId: string
+  enabled: boolean
+  keep: number
+}
+
+export interface S3Status extends S3Config {
+  hasSecret: boolean
+  lastBackupAt: number
+  lastBackupError?: string
+  pendingBackup?: string
+}
+
+export async function fetchS3Status(): Promise<S3Status> {
+  return invoke('s3.status')
+}
+
+/** secretAccessKey 省略时保留已保存的密钥；与 WebDAV 密码一样由系统保管 */
+export async function setS3Backup(config: S3Config, secretAccessKey?: string): Promise<S3Status> {
+  return invoke('settings.setS3Backup', { ...config, secretAccessKey })
+}
+
+export async function backupToS3(): Promise<BackupResult> {
+  return invoke('s3.backup')
+}
+
+export async function fetchS3Backups(): Promise<BackupInfo[]> {
+  return invoke('s3.backups')
+}
+
+export async function restoreFromS3(name: string): Promise<RestoreResult> {
+  return invoke('s3.restore', { name })
+}
+
+// ── 存档服务 API ─────────────────────────────────────────────
+export interface ArchiveServerStatus {
+  enabled: boolean
+  lan: boolean
+  port: number
+  running: boolean
+  token: string
+  urls: string[]
+  lastError?: string
+}
+
+export async function fetchArchiveServerStatus(): Promise<ArchiveServerStatus> {
+  return invoke('archiveServer.status')
+}
+
+export async function configureArchiveServer(
+  opts: { enabled: boolean; lan?: boolean; port?: number },
+): Promise<ArchiveServerStatus> {
+  return invoke('archiveServer.configure', opts)
+}
+
+export async function resetArchiveServerToken(): Promise<ArchiveServerStatus> {
+  return invoke('archiveServer.resetToken')
+}
+
 // ── 回收站 API ───────────────────────────────────────────────
-export async function fetchTrash(): Promise<Bookmark[]> {
-  return invoke('trash.list')
+export async function fetchTrash(
+  opts?: { limit?: number; cursor?: string; skipTotal?: boolean },
+): Promise<BookmarkPage> {
+  return invoke('trash.list', opts)
 }
 
 export async function restoreBookmark(id: string): Promise<void> {
@@ -116,10 +731,61 @@ export async function checkExtensionInstalled(): Promise<boolean> {
 }
 
 // ── 设置 API ──────────────────────────────────────────────
-export async function fetchAutoStart(): Promise<{ enabled?: boolean; autoStart?: boolean }> {
+export interface Settings {
+  enabled?: boolean
+  autoStart?: boolean
+  extensionInstalled?: boolean
+  trashRetentionDays?: number
+  urlStripParams?: Record<string, string[]>
+  revisionLimit?: number
+  linkCheckIntervalHours?: number
+  feed?: FeedConfig
+}
+
+export interface FeedConfig {
+  atomPath: string
+  jsonPath: string
+  title?: string
+  author?: string
+  tag?: string
+  /** 合集：starred、unread、reading、read、archived 或 color-<颜色> */
+  collection?: string
+  limit?: number
+  /** 资料库加密时是否仍把标题、备注和正文摘要写入订阅源（订阅源文件本身不加密） */
+  includePrivate?: boolean
+}
+
+export interface FeedExportResult {
+  count: number
+  atomPath?: string
+  jsonPath?: string
+}
+
+export async function fetchAutoStart(): Promise<Settings> {
   return invoke('settings.get')
 }
 
 export async function setAutoStart(enabled: boolean): Promise<void> {
   await invoke('settings.setAutoStart', { enabled })
 }
+
+export async function setTrashRetention(days: number): Promise<Settings> {
+  return invoke('settings.setTrashRetention', { days })
+}
+
+export async function setUrlStripParams(params: Record<string, string[]>): Promise<Settings> {
+  return invoke('settings.setUrlStripParams', { params })
+}
+
+export async function setRevisionLimit(limit: number): Promise<Settings> {
+  return invoke('settings.setRevisionLimit', { limit })
+}
+
+export async function setLinkCheckInterval(hours: number): Promise<Settings> {
+  return invoke('settings.setLinkCheckInterval', { hours })
+}
+
+/** 订阅源由桌面端在收藏变化后生成；桌面端未运行期间的变化在它下次启动时写入 */
+export async function setFeedExport(config: FeedConfig): Promise<FeedExportResult> {
+  return invoke('settings.setFeedExport', config)
+}
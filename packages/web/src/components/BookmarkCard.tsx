@@ -1,5 +1,6 @@
 import type { Bookmark } from '../api'
 import { relativeTime, formatSize, getDomain } from '../utils'
+import Favicon from './Favicon'
 
 interface Props {
     item: Bookmark
@@ -58,10 +59,7 @@ export default function BookmarkCard({ item, selected, onPreview, onDelete, onEd
             {/* 内容 */}
             <div className="p-3.5 px-4">
                 <div className="flex items-start gap-2.5 mb-2">
-                    {item.favicon && (
-                        <img src={item.favicon} alt="" className="w-4.5 h-4.5 rounded-1 object-contain shrink-0 mt-0.5"
-                            onError={e => (e.currentTarget.style.display = 'none')} />
-                    )}
+                    <Favicon id={item.favicon_id} fallback={item.favicon} className="w-4.5 h-4.5 rounded-1 object-contain shrink-0 mt-0.5" />
                     <div className="font-semibold text-13px leading-snug line-clamp-2 flex-1 text-white cursor-text hover:text-accent transition-colors"
                         onDoubleClick={e => { e.stopPropagation(); onEditAlias() }}>
                         {displayTitle}
@@ -0,0 +1,27 @@
+import { useEffect, useState } from 'react'
+import { fetchFavicon } from '../api'
+
+interface Props {
+    id: string
+    fallback?: string
+    className?: string
+}
+
+export default function Favicon({ id, fallback, className }: Props) {
+    const [src, setSrc] = useState(fallback || '')
+
+    useEffect(() => {
+        if (!id) return
+        let cancelled = false
+        fetchFavicon(id).then(url => {
+            if (!cancelled && url) setSrc(url)
+        })
+        return () => { cancelled = true }
+    }, [id])
+
+    if (!src) return null
+    return (
+        <img src={src} alt="" className={className}
+            onError={e => (e.currentTarget.style.display = 'none')} />
+    )
+}
@@ -1,5 +1,6 @@
 import type { Bookmark } from '../api'
 import { formatSize, getDomain } from '../utils'
+import Favicon from './Favicon'
 
 interface Props {
     item: Bookmark
@@ -9,7 +10,7 @@ interface Props {
 
 export default function TrashCard({ item, onRestore, onPermanentDelete }: Props) {
     const displayTitle = item.alias || item.title || item.url
-    const daysLeft = Math.max(0, Math.ceil(7 - (Date.now() - item.deleted_at) / (24 * 60 * 60 * 1000)))
+    const daysLeft = item.expires_in_days
 
     return (
         <div className="card-base group opacity-70 hover:opacity-100 hover:border-danger/30">
@@ -44,10 +45,7 @@ export default function TrashCard({ item, onRestore, onPermanentDelete }: Props)
             {/* 内容 */}
             <div className="p-3.5 px-4">
                 <div className="flex items-start gap-2.5 mb-2">
-                    {item.favicon && (
-                        <img src={item.favicon} alt="" className="w-4.5 h-4.5 rounded-1 object-contain shrink-0 mt-0.5"
-                            onError={e => (e.currentTarget.style.display = 'none')} />
-                    )}
+                    <Favicon id={item.favicon_id} fallback={item.favicon} className="w-4.5 h-4.5 rounded-1 object-contain shrink-0 mt-0.5" />
                     <div className="font-semibold text-13px leading-snug line-clamp-2 flex-1 text-white/70">
                         {displayTitle}
                     </div>
@@ -55,7 +53,7 @@ export default function TrashCard({ item, onRestore, onPermanentDelete }: Props)
                 <div className="text-11px text-muted truncate mb-2.5">{getDomain(item.url)}</div>
                 <div className="flex items-center justify-between">
                     <span className="text-11px text-danger font-medium">
-                        {daysLeft > 0 ? `${daysLeft} 天后永久删除` : '即将永久删除'}
+                        {daysLeft === undefined ? '不会自动删除' : daysLeft > 0 ? `${daysLeft} 天后永久删除` : '即将永久删除'}
                     </span>
                     <span className="text-11px text-muted">{formatSize(item.file_size)}</span>
                 </div>
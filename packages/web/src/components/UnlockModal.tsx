@@ -0,0 +1,66 @@
+import { useState, useEffect, useRef } from 'react'
+
+interface Props {
+    open: boolean
+    onUnlock: (passphrase: string) => Promise<void>
+    onClose: () => void
+}
+
+export default function UnlockModal({ open, onUnlock, onClose }: Props) {
+    const [value, setValue] = useState('')
+    const [error, setError] = useState('')
+    const [busy, setBusy] = useState(false)
+    const inputRef = useRef<HTMLInputElement>(null)
+
+    useEffect(() => {
+        if (open) {
+            setValue('')
+            setError('')
+            setTimeout(() => inputRef.current?.focus(), 50)
+        }
+    }, [open])
+
+    if (!open) return null
+
+    const submit = async () => {
+        if (!value || busy) return
+        setBusy(true)
+        setError('')
+        try {
+            await onUnlock(value)
+        } catch (err) {
+            setError(err instanceof Error ? err.message : '解锁失败')
+        } finally {
+            setBusy(false)
+        }
+    }
+
+    return (
+        <div className="fixed inset-0 bg-black/60 backdrop-blur-1.5 flex items-center justify-center z-200 animate-fade-in"
+            onClick={onClose}>
+            <div className="bg-bg-2 border border-border-2 rounded-4 p-6 w-105 max-w-[90vw] shadow-[0_24px_80px_rgba(0,0,0,0.6)] animate-slide-in-up"
+                onClick={e => e.stopPropagation()}>
+                <div className="text-base font-semibold mb-2">解锁资料库</div>
+                <div className="text-xs text-muted mb-1">资料库已加密，输入口令后才能查看页面、截图、网站图标和备注。</div>
+                <div className="text-xs text-muted mb-4">标题、别名、网址、标签和标注仍以明文保存以便搜索；加密的备注不参与搜索。</div>
+                <input
+                    ref={inputRef}
+                    type="password"
+                    value={value}
+                    onChange={e => setValue(e.target.value)}
+                    onKeyDown={e => {
+                        if (e.key === 'Enter') submit()
+                        if (e.key === 'Escape') onClose()
+                    }}
+                    placeholder="输入口令"
+                    className="w-full bg-bg-3 border border-border-2 rounded-2.5 text-white text-sm px-3.5 py-2.5 outline-none mb-2 focus:border-accent transition-colors"
+                />
+                <div className="text-xs text-danger min-h-4 mb-2">{error}</div>
+                <div className="flex justify-end gap-2.5">
+                    <button className="btn-ghost" onClick={onClose}>稍后</button>
+                    <button className="btn-primary" disabled={busy} onClick={submit}>解锁</button>
+                </div>
+            </div>
+        </div>
+    )
+}
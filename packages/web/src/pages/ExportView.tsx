@@ -5,6 +5,7 @@ import * as api from '../api'
 export default function ExportView() {
   const { id } = useParams<{ id: string }>()
   const [html, setHtml] = useState<string | null>(null)
+  const [previewSrc, setPreviewSrc] = useState<string | null>(null)
   const [title, setTitle] = useState('')
   const [sourceUrl, setSourceUrl] = useState('')
   const [loading, setLoading] = useState(true)
@@ -15,14 +16,22 @@ export default function ExportView() {
     let cancelled = false
     setLoading(true)
 
-    Promise.all([api.fetchBookmark(id), api.fetchBookmarkHtml(id)])
-      .then(([bookmark, htmlResp]) => {
+    Promise.all([api.fetchBookmark(id), api.fetchPreviewUrl(id)])
+      .then(async ([bookmark, previewUrl]) => {
         if (cancelled) return
         let nextTitle = ''
         if (bookmark) {
           setSourceUrl(bookmark.url)
           nextTitle = bookmark.alias || bookmark.title || bookmark.url
         }
+        // 桌面窗口优先使用独立来源的预览，存档页面无法触及桥接接口
+        if (bookmark && previewUrl) {
+          setPreviewSrc(previewUrl)
+          setTitle(nextTitle)
+          return
+        }
+        const htmlResp = await api.fetchBookmarkHtml(id)
+        if (cancelled) return
         if (htmlResp?.html) {
           setHtml(htmlResp.html)
           if (!nextTitle) {
@@ -56,7 +65,7 @@ export default function ExportView() {
   }, [title])
 
   const handlePrintPDF = () => {
-    if (!html) return
+    if (!html && !previewSrc) return
     window.print()
   }
 
@@ -88,7 +97,7 @@ export default function ExportView() {
     )
   }
 
-  if (!html) {
+  if (!html && !previewSrc) {
     return (
       <div className="min-h-screen bg-bg flex items-center justify-center text-muted">
         页面不存在
@@ -146,10 +155,10 @@ export default function ExportView() {
 
       <iframe
         ref={iframeRef}
-        srcDoc={html}
+        {...(previewSrc ? { src: previewSrc } : { srcDoc: html ?? '' })}
         className="w-full border-none mt-10"
         style={{ height: 'calc(100vh - 40px)' }}
-        sandbox="allow-same-origin allow-modals"
+        sandbox=""
         title={title}
       />
     </div>
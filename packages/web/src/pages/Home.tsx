@@ -4,9 +4,10 @@ import BookmarkCard from '../components/BookmarkCard'
 import TrashCard from '../components/TrashCard'
 import AliasModal from '../components/AliasModal'
 import NotesModal from '../components/NotesModal'
+import UnlockModal from '../components/UnlockModal'
 import SetupGuide from '../components/SetupGuide'
 import * as api from '../api'
-import type { Bookmark, Stats, VersionInfo } from '../api'
+import type { ArchiveServerStatus, Bookmark, Stats, VersionInfo } from '../api'
 import { formatSize, getDomain } from '../utils'
 
 export default function Home() {
@@ -39,7 +40,7 @@ function HomeInner() {
     // ── 状态 ──────────────────────────────────────────────────────
     const [items, setItems] = useState<Bookmark[]>([])
     const [trashItems, setTrashItems] = useState<Bookmark[]>([])
-    const [stats, setStats] = useState<Stats>({ total: 0, totalSize: 0, trashCount: 0 })
+    const [stats, setStats] = useState<Stats>({ total: 0, totalSize: 0, trashCount: 0, unread: 0, reading: 0, starred: 0, archived: 0 })
     const [loading, setLoading] = useState(true)
     const [viewMode, setViewMode] = useState<ViewMode>('main')
     const [search, setSearch] = useState('')
@@ -52,6 +53,8 @@ function HomeInner() {
     // ── 设置 ──────────────────────────────────────────────────────
     const [settingsOpen, setSettingsOpen] = useState(false)
     const [autoStart, setAutoStart] = useState(false)
+    const [trashRetentionDays, setTrashRetentionDays] = useState(7)
+    const [archiveServer, setArchiveServer] = useState<ArchiveServerStatus | null>(null)
 
     // ── 别名编辑 ──────────────────────────────────────────────────
     const [aliasTarget, setAliasTarget] = useState<{ id: string; value: string } | null>(null)
@@ -59,6 +62,9 @@ function HomeInner() {
     // ── 备注编辑 ──────────────────────────────────────────────────
     const [notesTarget, setNotesTarget] = useState<{ id: string; value: string } | null>(null)
 
+    // ── 资料库解锁 ────────────────────────────────────────────────
+    const [libraryLocked, setLibraryLocked] = useState(false)
+
     // ── 数据加载 ──────────────────────────────────────────────────
     const loadMain = useCallback(async () => {
         setLoading(true)
@@ -79,7 +85,13 @@ function HomeInner() {
     const loadTrash = useCallback(async () => {
         setLoading(true)
         try {
-            const items = await api.fetchTrash()
+            const items: Bookmark[] = []
+            let cursor: string | undefined
+            do {
+                const page = await api.fetchTrash({ limit: 200, cursor, skipTotal: true })
+                items.push(...page.items)
+                cursor = page.nextCursor
+            } while (cursor)
             setTrashItems(items)
         } catch {
             toast.show('加载回收站失败', 'error')
@@ -92,10 +104,15 @@ function HomeInner() {
     useEffect(() => {
         loadMain()
         api.checkExtensionInstalled().then(setExtensionInstalled)
+        api.fetchLibraryStatus().then(s => setLibraryLocked(s.enabled && !s.unlocked)).catch(() => { })
         // 检查更新（异步，不影响主流程）
         api.fetchVersion().then(setVersionInfo).catch(() => { })
         // 获取开机自启状态
-        api.fetchAutoStart().then(r => setAutoStart(Boolean(r.autoStart ?? r.enabled))).catch(() => { })
+        api.fetchAutoStart().then(r => {
+            setAutoStart(Boolean(r.autoStart ?? r.enabled))
+            if (r.trashRetentionDays !== undefined) setTrashRetentionDays(r.trashRetentionDays)
+        }).catch(() => { })
+        api.fetchArchiveServerStatus().then(setArchiveServer).catch(() => { })
     }, [loadMain])
 
     const handleToggleAutoStart = async () => {
@@ -110,6 +127,39 @@ function HomeInner() {
         }
     }
 
+    const handleChangeTrashRetention = async (days: number) => {
+        const prev = trashRetentionDays
+        setTrashRetentionDays(days)
+        try {
+            await api.setTrashRetention(days)
+            toast.show('回收站保留期已更新', 'success')
+            api.fetchStats().then(setStats)
+        } catch {
+            setTrashRetentionDays(prev)
+            toast.show('设置失败', 'error')
+        }
+    }
+
+    const handleToggleArchiveServer = async () => {
+        if (!archiveServer) return
+        const next = !archiveServer.enabled
+        try {
+            setArchiveServer(await api.configureArchiveServer({ enabled: next, lan: archiveServer.lan, port: archiveServer.port }))
+            toast.show(next ? '已开启存档服务' : '已关闭存档服务', 'success')
+        } catch (e) {
+            toast.show(e instanceof Error ? e.message : '设置失败', 'error')
+        }
+    }
+
+    const handleCopyArchiveURL = async (url: string) => {
+        try {
+            await navigator.clipboard.writeText(url)
+            toast.show('已复制访问地址', 'success')
+        } catch {
+            toast.show('复制失败', 'error')
+        }
+    }
+
     useEffect(() => {
         if (viewMode === 'trash') loadTrash()
     }, [viewMode, loadTrash])
@@ -146,7 +196,8 @@ function HomeInner() {
 
     // ── 操作 ──────────────────────────────────────────────────────
     const handleDelete = async (id: string) => {
-        if (!confirm('确认移入回收站？（7 天后自动永久删除）')) return
+        const hint = trashRetentionDays > 0 ? `（${trashRetentionDays} 天后自动永久删除）` : ''
+        if (!confirm(`确认移入回收站？${hint}`)) return
         await api.deleteBookmark(id)
         toast.show('已移入回收站', 'success')
         loadMain()
@@ -155,9 +206,9 @@ function HomeInner() {
     const handleBatchDelete = async () => {
         if (!selectedIds.size) return
         if (!confirm(`确认将 ${selectedIds.size} 条收藏移入回收站？`)) return
-        await Promise.all([...selectedIds].map(id => api.deleteBookmark(id)))
+        const result = await api.runBulk({ action: 'trash', ids: [...selectedIds] })
         setSelectedIds(new Set())
-        toast.show('已移入回收站', 'success')
+        toast.show(result.failed > 0 ? `已移入回收站，${result.failed} 条失败` : '已移入回收站', result.failed > 0 ? 'error' : 'success')
         loadMain()
     }
 
@@ -335,6 +386,51 @@ function HomeInner() {
                                                 }`} />
                                         </button>
                                     </div>
+                                    <div className="flex items-center justify-between mt-3">
+                                        <div>
+                                            <div className="text-sm text-white">回收站保留</div>
+                                            <div className="text-xs text-muted mt-0.5">过期条目自动永久删除</div>
+                                        </div>
+                                        <select
+                                            value={trashRetentionDays}
+                                            onChange={e => handleChangeTrashRetention(Number(e.target.value))}
+                                            className="bg-bg-3 border border-border-2 rounded-2 text-white text-xs px-2 py-1 outline-none cursor-pointer"
+                                        >
+                                            {[1, 7, 30, 90].map(d => <option key={d} value={d}>{d} 天</option>)}
+                                            <option value={0}>永不</option>
+                                        </select>
+                                    </div>
+                                    {archiveServer && (
+                                        <div className="mt-3">
+                                            <div className="flex items-center justify-between">
+                                                <div>
+                                                    <div className="text-sm text-white">只读存档服务</div>
+                                                    <div className="text-xs text-muted mt-0.5">
+                                                        {archiveServer.lastError ? `启动失败：${archiveServer.lastError}` : '在浏览器中凭令牌浏览收藏'}
+                                                    </div>
+                                                </div>
+                                                <button
+                                                    onClick={handleToggleArchiveServer}
+                                                    className={`relative w-11 h-6 rounded-full transition-colors duration-200 cursor-pointer border-none ${archiveServer.enabled ? 'bg-accent' : 'bg-bg-3'
+                                                        }`}
+                                                >
+                                                    <span className={`absolute top-0.5 left-0.5 w-5 h-5 bg-white rounded-full transition-transform duration-200 shadow-sm ${archiveServer.enabled ? 'translate-x-5' : 'translate-x-0'
+                                                        }`} />
+                                                </button>
+                                            </div>
+                                            {archiveServer.enabled && archiveServer.urls.map(url => (
+                                                <button
+                                                    key={url}
+                                                    onClick={() => handleCopyArchiveURL(url)}
+                                                    title="复制访问地址"
+                                                    className="btn-ghost w-full text-xs mt-2 justify-start truncate"
+                                                >
+                                                    <div className="i-lucide-copy w-3.5 h-3.5 shrink-0" />
+                                                    <span className="truncate">{url.replace(/\?token=.*$/, '')}</span>
+                                                </button>
+                                            ))}
+                                        </div>
+                                    )}
                                     <div className="border-t border-border mt-3 pt-3 flex flex-col gap-2">
                                         <button
                                             onClick={async () => {
@@ -352,6 +448,22 @@ function HomeInner() {
                                             检查更新
                                             {versionInfo && <span className="text-muted text-xs ml-1">当前 {versionInfo.current}</span>}
                                         </button>
+                                        <button
+                                            onClick={async () => {
+                                                setSettingsOpen(false)
+                                                toast.show('正在导出静态站点…', 'success')
+                                                try {
+                                                    const result = await api.exportStaticSite()
+                                                    toast.show(`已导出 ${result.count} 条收藏到 ${result.path}`, 'success')
+                                                } catch (e) {
+                                                    toast.show(e instanceof Error ? e.message : '导出失败', 'error')
+                                                }
+                                            }}
+                                            className="btn-ghost w-full text-sm justify-center"
+                                        >
+                                            <div className="i-lucide-globe w-3.5 h-3.5" />
+                                            导出静态站点
+                                        </button>
                                         {versionInfo?.updateAvailable && (
                                             <button
                                                 onClick={async () => {
@@ -422,7 +534,9 @@ function HomeInner() {
                         <div className="flex flex-col items-center justify-center gap-3 py-20 text-muted text-center">
                             <div className="i-lucide-trash-2 w-16 h-16 opacity-30" />
                             <p className="text-base font-medium">回收站是空的</p>
-                            <small className="text-sm">删除的收藏会在这里保留 7 天</small>
+                            <small className="text-sm">
+                                {trashRetentionDays > 0 ? `删除的收藏会在这里保留 ${trashRetentionDays} 天` : '删除的收藏会一直保留在这里'}
+                            </small>
                         </div>
                     ) : (
                         <div className="grid grid-cols-[repeat(auto-fill,minmax(260px,1fr))] gap-5">
@@ -447,6 +561,18 @@ function HomeInner() {
                 onClose={() => setAliasTarget(null)}
             />
 
+            {/* 资料库解锁弹窗 */}
+            <UnlockModal
+                open={libraryLocked}
+                onUnlock={async passphrase => {
+                    await api.unlockLibrary(passphrase)
+                    setLibraryLocked(false)
+                    toast.show('资料库已解锁', 'success')
+                    loadMain()
+                }}
+                onClose={() => setLibraryLocked(false)}
+            />
+
             {/* 备注编辑弹窗 */}
             <NotesModal
                 open={!!notesTarget}